@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// maxCustomCSS 自定义样式表的最大字节数
+const maxCustomCSS = 64 << 10
+
+// maxConvertV1Body /api/v1/convert 和创建流式会话的请求体最大字节数
+const maxConvertV1Body = 10 << 20
+
+// ConvertV1Request /api/v1/convert 请求；除 markdown 外的字段都是转换选项，为空时使用平台默认值
+type ConvertV1Request struct {
+	Markdown string `json:"markdown"`
+	// Theme 主题名称
+	Theme string `json:"theme,omitempty"`
+	// Platform 目标平台
+	Platform string `json:"platform,omitempty"`
+	// Links 链接处理方式：footnote、inline、text
+	Links string `json:"links,omitempty"`
+	// CodeTheme 代码块配色，见 converter.CodeThemes
+	CodeTheme string `json:"code_theme,omitempty"`
+	// HeadingNumbering 标题编号：none、h1（从一级标题开始）、h2（从二级标题开始）
+	HeadingNumbering string `json:"heading_numbering,omitempty"`
+	// Math 数学公式处理方式：mathjax、image、tex
+	Math string `json:"math,omitempty"`
+	// CustomCSS 追加在主题之后的 wenyan 格式样式表，选择器以 #wenyan 开头
+	CustomCSS  string               `json:"custom_css,omitempty"`
+	TableMode  converter.TableMode  `json:"table_mode,omitempty"`
+	TableRules []converter.CellRule `json:"table_rules,omitempty"`
+	TableSpans bool                 `json:"table_spans,omitempty"`
+	// SourceLines 在块级元素上添加 data-line 源码行号，并在响应中返回 source_map
+	SourceLines bool `json:"source_lines,omitempty"`
+}
+
+// ConvertV1Response /api/v1/convert 响应，列表字段没有内容时为空数组
+type ConvertV1Response struct {
+	HTML string `json:"html"`
+	// Meta 文章开头的元信息
+	Meta      converter.FrontMatter `json:"meta"`
+	Stats     converter.Stats       `json:"stats"`
+	TOC       []converter.TOCEntry  `json:"toc"`
+	Warnings  []converter.Warning   `json:"warnings"`
+	Footnotes []string              `json:"footnotes"`
+	// Diagnostics 带源码范围的诊断，供编辑器标注
+	Diagnostics []converter.Diagnostic `json:"diagnostics"`
+	// SourceMap 第 i 项为 HTML 中第 i 个带 data-line 的元素对应的源码行范围，只在请求 source_lines 时返回
+	SourceMap []converter.SourceBlock `json:"source_map,omitempty"`
+	Success   bool                    `json:"success"`
+}
+
+// ConvertV1Error /api/v1/convert 出错时的响应
+type ConvertV1Error struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error"`
+	// Option 出错的选项名，与选项无关的错误为空
+	Option string `json:"option,omitempty"`
+}
+
+// optionError 选项错误，Option 为出错的选项名
+type optionError struct {
+	Option string
+	Err    error
+}
+
+func (e *optionError) Error() string { return e.Err.Error() }
+
+// handleConvertV1 处理 /api/v1/convert：未知选项和无效的选项值返回 400 以及出错的选项名
+func handleConvertV1(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		req, err := decodeConvertV1(http.MaxBytesReader(w, r.Body, maxConvertV1Body))
+		if err == nil {
+			var resp ConvertV1Response
+			if resp, err = convertV1(cfg, req); err == nil {
+				sendConvertV1Response(w, resp, http.StatusOK)
+				return
+			}
+		}
+		sendConvertV1Error(w, err)
+	}
+}
+
+// sendConvertV1Error 发送出错的响应：选项错误和无效的 JSON 返回 400，选项错误带选项名，请求体过大返回 413
+func sendConvertV1Error(w http.ResponseWriter, err error) {
+	resp := ConvertV1Error{Error: err.Error()}
+	status := http.StatusInternalServerError
+	var optErr *optionError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &optErr):
+		resp.Option = optErr.Option
+		status = http.StatusBadRequest
+	case errors.As(err, &tooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, errInvalidJSON):
+		status = http.StatusBadRequest
+	}
+	sendConvertV1Response(w, resp, status)
+}
+
+func sendConvertV1Response(w http.ResponseWriter, resp interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+var errInvalidJSON = errors.New("Invalid JSON")
+
+// decodeConvertV1 解析请求，不接受未知字段和类型错误的字段；body 应由调用方限制大小
+func decodeConvertV1(body io.Reader) (ConvertV1Request, error) {
+	var req ConvertV1Request
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return req, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&req)
+	if err == nil {
+		return req, nil
+	}
+
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		// 嵌套的未知字段（如 table_rules[0].foo）以完整路径报告
+		fieldPath, options := unknownField(json.NewDecoder(bytes.NewReader(data)), reflect.TypeOf(req), "")
+		if fieldPath == "" {
+			fieldPath, options = strings.Trim(name, `"`), convertV1Options()
+		}
+		name := fieldPath[strings.LastIndexAny(fieldPath, ".]")+1:]
+		kind := "option"
+		if name != fieldPath {
+			kind = "field"
+		}
+		msg := fmt.Sprintf("unknown %s %q", kind, fieldPath)
+		if guess := closestOption(name, options); guess != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", guess)
+		}
+		return req, &optionError{Option: fieldPath, Err: fmt.Errorf("%s; supported %ss: %s", msg, kind, strings.Join(options, ", "))}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		name := strings.SplitN(typeErr.Field, ".", 2)[0]
+		return req, &optionError{Option: name, Err: fmt.Errorf("option %q must be %s, got %s", name, jsonKind(typeErr.Type), typeErr.Value)}
+	}
+	return req, fmt.Errorf("%w: %v", errInvalidJSON, err)
+}
+
+// convertV1 校验选项并转换
+func convertV1(cfg *Config, req ConvertV1Request) (ConvertV1Response, error) {
+	conv, numbering, err := newV1Converter(cfg, req)
+	if err != nil {
+		return ConvertV1Response{}, err
+	}
+	conv.SetSourceLines(req.SourceLines)
+
+	html := conv.ConvertMarkdownToWechat(req.Markdown)
+	doc := converter.Parse(req.Markdown)
+	resp := ConvertV1Response{
+		HTML:        html,
+		Meta:        doc.FrontMatter,
+		Stats:       conv.Stats(),
+		TOC:         converter.BuildTOC(doc, numbering),
+		Warnings:    append([]converter.Warning{}, conv.Warnings()...),
+		Footnotes:   append([]string{}, conv.Footnotes()...),
+		Diagnostics: append([]converter.Diagnostic{}, conv.Diagnostics()...),
+		Success:     true,
+	}
+	if req.SourceLines {
+		resp.SourceMap = append([]converter.SourceBlock{}, conv.SourceMap()...)
+	}
+	if resp.Meta == nil {
+		resp.Meta = converter.FrontMatter{}
+	}
+	return resp, nil
+}
+
+// newV1Converter 校验请求中的选项并创建转换器，同时返回标题编号方式；选项错误为 *optionError
+func newV1Converter(cfg *Config, req ConvertV1Request) (*converter.WechatConverter, converter.HeadingNumbering, error) {
+	// 只在指定主题时读取主题目录，目录不存在时只有默认主题
+	var themes []string
+	if req.Theme != "" {
+		var err error
+		themes, err = converter.ListThemes(cfg.ThemeDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			themes = []string{converter.DefaultTheme}
+		} else if err != nil {
+			return nil, "", err
+		}
+	}
+	checks := []struct {
+		option string
+		value  string
+		valid  []string
+	}{
+		{"platform", req.Platform, converter.Profiles()},
+		{"theme", req.Theme, themes},
+		{"links", req.Links, []string{string(converter.LinkFootnote), string(converter.LinkInline), string(converter.LinkText)}},
+		{"code_theme", req.CodeTheme, converter.CodeThemes()},
+		{"heading_numbering", req.HeadingNumbering, []string{"none", string(converter.NumberingH1), string(converter.NumberingH2)}},
+		{"math", req.Math, []string{string(converter.MathJax), string(converter.MathImage), string(converter.MathTeX)}},
+		{"table_mode", string(req.TableMode), []string{string(converter.TableStandard), string(converter.TableScroll), string(converter.TableASCII), string(converter.TableCard)}},
+	}
+	for _, check := range checks {
+		if check.value != "" && !slices.Contains(check.valid, check.value) {
+			return nil, "", &optionError{
+				Option: check.option,
+				Err:    fmt.Errorf("invalid value %q for option %q; supported values: %s", check.value, check.option, strings.Join(check.valid, ", ")),
+			}
+		}
+	}
+	if len(req.CustomCSS) > maxCustomCSS {
+		return nil, "", &optionError{Option: "custom_css", Err: fmt.Errorf("option \"custom_css\" is larger than %d bytes", maxCustomCSS)}
+	}
+
+	conv, status, err := newRequestConverter(cfg, ConvertRequest{
+		Platform:   req.Platform,
+		Theme:      req.Theme,
+		Links:      req.Links,
+		TableMode:  req.TableMode,
+		TableRules: req.TableRules,
+		TableSpans: req.TableSpans,
+	})
+	if err != nil {
+		// 其他选项都已校验，请求错误只可能来自表格规则
+		if status == http.StatusBadRequest {
+			return nil, "", &optionError{Option: "table_rules", Err: err}
+		}
+		return nil, "", err
+	}
+
+	profile := conv.Profile()
+	if req.Math != "" {
+		profile.MathMode = converter.MathMode(req.Math)
+		if profile.MathMode == converter.MathImage && profile.MathImageURL == "" {
+			profile.MathImageURL = converter.DefaultMathImageURL
+		}
+		conv.SetProfile(profile)
+	}
+	conv.ApplyCustomCSS(req.CustomCSS)
+	numbering := converter.HeadingNumbering(req.HeadingNumbering)
+	if numbering == "none" {
+		numbering = converter.NumberingNone
+	}
+	if err := conv.SetHeadingNumbering(numbering); err != nil {
+		return nil, "", &optionError{Option: "heading_numbering", Err: err}
+	}
+	if err := conv.SetCodeTheme(req.CodeTheme); err != nil {
+		return nil, "", &optionError{Option: "code_theme", Err: err}
+	}
+	return conv, numbering, nil
+}
+
+// convertV1Options 返回请求中的选项名（不含 markdown）
+func convertV1Options() []string {
+	var names []string
+	for _, name := range jsonFields(reflect.TypeOf(ConvertV1Request{})) {
+		if name != "markdown" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// jsonFields 返回结构体中可以从 JSON 解析的字段名
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		if !t.Field(i).IsExported() {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// unknownField 按文档顺序查找第一个 t 中没有的字段，返回其完整路径（如 table_rules[0].foo）
+// 和所在结构体支持的字段名；顶层结构体的字段名为选项名
+func unknownField(dec *json.Decoder, t reflect.Type, prefix string) (string, []string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return "", nil
+	}
+	delim, _ := tok.(json.Delim)
+	switch {
+	case delim == '{' && t.Kind() == reflect.Struct:
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return "", nil
+			}
+			key, _ := tok.(string)
+			field, ok := t.FieldByNameFunc(func(name string) bool {
+				f, _ := t.FieldByName(name)
+				tag := strings.Split(f.Tag.Get("json"), ",")[0]
+				if tag == "" {
+					tag = name
+				}
+				return f.IsExported() && tag != "-" && strings.EqualFold(tag, key)
+			})
+			if !ok {
+				if prefix == "" {
+					return key, convertV1Options()
+				}
+				return prefix + "." + key, jsonFields(t)
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if p, options := unknownField(dec, field.Type, key); p != "" {
+				return p, options
+			}
+		}
+	case delim == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for i := 0; dec.More(); i++ {
+			if p, options := unknownField(dec, t.Elem(), fmt.Sprintf("%s[%d]", prefix, i)); p != "" {
+				return p, options
+			}
+		}
+	case delim == '{' || delim == '[':
+		// 类型不匹配的值不会产生未知字段，跳过
+		for depth := 1; depth > 0; {
+			tok, err := dec.Token()
+			if err != nil {
+				return "", nil
+			}
+			switch tok {
+			case json.Delim('{'), json.Delim('['):
+				depth++
+			case json.Delim('}'), json.Delim(']'):
+				depth--
+			}
+		}
+		return "", nil
+	default:
+		return "", nil
+	}
+	dec.Token()
+	return "", nil
+}
+
+// closestOption 返回与 name 编辑距离不超过 2 的最接近的选项名，用于提示拼写错误
+func closestOption(name string, options []string) string {
+	best, bestDist := "", 3
+	for _, option := range options {
+		if d := editDistance(strings.ToLower(name), option); d < bestDist {
+			best, bestDist = option, d
+		}
+	}
+	return best
+}
+
+// editDistance 计算两个字符串的编辑距离
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// jsonKind 返回 Go 类型对应的 JSON 类型名，用于类型错误提示
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
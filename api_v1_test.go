@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// TestHandleConvertV1Options 主题目录不存在时不指定主题的请求照常转换，未知选项和嵌套的未知字段报告完整路径，
+// 请求体过大时返回 413
+func TestHandleConvertV1Options(t *testing.T) {
+	handler := handleConvertV1(&Config{ThemeDir: filepath.Join(t.TempDir(), "missing")})
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		option string
+		errMsg string
+	}{
+		{"no theme", `{"markdown":"# A"}`, http.StatusOK, "", ""},
+		{"default theme", `{"markdown":"# A","theme":"default"}`, http.StatusOK, "", ""},
+		{"unknown theme", `{"markdown":"# A","theme":"lapis"}`, http.StatusBadRequest, "theme", `invalid value "lapis" for option "theme"; supported values: default`},
+		{"unknown option", `{"markdown":"# A","them":"x"}`, http.StatusBadRequest, "them", `unknown option "them" (did you mean "theme"?)`},
+		{
+			"unknown rule field",
+			`{"markdown":"# A","table_rules":[{"style":"color: red;"},{"style":"color: red;","prefx":"-"}]}`,
+			http.StatusBadRequest, "table_rules[1].prefx",
+			`unknown field "table_rules[1].prefx" (did you mean "prefix"?); supported fields: column, prefix, contains, pattern, style`,
+		},
+		{
+			"unknown field after nested values",
+			`{"table_rules":[{"style":"a","pattern":"[{"}],"markdown":"# A","SOURCE_LINES":true,"foo":1}`,
+			http.StatusBadRequest, "foo", `unknown option "foo"`,
+		},
+		{"body too large", `{"markdown":"` + strings.Repeat("a", maxConvertV1Body) + `"}`, http.StatusRequestEntityTooLarge, "", "http: request body too large"},
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/convert", strings.NewReader(tc.body)))
+		var resp ConvertV1Error
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != tc.status || resp.Option != tc.option || !strings.HasPrefix(resp.Error, tc.errMsg) {
+			t.Errorf("%s: %d %+v, want %d option %q error %q", tc.name, rec.Code, resp, tc.status, tc.option, tc.errMsg)
+		}
+	}
+}
+
+// TestHandleConvertV1Diagnostics 不需要额外选项，响应中总是带有诊断，供编辑器页面标注
+func TestHandleConvertV1Diagnostics(t *testing.T) {
+	handler := handleConvertV1(&Config{ThemeDir: filepath.Join(t.TempDir(), "missing")})
+	for _, tc := range []struct {
+		markdown string
+		codes    []string
+	}{
+		{"# A\n", nil},
+		{"a\n\n```go\nx\n", []string{converter.DiagUnclosedFence}},
+	} {
+		body, _ := json.Marshal(ConvertV1Request{Markdown: tc.markdown})
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/convert", bytes.NewReader(body)))
+		var resp ConvertV1Response
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%q: %d %s", tc.markdown, rec.Code, rec.Body)
+		}
+		var codes []string
+		for _, d := range resp.Diagnostics {
+			codes = append(codes, d.Code)
+		}
+		if resp.Diagnostics == nil || !slices.Equal(codes, tc.codes) {
+			t.Errorf("%q: diagnostics %+v, want codes %v", tc.markdown, resp.Diagnostics, tc.codes)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// buildManifestName 增量构建清单，保存在输出目录中
+const buildManifestName = ".build-manifest.json"
+
+// buildManifestVersion 清单格式或输出方式变化时递增，旧清单中的记录全部失效
+const buildManifestVersion = 1
+
+// buildManifest 上次构建的输入摘要：Settings 为构建选项和主题样式表的摘要，
+// Files 以源文件相对路径为键
+type buildManifest struct {
+	Version  int                   `json:"version"`
+	Settings string                `json:"settings"`
+	Files    map[string]buildEntry `json:"files"`
+}
+
+// buildEntry 单个源文件的构建记录
+type buildEntry struct {
+	Hash   string `json:"hash"`
+	Output string `json:"output"`
+	// Title、Date、Digest 用于生成索引页，只有文章有
+	Title  string `json:"title,omitempty"`
+	Date   string `json:"date,omitempty"`
+	Digest string `json:"digest,omitempty"`
+}
+
+// buildOptions 构建的目录和转换选项
+type buildOptions struct {
+	src, dst        string
+	platform, theme string
+	preview         bool
+}
+
+// buildJob 一个待构建的源文件
+type buildJob struct {
+	rel  string
+	hash string
+}
+
+// buildResult 构建结果，err 不为空时该文件构建失败
+type buildResult struct {
+	rel      string
+	entry    buildEntry
+	warnings []converter.Warning
+	skipped  bool
+	err      error
+}
+
+// runBuild 把目录中的文章转换为 HTML、复制其他文件并生成索引页：
+// build [-o dist] [-j N] [-platform name] [-theme name] [-preview] [-force] srcdir
+func runBuild(cfg *Config, args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	output := flags.String("o", "dist", "output directory")
+	jobs := flags.Int("j", runtime.NumCPU(), "number of files to convert in parallel")
+	platform := flags.String("platform", "", "target platform profile (default: wechat)")
+	theme := flags.String("theme", "", "theme name (default: front matter theme or platform default)")
+	preview := flags.Bool("preview", false, "wrap the articles in a phone-width preview frame")
+	force := flags.Bool("force", false, "rebuild every file even if it is unchanged")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || *jobs < 1 {
+		fmt.Fprintln(os.Stderr, "usage: build [-o dist] [-j N] [-platform name] [-theme name] [-preview] [-force] srcdir")
+		return 2
+	}
+	if _, ok := converter.GetProfile(*platform); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown platform: %s\n", *platform)
+		return 2
+	}
+	src, dst := flags.Arg(0), *output
+
+	files, errs, err := buildSources(src, dst)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	settings, err := buildSettings(cfg, *platform, *theme, *preview)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	prev := loadBuildManifest(dst)
+	// 源文件已删除的输出一并删除，无法读取的文件保留上次的输出
+	for rel, entry := range prev.Files {
+		if _, ok := files[rel]; !ok && errs[rel] == nil {
+			os.Remove(filepath.Join(dst, filepath.FromSlash(entry.Output)))
+		}
+	}
+	if *force || prev.Version != buildManifestVersion || prev.Settings != settings {
+		prev.Files = nil
+	}
+	next := buildManifest{Version: buildManifestVersion, Settings: settings, Files: make(map[string]buildEntry)}
+	opts := buildOptions{src: src, dst: dst, platform: *platform, theme: *theme, preview: *preview}
+
+	queue := make(chan buildJob)
+	results := make(chan buildResult)
+	var wg sync.WaitGroup
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				results <- buildFile(cfg, opts, job, prev.Files[job.rel])
+			}
+		}()
+	}
+	go func() {
+		rels := make([]string, 0, len(files))
+		for rel := range files {
+			rels = append(rels, rel)
+		}
+		sort.Strings(rels)
+		for _, rel := range rels {
+			queue <- buildJob{rel: rel, hash: files[rel]}
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	// 无法读取的文件不构建，按失败计数
+	var built, skipped, failed int
+	rels := make([]string, 0, len(errs))
+	for rel := range errs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", filepath.Join(src, filepath.FromSlash(rel)), errs[rel])
+		failed++
+	}
+	for res := range results {
+		file := filepath.Join(src, filepath.FromSlash(res.rel))
+		for _, w := range res.warnings {
+			fmt.Fprintf(os.Stderr, "%s warning: %s\n", warningPrefix(file, w), w.Message)
+		}
+		switch {
+		case res.err != nil:
+			// 失败的文件不写入清单，下次构建时重试
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, res.err)
+			failed++
+			continue
+		case res.skipped:
+			skipped++
+		default:
+			built++
+		}
+		next.Files[res.rel] = res.entry
+	}
+
+	status := 0
+	if err := writeBuildIndex(dst, files, errs, next); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		status = 1
+	}
+	if err := saveBuildManifest(dst, next); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		status = 1
+	}
+	fmt.Printf("built %d, unchanged %d, failed %d\n", built, skipped, failed)
+	if failed > 0 {
+		status = 1
+	}
+	return status
+}
+
+// buildSources 返回源目录中的文件及其内容摘要，跳过隐藏文件、隐藏目录和位于源目录中的输出目录；
+// 文章的摘要包含其引用的本地图片的摘要。无法读取的文件和目录记录在 errs 中，不影响其他文件
+func buildSources(src, dst string) (files map[string]string, errs map[string]error, err error) {
+	absDst, err := filepath.Abs(dst)
+	if err != nil {
+		return nil, nil, err
+	}
+	files = make(map[string]string)
+	errs = make(map[string]error)
+	err = filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == src {
+				return err
+			}
+			// 无法读取的目录跳过其中的文件
+			if rel, relErr := filepath.Rel(src, p); relErr == nil {
+				errs[filepath.ToSlash(rel)] = err
+			}
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p != src && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if abs, err := filepath.Abs(p); err == nil && abs == absDst {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(p)
+		if err != nil {
+			errs[filepath.ToSlash(rel)] = err
+			return nil
+		}
+		files[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 图片变化（包括新增或删除）时文章的输出和警告也会变化
+	for rel, hash := range files {
+		if !isArticleFile(rel) {
+			continue
+		}
+		markdown, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(rel)))
+		if err != nil {
+			errs[rel] = err
+			delete(files, rel)
+			continue
+		}
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\n", hash)
+		for _, image := range converter.LocalImages(string(markdown)) {
+			image = path.Join(path.Dir(rel), image)
+			fmt.Fprintf(h, "%s=%s\n", image, files[image])
+		}
+		files[rel] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	// 输出路径相同的文件（如 x.md 和 x.html）都不构建，避免互相覆盖
+	outputs := make(map[string][]string)
+	for rel := range files {
+		outputs[buildOutput(rel)] = append(outputs[buildOutput(rel)], rel)
+	}
+	for output, rels := range outputs {
+		if len(rels) < 2 {
+			continue
+		}
+		sort.Strings(rels)
+		for i, rel := range rels {
+			others := append(append([]string(nil), rels[:i]...), rels[i+1:]...)
+			errs[rel] = fmt.Errorf("output %s is also produced by %s", output, strings.Join(others, ", "))
+			delete(files, rel)
+		}
+	}
+	return files, errs, nil
+}
+
+// isArticleFile 判断源文件是否为需要转换的文章
+func isArticleFile(rel string) bool {
+	return strings.EqualFold(path.Ext(rel), ".md")
+}
+
+// buildOutput 返回源文件在输出目录中的相对路径：文章为同名的 .html，其他文件不变
+func buildOutput(rel string) string {
+	if isArticleFile(rel) {
+		return strings.TrimSuffix(rel, path.Ext(rel)) + ".html"
+	}
+	return rel
+}
+
+// buildSettings 返回影响输出的构建选项和全部主题样式表的摘要
+func buildSettings(cfg *Config, platform, theme string, preview bool) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "platform=%s\ntheme=%s\npreview=%t\n", platform, theme, preview)
+	themes, err := converter.ListThemes(cfg.ThemeDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	for _, name := range themes {
+		css, err := converter.ReadThemeCSS(cfg.ThemeDir, name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s=%x\n", name, sha256.Sum256([]byte(css)))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// buildFile 构建单个文件：文章转换为同名的 .html，其他文件原样复制；内容和输出都没有变化时跳过
+func buildFile(cfg *Config, opts buildOptions, job buildJob, prev buildEntry) buildResult {
+	res := buildResult{rel: job.rel, entry: buildEntry{Hash: job.hash, Output: buildOutput(job.rel)}}
+	isArticle := isArticleFile(job.rel)
+	out := filepath.Join(opts.dst, filepath.FromSlash(res.entry.Output))
+
+	if prev.Hash == job.hash && prev.Output == res.entry.Output {
+		if _, err := os.Stat(out); err == nil {
+			res.entry = prev
+			res.skipped = true
+			return res
+		}
+	}
+
+	in := filepath.Join(opts.src, filepath.FromSlash(job.rel))
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		res.err = err
+		return res
+	}
+	if !isArticle {
+		res.err = copyFile(in, out)
+		return res
+	}
+
+	markdown, err := os.ReadFile(in)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	req := ExportRequest{
+		ConvertRequest: ConvertRequest{Markdown: string(markdown), Platform: opts.platform, Theme: opts.theme},
+		Preview:        opts.preview,
+	}
+	doc := converter.Parse(req.Markdown)
+	if req.Theme == "" {
+		req.Theme = doc.FrontMatter.Get("theme")
+	}
+	// 图片保留相对路径，和文章一起复制到输出目录
+	page, warnings, _, err := exportDocument(cfg, req, converter.ImageOptions{BaseDir: filepath.Dir(in)})
+	if err != nil {
+		res.err = err
+		return res
+	}
+	if err := os.WriteFile(out, []byte(page), 0644); err != nil {
+		res.err = err
+		return res
+	}
+
+	res.warnings = warnings
+	res.entry.Title = doc.Title()
+	res.entry.Date = doc.FrontMatter.Get("date")
+	res.entry.Digest = converter.ComputeStats(doc).Digest
+	return res
+}
+
+// writeBuildIndex 生成列出全部文章的 index.html，按日期从新到旧排列；
+// 源目录根部有输出为 index.html 的文件（index.md 或 index.html，不区分大小写）时不生成，避免覆盖
+func writeBuildIndex(dst string, files map[string]string, errs map[string]error, manifest buildManifest) error {
+	for rel := range files {
+		if strings.EqualFold(buildOutput(rel), "index.html") {
+			return nil
+		}
+	}
+	for rel := range errs {
+		if strings.EqualFold(buildOutput(rel), "index.html") {
+			return nil
+		}
+	}
+
+	var articles []buildEntry
+	for rel, entry := range manifest.Files {
+		if isArticleFile(rel) {
+			articles = append(articles, entry)
+		}
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if articles[i].Date != articles[j].Date {
+			return articles[i].Date > articles[j].Date
+		}
+		return articles[i].Output < articles[j].Output
+	})
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Articles</title>
+<style>
+body { max-width: 677px; margin: 0 auto; padding: 20px 16px; font-family: -apple-system-font, BlinkMacSystemFont, 'Helvetica Neue', 'PingFang SC', 'Microsoft YaHei', sans-serif; color: #3f3f3f; }
+li { margin: 1em 0; list-style: none; }
+a { color: #009874; font-size: 18px; text-decoration: none; }
+time, p { color: #888; font-size: 14px; margin: 0.3em 0 0; }
+</style>
+</head>
+<body>
+<ul>
+`)
+	for _, a := range articles {
+		title := a.Title
+		if title == "" {
+			title = a.Output
+		}
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a>", html.EscapeString(a.Output), html.EscapeString(title))
+		if a.Date != "" {
+			fmt.Fprintf(&b, "<time>%s</time>", html.EscapeString(a.Date))
+		}
+		if a.Digest != "" {
+			fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(a.Digest))
+		}
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, "index.html"), []byte(b.String()), 0644)
+}
+
+// loadBuildManifest 读取上次构建的清单，不存在或无法解析时返回空清单
+func loadBuildManifest(dst string) buildManifest {
+	var m buildManifest
+	data, err := os.ReadFile(filepath.Join(dst, buildManifestName))
+	if err == nil && json.Unmarshal(data, &m) == nil {
+		return m
+	}
+	return buildManifest{}
+}
+
+func saveBuildManifest(dst string, m buildManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, buildManifestName), data, 0644)
+}
+
+// hashFile 返回文件内容的 SHA-256
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyFile 复制文件内容
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeBuildFiles 在 dir 中写入测试用的源文件
+func writeBuildFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestBuildSourcesImageHash 文章的摘要随引用的本地图片变化，未引用的图片和代码块中的图片不影响
+func TestBuildSourcesImageHash(t *testing.T) {
+	src := t.TempDir()
+	writeBuildFiles(t, src, map[string]string{
+		"posts/a.md":      "# A\n\n![图](img/a.png)\n\n```\n![代码](img/code.png)\n```\n",
+		"posts/img/a.png": "A1",
+	})
+	files, _, err := buildSources(src, filepath.Join(src, "dist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := files["posts/a.md"]
+
+	for _, tc := range []struct {
+		name    string
+		files   map[string]string
+		changed bool
+	}{
+		{"unrelated image", map[string]string{"posts/img/b.png": "B"}, false},
+		{"image in code block", map[string]string{"posts/img/code.png": "C"}, false},
+		{"referenced image", map[string]string{"posts/img/a.png": "A2"}, true},
+	} {
+		writeBuildFiles(t, src, tc.files)
+		files, _, err := buildSources(src, filepath.Join(src, "dist"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed := files["posts/a.md"] != base; changed != tc.changed {
+			t.Errorf("%s: article hash changed = %v, want %v", tc.name, changed, tc.changed)
+		}
+		base = files["posts/a.md"]
+	}
+
+	// 引用的图片被删除时同样变化
+	os.Remove(filepath.Join(src, "posts/img/a.png"))
+	if files, _, _ := buildSources(src, filepath.Join(src, "dist")); files["posts/a.md"] == base {
+		t.Error("removed image: article hash unchanged")
+	}
+}
+
+// TestBuildSourcesUnreadable 无法读取的文件记录为该文件的错误，其他文件照常返回
+func TestBuildSourcesUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read files without permission")
+	}
+	src := t.TempDir()
+	writeBuildFiles(t, src, map[string]string{"a.md": "# A\n", "b.md": "# B\n", "sub/c.md": "# C\n"})
+	os.Chmod(filepath.Join(src, "b.md"), 0)
+	os.Chmod(filepath.Join(src, "sub"), 0)
+	defer os.Chmod(filepath.Join(src, "sub"), 0755)
+
+	files, errs, err := buildSources(src, filepath.Join(src, "dist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["a.md"]; !ok || len(files) != 1 {
+		t.Errorf("files = %v, want only a.md", files)
+	}
+	if errs["b.md"] == nil || errs["sub"] == nil {
+		t.Errorf("errs = %v, want errors for b.md and sub", errs)
+	}
+}
+
+// TestBuildSourcesOutputCollision 输出路径相同的文件都记录为错误，不会互相覆盖
+func TestBuildSourcesOutputCollision(t *testing.T) {
+	src := t.TempDir()
+	writeBuildFiles(t, src, map[string]string{"p1/post.md": "# A\n", "p2/post.md": "# B\n", "p2/post.html": "<p>B</p>"})
+
+	files, errs, err := buildSources(src, filepath.Join(src, "dist"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["p1/post.md"]; !ok || len(files) != 1 {
+		t.Errorf("files = %v, want only p1/post.md", files)
+	}
+	for rel, other := range map[string]string{"p2/post.md": "p2/post.html", "p2/post.html": "p2/post.md"} {
+		if errs[rel] == nil || !strings.Contains(errs[rel].Error(), other) {
+			t.Errorf("%s: error %v, want a collision with %s", rel, errs[rel], other)
+		}
+	}
+
+	dst := t.TempDir()
+	cfg := &Config{ThemeDir: filepath.Join(src, "no-themes")}
+	if status := runBuild(cfg, []string{"-o", dst, src}); status != 1 {
+		t.Errorf("build exit status %d, want 1", status)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "p2/post.html")); err == nil {
+		t.Error("p2/post.html written despite the collision")
+	}
+}
+
+// TestBuildIndexCollision 源目录根部有 index.html 或 index.md 时不生成索引页覆盖它
+func TestBuildIndexCollision(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"generated", map[string]string{"a.md": "# A\n"}, "<a href=\"a.html\">"},
+		{"index.html", map[string]string{"a.md": "# A\n", "index.html": "<p>自定义首页</p>"}, "<p>自定义首页</p>"},
+		{"INDEX.HTML", map[string]string{"a.md": "# A\n", "INDEX.HTML": "<p>自定义首页</p>"}, ""},
+		{"index.md", map[string]string{"a.md": "# A\n", "index.md": "# 首页\n"}, "首页"},
+	} {
+		src, dst := t.TempDir(), t.TempDir()
+		writeBuildFiles(t, src, tc.files)
+		cfg := &Config{ThemeDir: filepath.Join(src, "no-themes")}
+		if status := runBuild(cfg, []string{"-o", dst, src}); status != 0 {
+			t.Fatalf("%s: build exit status %d", tc.name, status)
+		}
+		index, err := os.ReadFile(filepath.Join(dst, "index.html"))
+		if tc.want == "" {
+			// 大小写不敏感的文件系统上两者是同一个文件，否则不应生成
+			if err == nil && !strings.Contains(string(index), "自定义首页") {
+				t.Errorf("%s: generated index.html next to INDEX.HTML", tc.name)
+			}
+			continue
+		}
+		if err != nil || !strings.Contains(string(index), tc.want) {
+			t.Errorf("%s: index.html = %q, %v, want it to contain %q", tc.name, index, err, tc.want)
+		}
+	}
+}
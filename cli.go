@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// 退出码：0 成功，1 转换失败或检查发现问题，2 参数错误
+const usage = `usage: bilibili-uploader <command> [flags] [args]
+
+commands:
+  serve      start the HTTP server (default when no command is given)
+  watch      preview a Markdown file or directory with live reload
+  convert    convert a Markdown file or stdin to HTML, text or AST
+  build      convert a directory of articles into a static site
+  lint       report problems in Markdown files
+  fmt        format Markdown files
+  themes     list available themes
+  export     export a standalone HTML document
+  epub       export articles as an EPUB book
+  text       render plain text or a thread
+  import     convert published HTML or DOCX back to Markdown
+  publish    publish articles to the WeChat draft box
+
+Run "bilibili-uploader <command> -h" for the flags of a command.
+Exit status is 0 on success, 1 on failure or lint findings, 2 on usage errors.
+`
+
+// runCommand 执行子命令，返回进程退出码
+func runCommand(cfg *Config, args []string) int {
+	switch args[0] {
+	case "serve":
+		return runServe(cfg, args[1:])
+	case "watch":
+		return runWatch(cfg, args[1:])
+	case "convert":
+		return runConvert(cfg, args[1:])
+	case "build":
+		return runBuild(cfg, args[1:])
+	case "lint":
+		return runLint(cfg, args[1:])
+	case "themes":
+		return runThemes(cfg, args[1:])
+	case "publish":
+		return runPublish(cfg, args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "export":
+		return runExport(cfg, args[1:])
+	case "epub":
+		return runEPUB(cfg, args[1:])
+	case "text":
+		return runText(args[1:])
+	case "fmt":
+		return runFormat(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// runConvert 转换单个文件或标准输入（参数为空或 -），结果写到标准输出或 -o 指定的文件；
+// 转换警告输出到标准错误，不影响退出码
+func runConvert(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	output := fs.String("o", "", "output file (default: stdout)")
+	platform := fs.String("platform", "", "target platform: "+strings.Join(converter.Profiles(), ", "))
+	theme := fs.String("theme", "", "theme name (default: front matter theme, then the platform theme)")
+	links := fs.String("links", "", "link mode: footnote, inline or text (default: platform default)")
+	format := fs.String("format", "html", "output format: html, text or ast")
+	tableMode := fs.String("table-mode", "", "table output mode (default: platform default)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: convert [-o out] [-platform name] [-theme name] [-links mode] [-format html|text|ast] [article.md|-]")
+		return 2
+	}
+
+	name, src, err := readInput(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	req := ConvertRequest{
+		Markdown:  src,
+		Platform:  *platform,
+		Theme:     *theme,
+		Links:     *links,
+		Format:    *format,
+		TableMode: converter.TableMode(*tableMode),
+	}
+	if req.Theme == "" {
+		req.Theme = converter.Parse(src).FrontMatter.Get("theme")
+	}
+
+	var out string
+	switch req.Format {
+	case "", "html":
+		conv, _, err := newRequestConverter(cfg, req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return convertExitCode(cfg, req, *theme)
+		}
+		// 本地图片相对于文章所在目录解析，标准输入相对于当前目录
+		conv.SetImageOptions(converter.ImageOptions{BaseDir: filepath.Dir(name)})
+		out = conv.ConvertMarkdownToWechat(src)
+		for _, w := range conv.Warnings() {
+			fmt.Fprintf(os.Stderr, "%s warning: %s\n", warningPrefix(name, w), w.Message)
+		}
+	default:
+		resp, _, err := convertRequest(cfg, req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if resp.AST != nil {
+			data, _ := json.MarshalIndent(resp.AST, "", "  ")
+			out = string(data) + "\n"
+		} else {
+			out = resp.Text
+		}
+	}
+
+	if *output == "" {
+		fmt.Print(out)
+		return 0
+	}
+	if err := os.WriteFile(*output, []byte(out), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// convertExitCode 转换选项无效时的退出码：主题来自文章的 front matter（flagTheme 为空）
+// 且去掉该主题后其余参数有效时，错误在输入文件中，返回 1；否则为参数错误，返回 2
+func convertExitCode(cfg *Config, req ConvertRequest, flagTheme string) int {
+	if flagTheme != "" || req.Theme == "" {
+		return 2
+	}
+	req.Theme = ""
+	if _, _, err := newRequestConverter(cfg, req); err != nil {
+		return 2
+	}
+	return 1
+}
+
+// runLint 检查 Markdown 文件，按 file:line:column: severity: message [code] 输出到标准输出，
+// 有 info 以外的诊断时返回 1
+func runLint(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	platform := fs.String("platform", "", "target platform used to render the articles")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: lint [-platform name] files|dirs")
+		return 2
+	}
+	if _, ok := converter.GetProfile(*platform); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown platform: %s\n", *platform)
+		return 2
+	}
+
+	files, err := markdownFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		// 渲染一次以检查本地图片，不下载远程图片
+		conv, _, err := newRequestConverter(cfg, ConvertRequest{Platform: *platform})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		conv.SetImageOptions(converter.ImageOptions{BaseDir: filepath.Dir(file)})
+		conv.ConvertMarkdownToWechat(string(src))
+
+		for _, d := range conv.Diagnostics() {
+			w := converter.Warning{Line: d.Range.StartLine, Column: d.Range.StartColumn}
+			fmt.Printf("%s %s: %s [%s]\n", warningPrefix(file, w), d.Severity, d.Message, d.Code)
+			if d.Severity != converter.SeverityInfo {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// runThemes 列出主题目录中的主题
+func runThemes(cfg *Config, args []string) int {
+	if len(args) != 1 || args[0] != "list" {
+		fmt.Fprintln(os.Stderr, "usage: themes list")
+		return 2
+	}
+	themes, err := converter.ListThemes(cfg.ThemeDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, theme := range themes {
+		fmt.Println(theme)
+	}
+	return 0
+}
+
+// readInput 读取文件，参数为空或 - 时读取标准输入，返回用于提示和解析图片的文件名
+func readInput(arg string) (string, string, error) {
+	if arg == "" || arg == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		return "<stdin>", string(data), err
+	}
+	data, err := os.ReadFile(arg)
+	return arg, string(data), err
+}
+
+// warningPrefix 返回 file:line:column: 形式的位置，省略未知的行号和列号
+func warningPrefix(name string, w converter.Warning) string {
+	switch {
+	case w.Line > 0 && w.Column > 0:
+		return fmt.Sprintf("%s:%d:%d:", name, w.Line, w.Column)
+	case w.Line > 0:
+		return fmt.Sprintf("%s:%d:", name, w.Line)
+	}
+	return name + ":"
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRunConvertExitStatus 文章 front matter 中的主题无效时退出码为 1，命令行参数无效时为 2
+func TestRunConvertExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{ThemeDir: filepath.Join(dir, "missing")}
+	for name, content := range map[string]string{
+		"plain.md":     "# A\n",
+		"bad-theme.md": "---\ntheme: lapis\n---\n\n# A\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		args   []string
+		status int
+	}{
+		{"plain", []string{"plain.md"}, 0},
+		{"front matter theme", []string{"bad-theme.md"}, 1},
+		{"front matter theme overridden", []string{"-theme", "default", "bad-theme.md"}, 0},
+		{"theme flag", []string{"-theme", "lapis", "plain.md"}, 2},
+		{"platform flag", []string{"-platform", "myspace", "bad-theme.md"}, 2},
+		{"links flag", []string{"-links", "none", "plain.md"}, 2},
+		{"table mode flag", []string{"-table-mode", "grid", "bad-theme.md"}, 2},
+		{"format flag", []string{"-format", "pdf", "plain.md"}, 2},
+		{"missing file", []string{"missing.md"}, 1},
+	} {
+		args := []string{"-o", filepath.Join(dir, "out.html")}
+		for _, arg := range tc.args {
+			if filepath.Ext(arg) == ".md" {
+				arg = filepath.Join(dir, arg)
+			}
+			args = append(args, arg)
+		}
+		if status := runConvert(cfg, args); status != tc.status {
+			t.Errorf("%s: exit status %d, want %d", tc.name, status, tc.status)
+		}
+	}
+}
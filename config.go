@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config 服务配置，从 JSON 文件读取，环境变量优先
+type Config struct {
+	Port     string `json:"port"`
+	ThemeDir string `json:"theme_dir"`
+	// StaticDir 前端静态文件目录，导出文档时从中读取 MathJax
+	StaticDir string `json:"static_dir"`
+	// ImageHosts 接口导出时允许下载的远程图片主机，为空时允许所有公网地址
+	ImageHosts []string     `json:"image_hosts"`
+	Wechat     WechatConfig `json:"wechat"`
+}
+
+// WechatConfig 微信公众号接口配置
+type WechatConfig struct {
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"`
+	// BaseURL 接口地址，测试时可指向本地的模拟服务
+	BaseURL string `json:"base_url"`
+	// CacheDir 图片上传记录的缓存目录
+	CacheDir string `json:"cache_dir"`
+}
+
+// loadConfig 读取配置文件（CONFIG_FILE 指定，默认 config.json，不存在时忽略），再应用环境变量
+func loadConfig() (*Config, error) {
+	cfg := &Config{
+		Port:      "8080",
+		ThemeDir:  "web/static/themes",
+		StaticDir: "web/static",
+		Wechat: WechatConfig{
+			CacheDir: ".cache",
+		},
+	}
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		path = "config.json"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !(os.IsNotExist(err) && os.Getenv("CONFIG_FILE") == "") {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	}
+
+	overrideFromEnv(&cfg.Port, "PORT")
+	overrideFromEnv(&cfg.ThemeDir, "THEME_DIR")
+	overrideFromEnv(&cfg.StaticDir, "STATIC_DIR")
+	overrideFromEnv(&cfg.Wechat.AppID, "WECHAT_APP_ID")
+	overrideFromEnv(&cfg.Wechat.AppSecret, "WECHAT_APP_SECRET")
+	overrideFromEnv(&cfg.Wechat.BaseURL, "WECHAT_API_BASE")
+	overrideFromEnv(&cfg.Wechat.CacheDir, "WECHAT_CACHE_DIR")
+
+	return cfg, nil
+}
+
+func overrideFromEnv(field *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*field = v
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// exportInlineMaxBytes 导出单文件时内联的本地图片大小上限
+const exportInlineMaxBytes = 10 << 20
+
+// 接口导出时每个请求下载的远程图片数量和总大小上限
+const (
+	requestMaxImages     = 50
+	requestMaxImageBytes = 50 << 20
+)
+
+// requestFetcher 接口请求的远程图片下载限制：配置了 image_hosts 时只访问这些主机，否则只访问公网地址
+func requestFetcher(cfg *Config) *converter.ImageFetcher {
+	return &converter.ImageFetcher{
+		AllowHosts:    cfg.ImageHosts,
+		PublicOnly:    len(cfg.ImageHosts) == 0,
+		MaxImages:     requestMaxImages,
+		MaxTotalBytes: requestMaxImageBytes,
+	}
+}
+
+// ExportRequest 独立 HTML 文档导出请求
+type ExportRequest struct {
+	ConvertRequest
+	// Title 文档标题，为空时使用 front matter 的 title 或第一个标题
+	Title string `json:"title,omitempty"`
+	// Preview 使用手机宽度的预览外框
+	Preview bool `json:"preview,omitempty"`
+	// Inline 内联远程图片和 MathJax，生成可离线打开的单个文件
+	Inline bool `json:"inline,omitempty"`
+	// mathJaxURL 本地预览时引用的 MathJax 地址，不从请求中读取
+	mathJaxURL string
+	// blockCache 反复转换同一篇文章时使用的块缓存，不从请求中读取
+	blockCache *converter.BlockCache
+	// fetcher 远程图片的下载限制，为空时不限制，不从请求中读取
+	fetcher *converter.ImageFetcher
+	// sourceLines 在块级元素上添加 data-line 源码行号，用于本地预览保持滚动位置，不从请求中读取
+	sourceLines bool
+}
+
+// exportDocument 按请求生成独立 HTML 文档，imageOpts 指定本地图片的解析方式
+func exportDocument(cfg *Config, req ExportRequest, imageOpts converter.ImageOptions) (string, []converter.Warning, int, error) {
+	conv, status, err := newRequestConverter(cfg, req.ConvertRequest)
+	if err != nil {
+		return "", nil, status, err
+	}
+	conv.SetImageOptions(imageOpts)
+	conv.SetBlockCache(req.blockCache)
+	conv.SetImageFetcher(req.fetcher)
+	conv.SetSourceLines(req.sourceLines)
+
+	theme := req.Theme
+	if theme == "" {
+		profile, _ := converter.GetProfile(req.Platform)
+		theme = profile.DefaultTheme
+	}
+	css, err := converter.ReadThemeCSS(cfg.ThemeDir, theme)
+	if err != nil {
+		return "", nil, http.StatusInternalServerError, err
+	}
+
+	opts := converter.DocumentOptions{
+		Title:        req.Title,
+		ThemeCSS:     css,
+		Preview:      req.Preview,
+		InlineImages: req.Inline,
+		MathJaxURL:   req.mathJaxURL,
+	}
+	if req.Inline {
+		// 本地没有 MathJax 时仍引用 CDN 地址
+		if script, err := os.ReadFile(filepath.Join(cfg.StaticDir, "mathjax", "tex-svg-full.min.js")); err == nil {
+			opts.MathJaxScript = string(script)
+		}
+	}
+
+	doc := conv.ConvertToDocument(req.Markdown, opts)
+	return doc, conv.Warnings(), http.StatusOK, nil
+}
+
+// handleExportHTML 处理 /api/export/html：参数与 /api/convert 相同，另有 theme、title、preview、inline，返回完整的 HTML 文档
+func handleExportHTML(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req ExportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		req.fetcher = requestFetcher(cfg)
+		doc, _, status, err := exportDocument(cfg, req, converter.ImageOptions{})
+		if err != nil {
+			sendErrorResponse(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if r.URL.Query().Get("download") != "" {
+			w.Header().Set("Content-Disposition", `attachment; filename="article.html"`)
+		}
+		w.Write([]byte(doc))
+	}
+}
+
+// runExport 命令行导出：export [-o article.html] [-platform name] [-theme name] [-preview] [-inline] article.md
+func runExport(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "output HTML file (default: stdout)")
+	platform := fs.String("platform", "", "target platform profile (default: wechat)")
+	theme := fs.String("theme", "", "theme name (default: front matter theme or platform default)")
+	title := fs.String("title", "", "document title (default: front matter title or first heading)")
+	preview := fs.Bool("preview", false, "wrap the article in a phone-width preview frame")
+	inline := fs.Bool("inline", false, "inline images and MathJax into a single self-contained file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: export [-o article.html] [-platform name] [-theme name] [-title text] [-preview] [-inline] article.md")
+		return 2
+	}
+
+	markdown, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	req := ExportRequest{
+		ConvertRequest: ConvertRequest{Markdown: string(markdown), Platform: *platform, Theme: *theme},
+		Title:          *title,
+		Preview:        *preview,
+		Inline:         *inline,
+	}
+	if req.Theme == "" {
+		fm, _ := converter.ParseFrontMatter(req.Markdown)
+		req.Theme = fm.Get("theme")
+	}
+	imageOpts := converter.ImageOptions{BaseDir: filepath.Dir(fs.Arg(0))}
+	if *inline {
+		imageOpts.InlineMaxBytes = exportInlineMaxBytes
+	} else if *output != "" {
+		// 本地图片链接改为相对于输出文件所在目录
+		if rel, err := filepath.Rel(filepath.Dir(*output), imageOpts.BaseDir); err == nil && rel != "." {
+			imageOpts.BaseURL = filepath.ToSlash(rel)
+		}
+	}
+
+	doc, warnings, _, err := exportDocument(cfg, req, imageOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "%s:%d: warning: %s\n", fs.Arg(0), w.Line, w.Message)
+	}
+
+	if *output == "" {
+		fmt.Print(doc)
+		return 0
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(*output, []byte(doc), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// EPUBRequest 电子书导出请求：markdown 为单篇文章，articles 为按顺序排列的系列文章
+type EPUBRequest struct {
+	ConvertRequest
+	Articles    []string            `json:"articles,omitempty"`
+	Title       string              `json:"title,omitempty"`
+	Author      string              `json:"author,omitempty"`
+	Language    string              `json:"language,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Split       converter.EPUBSplit `json:"split,omitempty"`
+	// fetcher 远程图片的下载限制，为空时不限制，不从请求中读取
+	fetcher *converter.ImageFetcher
+}
+
+// buildEPUB 按请求生成电子书，articles 的图片选项由调用方指定
+func buildEPUB(cfg *Config, req EPUBRequest, articles []converter.EPUBArticle) ([]byte, []converter.EPUBWarning, int, error) {
+	conv, status, err := newRequestConverter(cfg, req.ConvertRequest)
+	if err != nil {
+		return nil, nil, status, err
+	}
+	conv.SetImageFetcher(req.fetcher)
+
+	theme := req.Theme
+	if theme == "" {
+		profile, _ := converter.GetProfile(req.Platform)
+		theme = profile.DefaultTheme
+	}
+	css, err := converter.ReadThemeCSS(cfg.ThemeDir, theme)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	var buf bytes.Buffer
+	warnings, err := conv.WriteEPUB(&buf, articles, converter.EPUBOptions{
+		Title:       req.Title,
+		Author:      req.Author,
+		Language:    req.Language,
+		Description: req.Description,
+		ThemeCSS:    css,
+		Split:       req.Split,
+	})
+	if err != nil {
+		// 写入内存不会失败，错误都来自请求参数
+		return nil, nil, http.StatusBadRequest, err
+	}
+	return buf.Bytes(), warnings, http.StatusOK, nil
+}
+
+// handleExportEPUB 处理 /api/export/epub：返回 EPUB 文件，图片需为远程地址或 data URI
+func handleExportEPUB(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req EPUBRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		var articles []converter.EPUBArticle
+		if req.Markdown != "" {
+			articles = append(articles, converter.EPUBArticle{Markdown: req.Markdown})
+		}
+		for _, markdown := range req.Articles {
+			articles = append(articles, converter.EPUBArticle{Markdown: markdown})
+		}
+
+		req.fetcher = requestFetcher(cfg)
+		book, warnings, status, err := buildEPUB(cfg, req, articles)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="book.epub"`)
+		w.Header().Set("X-Conversion-Warnings", strconv.Itoa(len(warnings)))
+		w.Write(book)
+	}
+}
+
+// runEPUB 命令行导出电子书：epub [-o book.epub] [-title text] [-author name] [-split article|h1] [-theme name] dir|article.md ...
+// 目录中的 .md 文件按文件名排序，每篇文章的相对路径图片相对于文章所在目录
+func runEPUB(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("epub", flag.ContinueOnError)
+	output := fs.String("o", "book.epub", "output EPUB file")
+	title := fs.String("title", "", "book title (default: front matter series, or the title of a single article)")
+	author := fs.String("author", "", "book author (default: front matter author of the first article)")
+	lang := fs.String("lang", "", "book language (default: front matter lang or zh-CN)")
+	split := fs.String("split", string(converter.EPUBSplitArticle), "chapter split: article or h1")
+	theme := fs.String("theme", "", "theme name (default: built-in default)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: epub [-o book.epub] [-title text] [-author name] [-lang code] [-split article|h1] [-theme name] dir|article.md ...")
+		return 2
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.md"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no Markdown files found")
+		return 1
+	}
+
+	articles := make([]converter.EPUBArticle, 0, len(files))
+	for _, file := range files {
+		markdown, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		articles = append(articles, converter.EPUBArticle{
+			Markdown: string(markdown),
+			Images:   converter.ImageOptions{BaseDir: filepath.Dir(file)},
+		})
+	}
+
+	req := EPUBRequest{
+		ConvertRequest: ConvertRequest{Theme: *theme},
+		Title:          *title,
+		Author:         *author,
+		Language:       *lang,
+		Split:          converter.EPUBSplit(*split),
+	}
+	book, warnings, _, err := buildEPUB(cfg, req, articles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, w := range warnings {
+		if w.Line == 0 {
+			// 嵌入图片时的警告没有行号
+			fmt.Fprintf(os.Stderr, "%s: warning: %s\n", files[w.Article], w.Message)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "%s:%d: warning: %s\n", files[w.Article], w.Line, w.Message)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(*output, book, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// runText 命令行输出纯文本：text [-thread limit] [-numbered] article.md，分段之间以一行 --- 分隔
+func runText(args []string) int {
+	fs := flag.NewFlagSet("text", flag.ContinueOnError)
+	limit := fs.Int("thread", 0, "split the text into posts of at most this many characters")
+	numbered := fs.Bool("numbered", false, "append (i/n) to every post of a thread")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: text [-thread limit] [-numbered] article.md")
+		return 2
+	}
+
+	markdown, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	text := converter.RenderText(string(markdown))
+	if *limit <= 0 {
+		fmt.Print(text)
+		return 0
+	}
+	posts := converter.SplitThread(text, converter.ThreadOptions{Limit: *limit, Numbered: *numbered})
+	fmt.Println(strings.Join(posts, "\n---\n"))
+	return 0
+}
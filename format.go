@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// FormatRequest Markdown 格式化请求，格式化约定与 converter.FormatOptions 相同
+type FormatRequest struct {
+	Markdown string `json:"markdown"`
+	converter.FormatOptions
+}
+
+// FormatResponse 格式化响应
+type FormatResponse struct {
+	Markdown string `json:"markdown"`
+	// Changed 格式化结果与原文不同
+	Changed bool   `json:"changed"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// handleFormat 处理 /api/format；格式化会改变渲染结果时返回 422 和原文
+func handleFormat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 请求中省略的选项使用默认约定
+	req := FormatRequest{FormatOptions: converter.DefaultFormatOptions()}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendFormatResponse(w, FormatResponse{Error: "Invalid JSON"}, http.StatusBadRequest)
+		return
+	}
+
+	formatted, err := converter.FormatMarkdown(req.Markdown, req.FormatOptions)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, converter.ErrFormatChangesOutput) {
+			status = http.StatusUnprocessableEntity
+		}
+		sendFormatResponse(w, FormatResponse{Markdown: req.Markdown, Error: err.Error()}, status)
+		return
+	}
+	sendFormatResponse(w, FormatResponse{Markdown: formatted, Changed: formatted != req.Markdown, Success: true}, http.StatusOK)
+}
+
+func sendFormatResponse(w http.ResponseWriter, resp FormatResponse, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// runFormat 格式化 Markdown 文件：默认输出到标准输出，-w 写回文件，
+// -check 只列出需要格式化的文件，有这样的文件时返回 1
+func runFormat(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs and exit 1 if there are any")
+	write := flags.Bool("w", false, "write the result back to the source files")
+	opts := converter.DefaultFormatOptions()
+	flags.StringVar(&opts.Bullet, "bullet", opts.Bullet, "bullet for unordered lists: -, * or +")
+	flags.StringVar(&opts.Ordered, "ordered", opts.Ordered, "numbering of ordered lists: sequential or one")
+	flags.IntVar(&opts.ListIndent, "indent", opts.ListIndent, "spaces to indent nested lists (1-8)")
+	flags.BoolVar(&opts.CompactTables, "compact-tables", false, "do not pad table columns to the same width")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: fmt [-check] [-w] [-bullet -] [-ordered sequential|one] [-indent 2] [-compact-tables] files|dirs")
+		return 2
+	}
+
+	files, err := markdownFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		formatted, err := converter.FormatMarkdown(string(src), opts)
+		switch {
+		case errors.Is(err, converter.ErrFormatChangesOutput):
+			// 无法安全格式化的文件保持原样，不视为失败
+			fmt.Fprintf(os.Stderr, "%s: skipped: %v\n", file, err)
+			continue
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+
+		switch {
+		case *check:
+			if formatted != string(src) {
+				fmt.Println(file)
+				status = 1
+			}
+		case *write:
+			if formatted == string(src) {
+				continue
+			}
+			info, err := os.Stat(file)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			if err := os.WriteFile(file, []byte(formatted), info.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
+
+// markdownFiles 展开参数中的目录，递归收集其中的 .md 文件
+func markdownFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(path), ".md") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
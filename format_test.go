@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHandleFormatOptions 省略的选项使用默认约定，显式传入的无效选项（包括 list_indent 为 0）返回 400
+func TestHandleFormatOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		status   int
+		markdown string
+	}{
+		{"defaults", `{"markdown":"* a\n    * b\n"}`, http.StatusOK, "- a\n  - b\n"},
+		{"options", `{"markdown":"- a\n  - b\n","bullet":"*","list_indent":4}`, http.StatusOK, "* a\n    * b\n"},
+		{"zero indent", `{"markdown":"- a\n","list_indent":0}`, http.StatusBadRequest, "- a\n"},
+		{"empty bullet", `{"markdown":"* a\n","bullet":""}`, http.StatusOK, "- a\n"},
+		{"invalid JSON", `{"markdown":`, http.StatusBadRequest, ""},
+	} {
+		rec := httptest.NewRecorder()
+		handleFormat(rec, httptest.NewRequest(http.MethodPost, "/api/format", strings.NewReader(tc.body)))
+		var resp FormatResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != tc.status || resp.Markdown != tc.markdown {
+			t.Errorf("%s: %d %+v, want %d markdown %q", tc.name, rec.Code, resp, tc.status, tc.markdown)
+		}
+	}
+}
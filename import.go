@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// maxImportBytes 导入 HTML 的大小上限
+const maxImportBytes = 10 << 20
+
+// maxDOCXUploadBytes 上传 DOCX 的大小上限
+const maxDOCXUploadBytes = 32 << 20
+
+// docxInlineMaxBytes 转换 DOCX 时内联为 data URI 的图片大小上限
+const docxInlineMaxBytes = 5 << 20
+
+// ImportRequest HTML 导入请求，也可以直接以 text/html 提交 HTML
+type ImportRequest struct {
+	HTML string `json:"html"`
+}
+
+// ImportResponse 导入响应
+type ImportResponse struct {
+	*converter.ImportResult
+	// HTML、Stats 为 DOCX 导入后直接转换的结果
+	HTML    string           `json:"html,omitempty"`
+	Stats   *converter.Stats `json:"stats,omitempty"`
+	Success bool             `json:"success"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// handleImport 处理 /api/import
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		sendImportResponse(w, ImportResponse{Error: "Request body too large"}, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	src := string(body)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/html" {
+		var req ImportRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			sendImportResponse(w, ImportResponse{Error: "Invalid JSON"}, http.StatusBadRequest)
+			return
+		}
+		src = req.HTML
+	}
+
+	result, err := converter.ImportHTML(src)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, converter.ErrNoContent) {
+			status = http.StatusUnprocessableEntity
+		}
+		sendImportResponse(w, ImportResponse{Error: err.Error()}, status)
+		return
+	}
+
+	sendImportResponse(w, ImportResponse{ImportResult: result, Success: true}, http.StatusOK)
+}
+
+// handleImportDOCX 处理 /api/import/docx：multipart 表单的 file 字段为 Word 文档，
+// platform、table_mode 字段与 /api/convert 相同；返回 Markdown 和转换后的 HTML，嵌入图片内联为 data URI
+func handleImportDOCX(cfg *Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxDOCXUploadBytes)
+		if err := r.ParseMultipartForm(maxDOCXUploadBytes); err != nil {
+			sendImportResponse(w, ImportResponse{Error: "Invalid multipart form: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			sendImportResponse(w, ImportResponse{Error: "Missing file field"}, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		// DOCX 中的合并单元格导入为 "<"、"^" 标记
+		conv, status, err := newRequestConverter(cfg, ConvertRequest{
+			Platform:   r.FormValue("platform"),
+			TableMode:  converter.TableMode(r.FormValue("table_mode")),
+			TableSpans: true,
+		})
+		if err != nil {
+			sendImportResponse(w, ImportResponse{Error: err.Error()}, status)
+			return
+		}
+
+		result, err := converter.ImportDOCX(file, header.Size)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, converter.ErrInvalidDOCX) || errors.Is(err, converter.ErrNoContent) {
+				status = http.StatusUnprocessableEntity
+			}
+			sendImportResponse(w, ImportResponse{Error: err.Error()}, status)
+			return
+		}
+
+		conv.SetImageOptions(converter.ImageOptions{FS: result.Media, InlineMaxBytes: docxInlineMaxBytes})
+		html := conv.ConvertMarkdownToWechat(result.Markdown)
+		stats := conv.Stats()
+		for _, warning := range conv.Warnings() {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("line %d: %s", warning.Line, warning.Message))
+		}
+
+		sendImportResponse(w, ImportResponse{ImportResult: result, HTML: html, Stats: &stats, Success: true}, http.StatusOK)
+	}
+}
+
+func sendImportResponse(w http.ResponseWriter, resp ImportResponse, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(resp)
+}
+
+// runImport 命令行导入：import [-o article.md] [-images dir] article.html|article.docx
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	output := fs.String("o", "", "output Markdown file (default: stdout)")
+	images := fs.String("images", "", "HTML only: download images into this directory and link them relative to the output file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import [-o article.md] [-images dir] article.html|article.docx")
+		return 2
+	}
+
+	src, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	var result *converter.ImportResult
+	if strings.EqualFold(filepath.Ext(fs.Arg(0)), ".docx") {
+		result, err = converter.ImportDOCX(bytes.NewReader(src), int64(len(src)))
+	} else {
+		result, err = converter.ImportHTML(string(src))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", fs.Arg(0), err)
+		return 1
+	}
+
+	if result.Media != nil {
+		// 嵌入图片保存在输出文件旁边，保持 Markdown 中的 media/ 相对路径
+		dir := "."
+		if *output != "" {
+			dir = filepath.Dir(*output)
+		}
+		if err := result.SaveMedia(dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	} else if *images != "" {
+		// 图片链接相对于输出文件所在目录
+		prefix := filepath.ToSlash(*images) + "/"
+		if *output != "" {
+			if rel, err := filepath.Rel(filepath.Dir(*output), *images); err == nil {
+				prefix = filepath.ToSlash(rel) + "/"
+			}
+		}
+		if err := result.SaveImages(*images, prefix); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "%s: warning: %s\n", fs.Arg(0), w)
+	}
+
+	if *output == "" {
+		fmt.Print(result.Markdown)
+		return 0
+	}
+	if err := os.MkdirAll(filepath.Dir(*output), 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(*output, []byte(result.Markdown), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
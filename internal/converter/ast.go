@@ -0,0 +1,697 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ASTVersion JSON 语法树的版本，节点结构有不兼容的变化时递增
+const ASTVersion = 1
+
+// ErrASTVersion 语法树版本不受支持
+var ErrASTVersion = errors.New("unsupported AST version")
+
+// NodeType 语法树节点类型
+type NodeType string
+
+// 块级节点
+const (
+	// NodeHeading 标题，attrs: level
+	NodeHeading NodeType = "heading"
+	// NodeParagraph 段落，多行之间以 softbreak 分隔
+	NodeParagraph NodeType = "paragraph"
+	// NodeCodeBlock 代码块，text 为代码，attrs: lang、indent（围栏的缩进空格数）
+	NodeCodeBlock NodeType = "code_block"
+	// NodeMathBlock 独占段落的 $$...$$ 公式，text 为 TeX
+	NodeMathBlock NodeType = "math_block"
+	// NodeQuote 引用，子节点为块级节点
+	NodeQuote NodeType = "quote"
+	// NodeList 列表，attrs: ordered（"true"/"false"）
+	NodeList NodeType = "list"
+	// NodeListItem 列表项，子节点为行内节点，嵌套列表在最后；attrs: marker（-、*、+、•、1.），checked（任务列表）
+	NodeListItem NodeType = "list_item"
+	// NodeTable 表格，第一行为表头
+	NodeTable NodeType = "table"
+	// NodeTableRow 表格行，attrs: header（表头行为 "true"）
+	NodeTableRow NodeType = "table_row"
+	// NodeTableCell 单元格，attrs: align（left、center、right）
+	NodeTableCell NodeType = "table_cell"
+	// NodeThematicBreak 分隔线，text 为原文
+	NodeThematicBreak NodeType = "thematic_break"
+	// NodeFootnoteDef 脚注定义，attrs: id
+	NodeFootnoteDef NodeType = "footnote_def"
+)
+
+// 行内节点
+const (
+	// NodeText 文本，text 为原文
+	NodeText NodeType = "text"
+	// NodeSoftBreak 段落内的换行
+	NodeSoftBreak NodeType = "softbreak"
+	// NodeStrong 粗体
+	NodeStrong NodeType = "strong"
+	// NodeEmphasis 斜体
+	NodeEmphasis NodeType = "emphasis"
+	// NodeCode 行内代码，text 为代码
+	NodeCode NodeType = "code"
+	// NodeLink 链接，子节点为链接文字；attrs: href
+	NodeLink NodeType = "link"
+	// NodeImage 图片，attrs: src、alt、title（可选）
+	NodeImage NodeType = "image"
+	// NodeMath 行内公式，text 为 TeX；attrs: display（$$...$$ 为 "true"）
+	NodeMath NodeType = "math"
+	// NodeFootnoteRef 脚注引用，attrs: id
+	NodeFootnoteRef NodeType = "footnote_ref"
+	// NodeHTML 行内 HTML 标签，text 为原文
+	NodeHTML NodeType = "html"
+)
+
+// ASTDocument JSON 语法树的根节点
+type ASTDocument struct {
+	Version     int         `json:"version"`
+	FrontMatter FrontMatter `json:"front_matter,omitempty"`
+	Children    []*ASTNode  `json:"children"`
+}
+
+// ASTNode 语法树节点，各类型使用的 attrs 和 text 见 NodeType 的说明
+type ASTNode struct {
+	Type     NodeType          `json:"type"`
+	Attrs    map[string]string `json:"attrs,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	Children []*ASTNode        `json:"children,omitempty"`
+	Pos      *Position         `json:"pos,omitempty"`
+}
+
+// Position 节点在原文中的位置，行列从 1 开始，列按字符计；
+// 块级节点只有行号，行内节点的 EndColumn 为最后一个字符之后的列
+type Position struct {
+	StartLine   int `json:"start_line"`
+	StartColumn int `json:"start_column,omitempty"`
+	EndLine     int `json:"end_line"`
+	EndColumn   int `json:"end_column,omitempty"`
+}
+
+var (
+	astImageRegex  = regexp.MustCompile(`^!\[([^\]]*)\]\(([^)]+)\)`)
+	astLinkRegex   = regexp.MustCompile(`^\[([^\]]+)\]\(([^)]+)\)`)
+	astNoteRegex   = regexp.MustCompile(`^\[\^([^\]\s]+)\]`)
+	astStrongRegex = regexp.MustCompile(`^\*\*([^*]+)\*\*`)
+	astEmRegex     = regexp.MustCompile(`^\*([^*]+)\*`)
+	astTagRegex    = regexp.MustCompile(`^</?[a-zA-Z][^>]*>`)
+	astDefRegex    = regexp.MustCompile(`^\[\^([^\]\s]+)\]:[ \t]*`)
+	astMathBlock   = regexp.MustCompile(`(?s)^\$\$(.+?)\$\$$`)
+	listItemRegex  = regexp.MustCompile(`^(?:([-*+]) (?:\[([ xX])\] )?|(•)\s*|(\d+\.)\s*)`)
+)
+
+// BuildAST 解析 Markdown，返回带源码位置的语法树
+func BuildAST(markdown string) *ASTDocument {
+	fm, _ := parseFrontMatter(markdown)
+	lines := strings.Split(blankFrontMatter(markdown), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	doc := &ASTDocument{Version: ASTVersion, Children: buildBlocks(lines, make([]int, len(lines)), 0)}
+	if len(fm) > 0 {
+		doc.FrontMatter = fm
+	}
+	return doc
+}
+
+// buildBlocks 解析一组行，lineOffset 为第一行之前的行数，cols 为每行内容在原文中的起始列减一
+func buildBlocks(lines []string, cols []int, lineOffset int) []*ASTNode {
+	var nodes []*ASTNode
+	for _, block := range Parse(strings.Join(lines, "\n")).Blocks {
+		start, end := block.StartLine, block.EndLine
+		pos := &Position{StartLine: start + lineOffset, EndLine: end + lineOffset}
+
+		switch block.Type {
+		case BlockHeading:
+			raw := strings.TrimRight(lines[start-1], " \t")
+			content := block.Lines[0]
+			col := cols[start-1] + utf8.RuneCountInString(raw[:len(raw)-len(content)]) + 1
+			nodes = append(nodes, &ASTNode{
+				Type:     NodeHeading,
+				Attrs:    map[string]string{"level": strconv.Itoa(block.Level)},
+				Children: parseInline(content, pos.StartLine, col),
+				Pos:      pos,
+			})
+
+		case BlockCode:
+			node := &ASTNode{Type: NodeCodeBlock, Text: strings.Join(block.Lines, "\n"), Attrs: map[string]string{}, Pos: pos}
+			if block.Lang != "" {
+				node.Attrs["lang"] = block.Lang
+			}
+			// 列表项中缩进的代码块，代码行保留原有缩进
+			if indent := len(lines[start-1]) - len(strings.TrimLeft(lines[start-1], " ")); indent > 0 {
+				node.Attrs["indent"] = strconv.Itoa(indent)
+			}
+			if len(node.Attrs) == 0 {
+				node.Attrs = nil
+			}
+			nodes = append(nodes, node)
+
+		case BlockQuote:
+			inner := make([]string, 0, end-start+1)
+			innerCols := make([]int, 0, end-start+1)
+			for i := start - 1; i < end; i++ {
+				raw := lines[i]
+				content := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), ">"))
+				inner = append(inner, content)
+				innerCols = append(innerCols, cols[i]+utf8.RuneCountInString(raw[:contentOffset(raw, content)]))
+			}
+			nodes = append(nodes, &ASTNode{Type: NodeQuote, Children: buildBlocks(inner, innerCols, pos.StartLine-1), Pos: pos})
+
+		case BlockList:
+			nodes = append(nodes, buildList(lines[start-1:end], cols[start-1:end], pos))
+
+		case BlockTable:
+			nodes = append(nodes, buildTable(lines, cols, block, pos))
+
+		default:
+			nodes = append(nodes, buildParagraphs(lines, cols, block, lineOffset)...)
+		}
+	}
+	return nodes
+}
+
+// contentOffset 返回 content 在行中的字节位置，content 为行的后缀（忽略行尾空白）
+func contentOffset(raw, content string) int {
+	trimmed := strings.TrimRight(raw, " \t")
+	if content == "" {
+		return len(trimmed)
+	}
+	return len(trimmed) - len(content)
+}
+
+// buildParagraphs 解析段落块：分隔线、独占段落的公式和脚注定义单独成节点，其余连续的行合并为段落
+func buildParagraphs(lines []string, cols []int, block *Block, lineOffset int) []*ASTNode {
+	var nodes []*ASTNode
+	text := strings.Join(block.Lines, "\n")
+	pos := &Position{StartLine: block.StartLine + lineOffset, EndLine: block.EndLine + lineOffset}
+
+	if len(block.Lines) == 1 && thematicRegex.MatchString(block.Lines[0]) {
+		return []*ASTNode{{Type: NodeThematicBreak, Text: block.Lines[0], Pos: pos}}
+	}
+	if m := astMathBlock.FindStringSubmatch(text); m != nil && !strings.Contains(m[1], "$$") {
+		return []*ASTNode{{Type: NodeMathBlock, Text: strings.TrimSpace(m[1]), Pos: pos}}
+	}
+
+	var para *ASTNode
+	for i, content := range block.Lines {
+		n := block.StartLine - 1 + i
+		line := n + 1 + lineOffset
+		col := cols[n] + utf8.RuneCountInString(lines[n][:contentOffset(lines[n], content)]) + 1
+		linePos := &Position{StartLine: line, EndLine: line}
+
+		if m := astDefRegex.FindString(content); m != "" {
+			para = nil
+			nodes = append(nodes, &ASTNode{
+				Type:     NodeFootnoteDef,
+				Attrs:    map[string]string{"id": astDefRegex.FindStringSubmatch(content)[1]},
+				Children: parseInline(content[len(m):], line, col+utf8.RuneCountInString(m)),
+				Pos:      linePos,
+			})
+			continue
+		}
+		if para == nil {
+			para = &ASTNode{Type: NodeParagraph, Pos: linePos}
+			nodes = append(nodes, para)
+		} else {
+			para.Children = append(para.Children, &ASTNode{Type: NodeSoftBreak})
+			para.Pos.EndLine = line
+		}
+		para.Children = append(para.Children, parseInline(content, line, col)...)
+	}
+	return nodes
+}
+
+// buildList 按缩进解析嵌套列表
+func buildList(lines []string, cols []int, pos *Position) *ASTNode {
+	type level struct {
+		indent int
+		list   *ASTNode
+	}
+	root := &ASTNode{Type: NodeList, Pos: pos}
+	var stack []level
+
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			continue
+		}
+		line := pos.StartLine + i
+		col := cols[i] + utf8.RuneCountInString(raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]) + 1
+		// 兼容 "1.\n\n内容" 形式的编号列表，内容位置取下一行
+		content, contentLine, contentCol := "", line, col
+		if orderedItemOnlyRegex.MatchString(trimmed) {
+			for j := i + 1; j < len(lines); j++ {
+				if t := strings.TrimSpace(lines[j]); t != "" {
+					content = t
+					contentLine = pos.StartLine + j
+					contentCol = cols[j] + utf8.RuneCountInString(lines[j][:len(lines[j])-len(strings.TrimLeft(lines[j], " \t"))]) + 1
+					i = j
+					break
+				}
+			}
+		}
+
+		m := listItemRegex.FindStringSubmatch(trimmed)
+		item := &ASTNode{Type: NodeListItem, Attrs: map[string]string{}, Pos: &Position{StartLine: line, EndLine: contentLine}}
+		ordered := m[4] != ""
+		switch {
+		case m[1] != "":
+			item.Attrs["marker"] = m[1]
+			if m[2] != "" {
+				item.Attrs["checked"] = strconv.FormatBool(m[2] != " ")
+			}
+		case m[3] != "":
+			item.Attrs["marker"] = m[3]
+		default:
+			item.Attrs["marker"] = m[4]
+		}
+		if content == "" {
+			content = trimmed[len(m[0]):]
+			contentCol = col + utf8.RuneCountInString(m[0])
+		}
+		item.Children = parseInline(content, contentLine, contentCol)
+
+		indent := leadingWidth(raw)
+		for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+			stack = stack[:len(stack)-1]
+		}
+		switch {
+		case len(stack) == 0:
+			root.Attrs = map[string]string{"ordered": strconv.FormatBool(ordered)}
+			stack = append(stack, level{indent: indent, list: root})
+		case stack[len(stack)-1].indent < indent:
+			// 缩进更深的项属于上一项的子列表
+			parent := stack[len(stack)-1].list
+			last := parent.Children[len(parent.Children)-1]
+			sub := &ASTNode{Type: NodeList, Attrs: map[string]string{"ordered": strconv.FormatBool(ordered)}, Pos: &Position{StartLine: line, EndLine: line}}
+			last.Children = append(last.Children, sub)
+			stack = append(stack, level{indent: indent, list: sub})
+		}
+		list := stack[len(stack)-1].list
+		list.Children = append(list.Children, item)
+		// 子列表的结束行随最后一项更新
+		for _, l := range stack {
+			l.list.Pos.EndLine = contentLine
+		}
+	}
+	return root
+}
+
+// buildTable 解析表格，单元格中的 \| 还原为竖线
+func buildTable(lines []string, cols []int, block *Block, pos *Position) *ASTNode {
+	aligns := parseTableAligns(block.Lines[1])
+	node := &ASTNode{Type: NodeTable, Pos: pos}
+	for i, text := range block.Lines {
+		if i == 1 {
+			continue
+		}
+		n := block.StartLine - 1 + i
+		raw := lines[n]
+		line := pos.StartLine + i
+		row := &ASTNode{Type: NodeTableRow, Pos: &Position{StartLine: line, EndLine: line}}
+		if i == 0 {
+			row.Attrs = map[string]string{"header": "true"}
+		}
+		cursor := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		for j, cell := range splitTableRow(text) {
+			cell := cell
+			// 单元格位置按原文顺序查找，含转义竖线的单元格取近似位置
+			if k := strings.Index(raw[cursor:], cell); k >= 0 && !strings.Contains(cell, "|") {
+				cursor += k
+			}
+			col := cols[n] + utf8.RuneCountInString(raw[:cursor]) + 1
+			c := &ASTNode{Type: NodeTableCell, Children: parseInline(cell, line, col)}
+			if j < len(aligns) && aligns[j] != "" {
+				c.Attrs = map[string]string{"align": aligns[j]}
+			}
+			row.Children = append(row.Children, c)
+			cursor = min(cursor+len(cell), len(raw))
+		}
+		node.Children = append(node.Children, row)
+	}
+	return node
+}
+
+// parseInline 解析行内元素，col 为 s 第一个字符所在的列；
+// 规则与转换器一致，节点按顺序输出后与原文相同
+func parseInline(s string, line, col int) []*ASTNode {
+	var nodes []*ASTNode
+	var text strings.Builder
+	textCol := col
+
+	at := func(offset int) int {
+		return col + utf8.RuneCountInString(s[:offset])
+	}
+	flush := func(offset int) {
+		if text.Len() > 0 {
+			nodes = append(nodes, &ASTNode{Type: NodeText, Text: text.String(), Pos: &Position{StartLine: line, StartColumn: textCol, EndLine: line, EndColumn: at(offset)}})
+			text.Reset()
+		}
+	}
+	emit := func(start, end int, node *ASTNode) {
+		flush(start)
+		node.Pos = &Position{StartLine: line, StartColumn: at(start), EndLine: line, EndColumn: at(end)}
+		nodes = append(nodes, node)
+		textCol = at(end)
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		switch rest[0] {
+		case '`':
+			if end := strings.IndexByte(rest[1:], '`'); end > 0 {
+				emit(i, i+end+2, &ASTNode{Type: NodeCode, Text: rest[1 : end+1]})
+				i += end + 2
+				continue
+			}
+		case '$':
+			if strings.HasPrefix(rest, "$$") {
+				if end := strings.Index(rest[2:], "$$"); end > 0 {
+					emit(i, i+end+4, &ASTNode{Type: NodeMath, Text: rest[2 : end+2], Attrs: map[string]string{"display": "true"}})
+					i += end + 4
+					continue
+				}
+			} else if end := inlineMathEnd(s, i); end > 0 {
+				emit(i, end+1, &ASTNode{Type: NodeMath, Text: s[i+1 : end]})
+				i = end + 1
+				continue
+			}
+		case '!':
+			if m := astImageRegex.FindStringSubmatch(rest); m != nil {
+				attrs := map[string]string{"alt": m[1]}
+				attrs["src"], attrs["title"] = splitImageTitle(m[2])
+				if attrs["title"] == "" {
+					delete(attrs, "title")
+				}
+				emit(i, i+len(m[0]), &ASTNode{Type: NodeImage, Attrs: attrs})
+				i += len(m[0])
+				continue
+			}
+		case '[':
+			if m := astNoteRegex.FindStringSubmatch(rest); m != nil {
+				emit(i, i+len(m[0]), &ASTNode{Type: NodeFootnoteRef, Attrs: map[string]string{"id": m[1]}})
+				i += len(m[0])
+				continue
+			}
+			if m := astLinkRegex.FindStringSubmatch(rest); m != nil {
+				emit(i, i+len(m[0]), &ASTNode{Type: NodeLink, Attrs: map[string]string{"href": m[2]}, Children: parseInline(m[1], line, at(i+1))})
+				i += len(m[0])
+				continue
+			}
+		case '*':
+			if m := astStrongRegex.FindStringSubmatch(rest); m != nil {
+				emit(i, i+len(m[0]), &ASTNode{Type: NodeStrong, Children: parseInline(m[1], line, at(i+2))})
+				i += len(m[0])
+				continue
+			}
+			if m := astEmRegex.FindStringSubmatch(rest); m != nil {
+				emit(i, i+len(m[0]), &ASTNode{Type: NodeEmphasis, Children: parseInline(m[1], line, at(i+1))})
+				i += len(m[0])
+				continue
+			}
+		case '<':
+			if m := astTagRegex.FindString(rest); m != "" {
+				emit(i, i+len(m), &ASTNode{Type: NodeHTML, Text: m})
+				i += len(m)
+				continue
+			}
+		}
+
+		if text.Len() == 0 {
+			textCol = at(i)
+		}
+		_, size := utf8.DecodeRuneInString(rest)
+		text.WriteString(rest[:size])
+		i += size
+	}
+	flush(len(s))
+	return nodes
+}
+
+// inlineMathEnd 按 replaceInlineMath 的规则查找从 i 开始的行内公式的结束 $，不是公式时返回 -1
+func inlineMathEnd(s string, i int) int {
+	if (i > 0 && s[i-1] == '\\') || i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == '$' {
+		return -1
+	}
+	for j := i + 1; j < len(s); j++ {
+		if s[j] == '$' && s[j-1] != '\\' {
+			if s[j-1] != ' ' && (j+1 >= len(s) || s[j+1] < '0' || s[j+1] > '9') {
+				return j
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// Markdown 将语法树输出为 Markdown；带位置的块按原来的行号输出，转换时警告的行号不变
+func (d *ASTDocument) Markdown() string {
+	var lines []string
+	if len(d.FrontMatter) > 0 {
+		keys := make([]string, 0, len(d.FrontMatter))
+		for key := range d.FrontMatter {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		lines = append(lines, "---")
+		for _, key := range keys {
+			lines = append(lines, key+": "+quoteYAML(d.FrontMatter[key]))
+		}
+		lines = append(lines, "---")
+	}
+	w := &markdownWriter{keepLines: true}
+	lines = append(lines, w.blocks(d.Children, len(lines)+1)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ConvertASTMarkdown 将语法树还原为 Markdown（见 ASTDocument.Markdown）后转换为 HTML，
+// 不直接渲染节点；由 BuildAST 得到的语法树结果与直接转换原文相同
+func (c *WechatConverter) ConvertASTMarkdown(doc *ASTDocument) (string, error) {
+	if doc.Version != ASTVersion {
+		return "", fmt.Errorf("%w: %d", ErrASTVersion, doc.Version)
+	}
+	return c.ConvertMarkdownToWechat(doc.Markdown()), nil
+}
+
+// quoteYAML 值的首尾有空白或引号时加上双引号
+func quoteYAML(value string) string {
+	if value != strings.TrimSpace(value) || strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
+
+// markdownWriter 将语法树输出为 Markdown
+type markdownWriter struct {
+	// keepLines 带位置的块按原来的行号输出
+	keepLines bool
+	// listIndent 子列表的缩进，为 0 时缩进到上一项内容的位置
+	listIndent int
+	// alignTables 补齐表格各列的宽度
+	alignTables bool
+}
+
+// blocks 输出块级节点，first 为第一行的行号；原文中相隔空行的块之间至少保留一个空行
+func (w *markdownWriter) blocks(nodes []*ASTNode, first int) []string {
+	var lines []string
+	prevEnd := 0
+	for i, node := range nodes {
+		if i > 0 && (!w.keepLines || node.Pos == nil || prevEnd == 0 || node.Pos.StartLine > prevEnd+1) {
+			lines = append(lines, "")
+		}
+		if w.keepLines && node.Pos != nil {
+			for first+len(lines) < node.Pos.StartLine {
+				lines = append(lines, "")
+			}
+			prevEnd = node.Pos.EndLine
+		} else {
+			prevEnd = 0
+		}
+		lines = append(lines, w.block(node, first+len(lines))...)
+	}
+	return lines
+}
+
+// block 输出单个块级节点
+func (w *markdownWriter) block(node *ASTNode, first int) []string {
+	switch node.Type {
+	case NodeHeading:
+		level, _ := strconv.Atoi(node.Attrs["level"])
+		return []string{strings.Repeat("#", max(level, 1)) + " " + writeInline(node.Children)}
+	case NodeCodeBlock:
+		indent, _ := strconv.Atoi(node.Attrs["indent"])
+		fence := strings.Repeat(" ", max(indent, 0)) + "```"
+		lines := []string{fence + node.Attrs["lang"]}
+		if node.Text != "" {
+			lines = append(lines, strings.Split(node.Text, "\n")...)
+		}
+		return append(lines, fence)
+	case NodeMathBlock:
+		if strings.Contains(node.Text, "\n") {
+			return []string{"$$", node.Text, "$$"}
+		}
+		return []string{"$$" + node.Text + "$$"}
+	case NodeQuote:
+		inner := w.blocks(node.Children, first)
+		for i, line := range inner {
+			if line == "" {
+				inner[i] = ">"
+			} else {
+				inner[i] = "> " + line
+			}
+		}
+		return inner
+	case NodeList:
+		return w.list(node, "")
+	case NodeTable:
+		return w.table(node)
+	case NodeThematicBreak:
+		if node.Text == "" {
+			return []string{"---"}
+		}
+		return []string{node.Text}
+	case NodeFootnoteDef:
+		return []string{"[^" + node.Attrs["id"] + "]: " + writeInline(node.Children)}
+	default:
+		return strings.Split(writeInline(node.Children), "\n")
+	}
+}
+
+// list 输出列表
+func (w *markdownWriter) list(list *ASTNode, indent string) []string {
+	var lines []string
+	for i, item := range list.Children {
+		marker := item.Attrs["marker"]
+		if marker == "" {
+			marker = "-"
+			if list.Attrs["ordered"] == "true" {
+				marker = strconv.Itoa(i+1) + "."
+			}
+		}
+		prefix := marker + " "
+		switch item.Attrs["checked"] {
+		case "true":
+			prefix += "[x] "
+		case "false":
+			prefix += "[ ] "
+		}
+
+		var inline []*ASTNode
+		var sublists []*ASTNode
+		for _, child := range item.Children {
+			if child.Type == NodeList {
+				sublists = append(sublists, child)
+			} else {
+				inline = append(inline, child)
+			}
+		}
+		lines = append(lines, indent+prefix+writeInline(inline))
+		width := w.listIndent
+		if width <= 0 {
+			width = utf8.RuneCountInString(marker) + 1
+		}
+		for _, sub := range sublists {
+			lines = append(lines, w.list(sub, indent+strings.Repeat(" ", width))...)
+		}
+	}
+	return lines
+}
+
+// table 输出表格，分隔行按表头单元格的对齐方式生成
+func (w *markdownWriter) table(node *ASTNode) []string {
+	rows := make([][]string, len(node.Children))
+	var aligns []string
+	var widths []int
+	for i, row := range node.Children {
+		rows[i] = make([]string, len(row.Children))
+		for j, cell := range row.Children {
+			rows[i][j] = strings.ReplaceAll(writeInline(cell.Children), "|", `\|`)
+			if j >= len(widths) {
+				widths = append(widths, 3)
+				aligns = append(aligns, "")
+			}
+			if i == 0 {
+				aligns[j] = cell.Attrs["align"]
+			}
+			widths[j] = max(widths[j], displayWidth(rows[i][j]))
+		}
+	}
+
+	var lines []string
+	for i, cells := range rows {
+		for j := range cells {
+			if w.alignTables {
+				cells[j] = padCell(cells[j], widths[j], aligns[j])
+			}
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i > 0 {
+			continue
+		}
+		seps := make([]string, len(cells))
+		for j := range cells {
+			width := 3
+			if w.alignTables {
+				width = widths[j]
+			}
+			switch aligns[j] {
+			case "left":
+				seps[j] = ":" + strings.Repeat("-", width-1)
+			case "center":
+				seps[j] = ":" + strings.Repeat("-", max(width-2, 1)) + ":"
+			case "right":
+				seps[j] = strings.Repeat("-", width-1) + ":"
+			default:
+				seps[j] = strings.Repeat("-", width)
+			}
+		}
+		lines = append(lines, "| "+strings.Join(seps, " | ")+" |")
+	}
+	return lines
+}
+
+// writeInline 输出行内节点
+func writeInline(nodes []*ASTNode) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case NodeSoftBreak:
+			b.WriteString("\n")
+		case NodeStrong:
+			b.WriteString("**" + writeInline(node.Children) + "**")
+		case NodeEmphasis:
+			b.WriteString("*" + writeInline(node.Children) + "*")
+		case NodeCode:
+			b.WriteString("`" + node.Text + "`")
+		case NodeLink:
+			b.WriteString("[" + writeInline(node.Children) + "](" + node.Attrs["href"] + ")")
+		case NodeImage:
+			if title, ok := node.Attrs["title"]; ok {
+				b.WriteString("![" + node.Attrs["alt"] + "](" + node.Attrs["src"] + ` "` + title + `")`)
+			} else {
+				b.WriteString("![" + node.Attrs["alt"] + "](" + node.Attrs["src"] + ")")
+			}
+		case NodeMath:
+			if node.Attrs["display"] == "true" {
+				b.WriteString("$$" + node.Text + "$$")
+			} else {
+				b.WriteString("$" + node.Text + "$")
+			}
+		case NodeFootnoteRef:
+			b.WriteString("[^" + node.Attrs["id"] + "]")
+		default:
+			b.WriteString(node.Text)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package converter
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+const astSample = `---
+title: "  AST 示例"
+author: 测试
+---
+
+# 标题 **粗体** 与 *斜体*
+
+第一行有 ` + "`code`" + ` 和 [链接](https://example.com)，
+第二行有 $E=mc^2$、$$\sum_i x_i$$ 和脚注[^1]。
+
+> 引用中的 **强调**
+>
+> - 引用里的列表
+
+- 无序项
+  - 嵌套项 ![图](https://example.com/a.png)
+- [x] 已完成
+* 星号项
+
+1.
+
+编号内容
+
+2. 第二项
+• 圆点项
+
+| 名称 | 说明 | 数量 |
+| :--- | :---: | ---: |
+| a \| b | <b>粗</b> | 1 |
+| ` + "`x`" + ` | [l](u) | 2 |
+
+$$
+\int_0^1 f(x)\,dx
+$$
+
+---
+
+` + "```go\nfunc main() {\n\tprintln(\"hi\")\n}\n```" + `
+
+价格 $5 和 $10，转义 \$x$。
+
+[^1]: 脚注内容 **加粗**
+`
+
+// TestASTMarkdownRoundTrip 语法树经过 JSON 序列化、还原为 Markdown 后转换的 HTML 应与直接转换原文相同
+func TestASTMarkdownRoundTrip(t *testing.T) {
+	example, err := os.ReadFile("../../web/static/example.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, markdown := range map[string]string{"example.md": string(example), "sample": astSample} {
+		t.Run(name, func(t *testing.T) {
+			want := NewWechatConverterFixed().ConvertMarkdownToWechat(markdown)
+
+			data, err := json.Marshal(BuildAST(markdown))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc ASTDocument
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewWechatConverterFixed().ConvertASTMarkdown(&doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("round trip mismatch\nmarkdown:\n%s\nwant:\n%s\ngot:\n%s", doc.Markdown(), want, got)
+			}
+		})
+	}
+}
+
+// TestASTVersion 不支持的版本返回错误
+func TestASTVersion(t *testing.T) {
+	if _, err := NewWechatConverterFixed().ConvertASTMarkdown(&ASTDocument{Version: ASTVersion + 1}); err == nil {
+		t.Fatal("expected error for unsupported version")
+	}
+}
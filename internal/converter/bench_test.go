@@ -0,0 +1,68 @@
+package converter
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchFixture 读取 testdata/bench 下的文章；名称为 1mb 时把 large.md 重复到不少于 1 MB
+func benchFixture(t testing.TB, name string) string {
+	if name == "1mb" {
+		large := benchFixture(t, "large")
+		return strings.Repeat(large+"\n\n", (1<<20)/len(large)+1)
+	}
+	data, err := os.ReadFile("testdata/bench/" + name + ".md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// BenchmarkConvert 整篇转换不同长度的文章
+func BenchmarkConvert(b *testing.B) {
+	for _, name := range []string{"small", "medium", "large", "1mb"} {
+		b.Run(name, func(b *testing.B) {
+			markdown := benchFixture(b, name)
+			b.SetBytes(int64(len(markdown)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				NewWechatConverterFixed().ConvertMarkdownToWechat(markdown)
+			}
+		})
+	}
+}
+
+// perfBudgets 整篇转换的耗时上限，约为开发机实测值的三倍，以免 CI 机器波动导致误报
+var perfBudgets = []struct {
+	name   string
+	budget time.Duration
+}{
+	{"small", 2 * time.Millisecond},
+	{"medium", 5 * time.Millisecond},
+	{"large", 15 * time.Millisecond},
+	{"1mb", 200 * time.Millisecond},
+}
+
+// TestPerformanceBudget 转换耗时不能超过 perfBudgets，取多次运行中的最短耗时；-short 时跳过
+func TestPerformanceBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("performance budget is skipped in short mode")
+	}
+	for _, pb := range perfBudgets {
+		markdown := benchFixture(t, pb.name)
+		best := time.Duration(1<<63 - 1)
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			NewWechatConverterFixed().ConvertMarkdownToWechat(markdown)
+			best = min(best, time.Since(start))
+		}
+		if best > pb.budget {
+			t.Errorf("%s (%d bytes): conversion took %v, budget is %v", pb.name, len(markdown), best, pb.budget)
+		} else {
+			t.Logf("%s (%d bytes): %v (budget %v)", pb.name, len(markdown), best, pb.budget)
+		}
+	}
+}
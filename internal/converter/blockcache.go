@@ -0,0 +1,99 @@
+package converter
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultBlockCacheBytes 未指定大小时块缓存占用的内存上限
+const DefaultBlockCacheBytes = 8 << 20
+
+// blockEntryOverhead 每个缓存项除字符串内容外的估算开销
+const blockEntryOverhead = 128
+
+// BlockCache 块渲染结果的 LRU 缓存，键为块原文、渲染选项和上下文的哈希；
+// 按估算的内存占用淘汰最久未使用的块。可在多个转换器之间共享，并发安全
+type BlockCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List
+	items    map[string]*list.Element
+	hits     uint64
+	misses   uint64
+}
+
+// BlockCacheStats 块缓存的使用情况
+type BlockCacheStats struct {
+	Entries int
+	Bytes   int
+	Hits    uint64
+	Misses  uint64
+}
+
+type blockEntry struct {
+	key  string
+	res  *blockResult
+	size int
+}
+
+// NewBlockCache 创建最多占用 maxBytes 字节的块缓存，maxBytes 不大于 0 时使用 DefaultBlockCacheBytes
+func NewBlockCache(maxBytes int) *BlockCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultBlockCacheBytes
+	}
+	return &BlockCache{maxBytes: maxBytes, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Stats 返回缓存的项数、估算的内存占用和命中次数
+func (bc *BlockCache) Stats() BlockCacheStats {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return BlockCacheStats{Entries: bc.order.Len(), Bytes: bc.bytes, Hits: bc.hits, Misses: bc.misses}
+}
+
+// get 返回键对应的结果，并把它标记为最近使用
+func (bc *BlockCache) get(key string) (*blockResult, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	el, ok := bc.items[key]
+	if !ok {
+		bc.misses++
+		return nil, false
+	}
+	bc.hits++
+	bc.order.MoveToFront(el)
+	return el.Value.(*blockEntry).res, true
+}
+
+// put 缓存结果，超出上限时淘汰最久未使用的项；比上限还大的结果不缓存。res 放入后不能再修改
+func (bc *BlockCache) put(key string, res *blockResult) {
+	size := len(key) + len(res.html) + blockEntryOverhead
+	for _, notes := range [][]string{res.refNotes, res.linkNotes, res.refIDs} {
+		for _, s := range notes {
+			size += len(s)
+		}
+	}
+	for id := range res.numbers {
+		size += len(id) + 8
+	}
+	if size > bc.maxBytes {
+		return
+	}
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if el, ok := bc.items[key]; ok {
+		bc.order.MoveToFront(el)
+		return
+	}
+	bc.items[key] = bc.order.PushFront(&blockEntry{key: key, res: res, size: size})
+	bc.bytes += size
+	for bc.bytes > bc.maxBytes {
+		oldest := bc.order.Back()
+		entry := oldest.Value.(*blockEntry)
+		bc.order.Remove(oldest)
+		delete(bc.items, entry.key)
+		bc.bytes -= entry.size
+	}
+}
@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// largeFixture 把 example.md 重复 n 次，作为长文章
+func largeFixture(t testing.TB, n int) string {
+	example, err := os.ReadFile("../../web/static/example.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Repeat(string(example)+"\n\n", n)
+}
+
+// TestBlockCacheMatchesFullConversion 使用块缓存的转换结果应与整篇转换相同，第二次转换应全部命中缓存
+func TestBlockCacheMatchesFullConversion(t *testing.T) {
+	for name, markdown := range map[string]string{"example.md": largeFixture(t, 1), "sample": astSample} {
+		for _, numbering := range []HeadingNumbering{NumberingNone, NumberingH1} {
+			for _, lines := range []bool{false, true} {
+				full := NewWechatConverterFixed()
+				full.SetHeadingNumbering(numbering)
+				full.SetSourceLines(lines)
+				want := full.ConvertMarkdownToWechat(markdown)
+
+				cache := NewBlockCache(0)
+				cached := NewWechatConverterFixed()
+				cached.SetHeadingNumbering(numbering)
+				cached.SetSourceLines(lines)
+				cached.SetBlockCache(cache)
+				var misses uint64
+				for i := 0; i < 2; i++ {
+					if got := cached.ConvertMarkdownToWechat(markdown); got != want {
+						t.Errorf("%s (numbering %q, source lines %v, run %d): cached output differs from full conversion", name, numbering, lines, i+1)
+					}
+					if i == 0 {
+						misses = cache.Stats().Misses
+					}
+				}
+				if len(cached.SourceMap()) != len(full.SourceMap()) {
+					t.Errorf("%s: source map has %d blocks, want %d", name, len(cached.SourceMap()), len(full.SourceMap()))
+				}
+				if got := cache.Stats().Misses; got != misses {
+					t.Errorf("%s: second conversion rendered %d blocks, want all from cache", name, got-misses)
+				}
+			}
+		}
+	}
+}
+
+// TestBlockCacheOptions 选项不同的转换器共用缓存时不能相互使用对方的结果
+func TestBlockCacheOptions(t *testing.T) {
+	cache := NewBlockCache(0)
+	markdown := "# 标题\n\n正文"
+
+	plain := NewWechatConverterFixed()
+	plain.SetBlockCache(cache)
+	numbered := NewWechatConverterFixed()
+	numbered.SetHeadingNumbering(NumberingH1)
+	numbered.SetBlockCache(cache)
+
+	if html := plain.ConvertMarkdownToWechat(markdown); strings.Contains(html, "1 标题") {
+		t.Errorf("unnumbered output contains heading number: %s", html)
+	}
+	if html := numbered.ConvertMarkdownToWechat(markdown); !strings.Contains(html, "1 标题") {
+		t.Errorf("numbered output is missing heading number: %s", html)
+	}
+}
+
+// TestBlockCacheEviction 超出内存上限时淘汰最久未使用的块
+func TestBlockCacheEviction(t *testing.T) {
+	cache := NewBlockCache(4 << 10)
+	conv := NewWechatConverterFixed()
+	conv.SetBlockCache(cache)
+
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		b.WriteString("段落 " + strconv.Itoa(i) + "\n\n")
+	}
+	conv.ConvertMarkdownToWechat(b.String())
+	stats := cache.Stats()
+	if stats.Bytes > 4<<10 {
+		t.Errorf("cache uses %d bytes, limit is %d", stats.Bytes, 4<<10)
+	}
+	if stats.Entries == 0 || stats.Entries >= 200 {
+		t.Errorf("cache has %d entries, want some but not all of 200", stats.Entries)
+	}
+
+	// 最近渲染的块仍在缓存中，最早渲染的块已被淘汰
+	before := cache.Stats().Misses
+	conv.ConvertMarkdownToWechat("段落 199")
+	if cache.Stats().Misses != before {
+		t.Error("most recently used block was evicted")
+	}
+	conv.ConvertMarkdownToWechat("段落 0")
+	if cache.Stats().Misses == before {
+		t.Error("least recently used block was not evicted")
+	}
+}
+
+// benchmarkEdit 模拟编辑长文章：每次在中间插入不同的段落后重新转换
+func benchmarkEdit(b *testing.B, cache *BlockCache) {
+	markdown := largeFixture(b, 20)
+	mid := strings.Index(markdown[len(markdown)/2:], "\n\n") + len(markdown)/2
+	conv := NewWechatConverterFixed()
+	conv.SetBlockCache(cache)
+	conv.ConvertMarkdownToWechat(markdown)
+
+	b.SetBytes(int64(len(markdown)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conv.ConvertMarkdownToWechat(markdown[:mid] + "\n\n编辑 " + strconv.Itoa(i) + markdown[mid:])
+	}
+}
+
+func BenchmarkEditFull(b *testing.B) {
+	benchmarkEdit(b, nil)
+}
+
+func BenchmarkEditBlockCache(b *testing.B) {
+	benchmarkEdit(b, NewBlockCache(0))
+}
@@ -0,0 +1,229 @@
+package converter
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Severity 诊断的严重程度
+type Severity string
+
+const (
+	// SeverityError 内容会丢失或整体错乱
+	SeverityError Severity = "error"
+	// SeverityWarning 输出与作者的意图不一致
+	SeverityWarning Severity = "warning"
+	// SeverityInfo 不影响输出的提示
+	SeverityInfo Severity = "info"
+)
+
+// 诊断代码
+const (
+	DiagUnclosedFence     = "unclosed-fence"
+	DiagBrokenLink        = "broken-link"
+	DiagEmptyAlt          = "empty-alt"
+	DiagUndefinedFootnote = "undefined-footnote"
+	DiagDuplicateFootnote = "duplicate-footnote"
+	DiagUnusedFootnote    = "unused-footnote"
+	DiagUnsupportedSyntax = "unsupported-syntax"
+	DiagTableColumns      = "table-columns"
+	// DiagImage 转换时解析、读取或上传图片失败
+	DiagImage = "image"
+)
+
+// Diagnostic 带源码范围的诊断，Range 的行列规则与语法树的 Position 相同，
+// EndColumn 为范围最后一个字符之后的列，为 0 时表示到行尾
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Range    Position `json:"range"`
+}
+
+var (
+	setextRegex       = regexp.MustCompile(`^=+\s*$`)
+	refDefRegex       = regexp.MustCompile(`^\[[^\]^][^\]]*\]:\s*\S`)
+	refLinkRegex      = regexp.MustCompile(`\[[^\]]+\]\[[^\]]*\]`)
+	strikeRegex       = regexp.MustCompile(`~~[^~]+~~`)
+	unclosedLinkRegex = regexp.MustCompile(`\[[^\]]+\]\([^)]*$`)
+)
+
+// Diagnose 检查 Markdown 中转换器不支持或可能写错的地方，返回按位置排序的诊断；
+// 图片是否存在等需要读取文件的问题由转换器的 Diagnostics 给出
+func Diagnose(markdown string) []Diagnostic {
+	var diags []Diagnostic
+	add := func(severity Severity, code string, pos Position, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{Severity: severity, Code: code, Message: fmt.Sprintf(format, args...), Range: pos})
+	}
+
+	text := blankFrontMatter(markdown)
+	lines := strings.Split(text, "\n")
+	// lineRange 返回第 n 行去掉首尾空白后的范围
+	lineRange := func(n int) Position {
+		line := strings.TrimRight(lines[n-1], " \t\r")
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		return Position{StartLine: n, StartColumn: indent + 1, EndLine: n, EndColumn: utf8.RuneCountInString(line) + 1}
+	}
+
+	// 与转换器相同配对代码块：没有配对的围栏行按普通文字输出，之后的内容仍按 Markdown 渲染
+	if _, open := codeFences(text); open >= 0 {
+		if n := strings.Count(text[:open], "\n") + 1; strings.HasPrefix(strings.TrimSpace(lines[n-1]), "```") {
+			add(SeverityError, DiagUnclosedFence, lineRange(n), "unclosed code block; the fence line is shown as plain text and the lines after it are rendered as normal Markdown")
+		}
+	}
+
+	for _, block := range Parse(markdown).Blocks {
+		switch block.Type {
+		case BlockHeading:
+			if block.Level > 3 {
+				add(SeverityWarning, DiagUnsupportedSyntax, lineRange(block.StartLine), "heading level %d is rendered as a plain paragraph", block.Level)
+			}
+		case BlockTable:
+			header := len(splitTableRow(block.Lines[0]))
+			for i, row := range block.Lines[2:] {
+				n := block.StartLine + 2 + i
+				switch cells := len(splitTableRow(row)); {
+				case cells > header:
+					add(SeverityWarning, DiagTableColumns, lineRange(n), "table row has %d cells but the header has %d; extra cells are dropped", cells, header)
+				case cells < header:
+					add(SeverityInfo, DiagTableColumns, lineRange(n), "table row has %d cells but the header has %d; missing cells are left empty", cells, header)
+				}
+			}
+		case BlockQuote:
+			for i, line := range block.Lines {
+				if strings.HasPrefix(strings.TrimSpace(line), ">") {
+					add(SeverityWarning, DiagUnsupportedSyntax, lineRange(block.StartLine+i), "nested blockquotes are not supported")
+				}
+			}
+		case BlockParagraph:
+			for i, line := range block.Lines {
+				n := block.StartLine + i
+				switch {
+				case i > 0 && setextRegex.MatchString(line):
+					add(SeverityWarning, DiagUnsupportedSyntax, lineRange(n), "setext headings are not supported; use # instead")
+				case refDefRegex.MatchString(strings.TrimSpace(line)):
+					add(SeverityWarning, DiagUnsupportedSyntax, lineRange(n), "reference link definitions are not supported; use inline links")
+				}
+			}
+		}
+	}
+
+	// 脚注定义可以写在引用之后，先收集再检查
+	defs := make(map[string]*ASTNode)
+	var refs []*ASTNode
+	var walk func(nodes []*ASTNode)
+	walk = func(nodes []*ASTNode) {
+		for _, node := range nodes {
+			switch node.Type {
+			case NodeFootnoteDef:
+				if def, ok := defs[node.Attrs["id"]]; ok {
+					add(SeverityWarning, DiagDuplicateFootnote, lineRange(node.Pos.StartLine), "footnote [^%s] is already defined on line %d", node.Attrs["id"], def.Pos.StartLine)
+				} else {
+					defs[node.Attrs["id"]] = node
+				}
+			case NodeFootnoteRef:
+				refs = append(refs, node)
+			case NodeImage:
+				if strings.TrimSpace(node.Attrs["alt"]) == "" {
+					add(SeverityWarning, DiagEmptyAlt, *node.Pos, "image has no alt text: %s", node.Attrs["src"])
+				}
+			case NodeLink:
+				if msg := checkLinkURL(node.Attrs["href"]); msg != "" {
+					add(SeverityWarning, DiagBrokenLink, *node.Pos, "%s", msg)
+				}
+			case NodeListItem:
+				if _, ok := node.Attrs["checked"]; ok {
+					add(SeverityWarning, DiagUnsupportedSyntax, lineRange(node.Pos.StartLine), "task list checkboxes are rendered as plain text")
+				}
+			case NodeText:
+				diagnoseText(node, add)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(BuildAST(markdown).Children)
+
+	used := make(map[string]bool)
+	for _, ref := range refs {
+		id := ref.Attrs["id"]
+		used[id] = true
+		if _, ok := defs[id]; !ok {
+			add(SeverityWarning, DiagUndefinedFootnote, *ref.Pos, "footnote [^%s] is not defined", id)
+		}
+	}
+	for id, def := range defs {
+		if !used[id] {
+			add(SeverityWarning, DiagUnusedFootnote, lineRange(def.Pos.StartLine), "footnote [^%s] is never referenced", id)
+		}
+	}
+
+	sortDiagnostics(diags)
+	return diags
+}
+
+// diagnoseText 检查文本节点中没有被识别的行内语法
+func diagnoseText(node *ASTNode, add func(Severity, string, Position, string, ...interface{})) {
+	if node.Pos == nil || node.Pos.StartColumn == 0 {
+		return
+	}
+	span := func(loc []int) Position {
+		start := node.Pos.StartColumn + utf8.RuneCountInString(node.Text[:loc[0]])
+		return Position{StartLine: node.Pos.StartLine, StartColumn: start, EndLine: node.Pos.StartLine, EndColumn: start + utf8.RuneCountInString(node.Text[loc[0]:loc[1]])}
+	}
+	for _, loc := range refLinkRegex.FindAllStringIndex(node.Text, -1) {
+		add(SeverityWarning, DiagUnsupportedSyntax, span(loc), "reference links are not supported; use [text](url)")
+	}
+	for _, loc := range strikeRegex.FindAllStringIndex(node.Text, -1) {
+		add(SeverityWarning, DiagUnsupportedSyntax, span(loc), "strikethrough is rendered as plain text")
+	}
+	if loc := unclosedLinkRegex.FindStringIndex(node.Text); loc != nil {
+		add(SeverityWarning, DiagBrokenLink, span(loc), "link is missing its closing parenthesis")
+	}
+}
+
+// checkLinkURL 检查链接地址，有问题时返回说明
+func checkLinkURL(href string) string {
+	switch {
+	case strings.TrimSpace(href) == "":
+		return "link has an empty URL"
+	case strings.ContainsAny(strings.TrimSpace(href), " \t"):
+		return fmt.Sprintf("link URL %q contains spaces; link titles are not supported and spaces must be encoded as %%20", href)
+	}
+	if _, err := url.Parse(strings.TrimSpace(href)); err != nil {
+		return fmt.Sprintf("invalid link URL %q", href)
+	}
+	return ""
+}
+
+// Diagnostics 返回最近一次转换的诊断：原文检查的结果加上转换时的图片警告
+func (c *WechatConverter) Diagnostics() []Diagnostic {
+	diags := Diagnose(c.source)
+	for _, w := range c.warnings {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagImage,
+			Message:  w.Message,
+			Range:    Position{StartLine: w.Line, StartColumn: w.Column, EndLine: w.Line},
+		})
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+// sortDiagnostics 按起始位置排序，位置相同时按说明排序
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Range, diags[j].Range
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		if a.StartColumn != b.StartColumn {
+			return a.StartColumn < b.StartColumn
+		}
+		return diags[i].Message < diags[j].Message
+	})
+}
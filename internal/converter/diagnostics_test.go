@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDiagnoseFences 代码块诊断与转换器的配对一致：结束标记在行中间时已闭合，未闭合的围栏只标记围栏行
+func TestDiagnoseFences(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		markdown string
+		// line 未闭合诊断所在的行，0 表示没有
+		line int
+	}{
+		{"closed", "a\n\n```go\nx\n```\n", 0},
+		{"closed mid-line", "a\n\n```go\nx\n\ntail ``` here\n\n# T\n", 0},
+		{"unclosed", "a\n\n```go\nx\n\n# Title\n\ntext\n", 3},
+		{"unclosed last line", "a\n\n```", 3},
+		{"inline backticks", "use ``` for code\n", 0},
+	} {
+		var got []Diagnostic
+		for _, d := range Diagnose(tc.markdown) {
+			if d.Code == DiagUnclosedFence {
+				got = append(got, d)
+			}
+		}
+		switch {
+		case tc.line == 0 && len(got) > 0:
+			t.Errorf("%s: unexpected %v", tc.name, got)
+		case tc.line > 0 && (len(got) != 1 || got[0].Range.StartLine != tc.line || got[0].Range.EndLine != tc.line):
+			t.Errorf("%s: diagnostics %v, want one on line %d", tc.name, got, tc.line)
+		}
+
+		// 诊断与渲染结果一致：未闭合时围栏按文字输出，之后的标题仍是标题
+		html := NewWechatConverterFixed().ConvertMarkdownToWechat(tc.markdown)
+		if tc.line > 0 && (!strings.Contains(html, "```") || strings.Contains(tc.markdown, "# Title") && !strings.Contains(html, "Title</h1>")) {
+			t.Errorf("%s: output does not match the diagnostic:\n%s", tc.name, html)
+		}
+	}
+}
+
+// TestParseFences 代码块的范围与转换器的配对相同
+func TestParseFences(t *testing.T) {
+	doc := Parse("a\n\n```go\nx\n\ntail ``` here\n\n# T\n\n```\nunclosed\n")
+	var types []string
+	for _, b := range doc.Blocks {
+		types = append(types, string(b.Type))
+	}
+	if got := strings.Join(types, ","); got != "paragraph,code,heading,paragraph" {
+		t.Fatalf("blocks = %s", got)
+	}
+	code := doc.Blocks[1]
+	if code.StartLine != 3 || code.EndLine != 6 || code.Text() != "x\n\ntail " || code.Lang != "go" {
+		t.Errorf("code block = lines %d-%d %q lang %q", code.StartLine, code.EndLine, code.Text(), code.Lang)
+	}
+	if last := doc.Blocks[3]; last.StartLine != 10 || last.Lines[0] != "```" {
+		t.Errorf("unclosed fence = line %d %q, want a paragraph on line 10", last.StartLine, last.Lines)
+	}
+}
@@ -0,0 +1,166 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// DefaultMathJaxURL 未内联 MathJax 时使用的脚本地址，与 web/static/mathjax 的版本一致
+const DefaultMathJaxURL = "https://cdn.jsdelivr.net/npm/mathjax@3.2.2/es5/tex-svg-full.js"
+
+// DocumentOptions 独立 HTML 文档选项
+type DocumentOptions struct {
+	// Title 文档标题，为空时使用 front matter 的 title 或第一个标题
+	Title string
+	// ThemeCSS 主题样式表原文，作用于 #wenyan 容器中没有内联样式的元素
+	ThemeCSS string
+	// Preview 使用手机宽度的预览外框
+	Preview bool
+	// MathJaxURL 公式渲染脚本地址，为空时使用 DefaultMathJaxURL
+	MathJaxURL string
+	// MathJaxScript MathJax 脚本内容，设置后内联到文档中，不再引用 MathJaxURL
+	MathJaxScript string
+	// InlineImages 下载远程图片并内联为 data URI，生成可离线打开的单个文件
+	InlineImages bool
+}
+
+var imgSrcRegex = regexp.MustCompile(`(<img\b[^>]*?\ssrc=")([^"]+)(")`)
+
+// documentTemplate 独立文档骨架：标题、描述、样式、正文、脚本
+const documentTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>%s</title>
+%s<style>
+%s</style>
+</head>
+<body>
+%s
+%s</body>
+</html>
+`
+
+// documentCSS 文档外框样式，正文宽度与公众号文章一致
+const documentCSS = `body { margin: 0; background: #fff; }
+#wenyan { max-width: 677px; margin: 0 auto; padding: 20px 16px; box-sizing: border-box; }
+#wenyan img { max-width: 100%; }
+`
+
+// previewCSS 手机宽度的预览外框
+const previewCSS = `body { background: #ededed; }
+.preview-frame { width: 375px; max-width: 100%; margin: 24px auto; background: #fff; border: 1px solid #ddd; border-radius: 24px; box-shadow: 0 4px 20px rgba(0, 0, 0, 0.12); overflow: hidden; }
+.preview-frame #wenyan { max-width: none; padding: 24px 16px; }
+`
+
+// mathJaxConfig 与前端页面相同的 MathJax 配置，公式容器已由转换器生成
+const mathJaxConfig = `<script>
+MathJax = {
+  svg: { fontCache: 'none' },
+  tex: { inlineMath: [['\\(', '\\)']], displayMath: [['\\[', '\\]']], processEscapes: true }
+};
+</script>
+`
+
+// ConvertToDocument 将 Markdown 转换为可以独立打开的完整 HTML 文档
+func (c *WechatConverter) ConvertToDocument(markdown string, opts DocumentOptions) string {
+	body := c.ConvertMarkdownToWechat(markdown)
+
+	doc := Parse(markdown)
+	title := opts.Title
+	if title == "" {
+		title = documentTitle(doc)
+	}
+
+	if opts.InlineImages {
+		body = c.inlineRemoteImages(body)
+	}
+
+	var head strings.Builder
+	if c.stats.Digest != "" {
+		head.WriteString(fmt.Sprintf("<meta name=\"description\" content=\"%s\">\n", html.EscapeString(c.stats.Digest)))
+	}
+	if author := doc.FrontMatter.Get("author"); author != "" {
+		head.WriteString(fmt.Sprintf("<meta name=\"author\" content=\"%s\">\n", html.EscapeString(author)))
+	}
+
+	css := documentCSS
+	if opts.Preview {
+		css += previewCSS
+	}
+	if opts.ThemeCSS != "" {
+		css += opts.ThemeCSS + "\n"
+	}
+
+	content := `<section id="wenyan">` + body + `</section>`
+	if opts.Preview {
+		content = `<div class="preview-frame">` + content + `</div>`
+	}
+
+	var scripts string
+	if strings.Contains(body, `class="inline-equation"`) || strings.Contains(body, `class="block-equation"`) {
+		scripts = mathJaxConfig
+		if opts.MathJaxScript != "" {
+			// 避免脚本内容提前结束 <script> 标签
+			scripts += "<script>\n" + strings.ReplaceAll(opts.MathJaxScript, "</script", `<\/script`) + "\n</script>\n"
+		} else {
+			url := opts.MathJaxURL
+			if url == "" {
+				url = DefaultMathJaxURL
+			}
+			scripts += fmt.Sprintf("<script src=\"%s\"></script>\n", html.EscapeString(url))
+		}
+	}
+
+	return fmt.Sprintf(documentTemplate, html.EscapeString(title), head.String(),
+		strings.ReplaceAll(css, "</style", `<\/style`), content, scripts)
+}
+
+// documentTitle 返回文档标题，没有标题时为 Untitled
+func documentTitle(doc *Document) string {
+	if title := doc.Title(); title != "" {
+		return title
+	}
+	return "Untitled"
+}
+
+// Title 返回 front matter 中的标题或第一个标题的文字，都没有时为空
+func (d *Document) Title() string {
+	if title := d.FrontMatter.Get("title"); title != "" {
+		return title
+	}
+	for _, block := range d.Blocks {
+		if block.Type == BlockHeading {
+			return inlineText(block.Text())
+		}
+	}
+	return ""
+}
+
+// inlineRemoteImages 下载 HTML 中的远程图片并替换为 data URI，失败时保留原地址并记录警告
+func (c *WechatConverter) inlineRemoteImages(body string) string {
+	cache := make(map[string]string)
+	return imgSrcRegex.ReplaceAllStringFunc(body, func(match string) string {
+		m := imgSrcRegex.FindStringSubmatch(match)
+		src := html.UnescapeString(m[2])
+		if !isRemoteImage(src) {
+			return match
+		}
+		uri, ok := cache[src]
+		if !ok {
+			data, err := c.fetchImage(src)
+			if err != nil {
+				c.addWarning(0, "failed to inline image %s: %v", src, err)
+				return match
+			}
+			// 扩展名取自去掉查询参数的路径，无法识别时按内容推断
+			name, _, _ := strings.Cut(src, "?")
+			uri = dataURI(name, data)
+			cache[src] = uri
+		}
+		return m[1] + uri + m[3]
+	})
+}
@@ -0,0 +1,759 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidDOCX 不是有效的 Word 文档
+var ErrInvalidDOCX = errors.New("invalid docx file")
+
+// maxDOCXPartBytes 单个 XML 部件解压后的大小上限
+const maxDOCXPartBytes = 64 << 20
+
+var (
+	headingStyleRegex = regexp.MustCompile(`^(?:heading|标题)\s*(\d)$`)
+	hyperlinkInstr    = regexp.MustCompile(`HYPERLINK\s+"([^"]+)"`)
+)
+
+// docxImporter 保存单次 DOCX 导入的状态
+type docxImporter struct {
+	zip       *zip.Reader
+	rels      map[string]docxRel
+	styles    map[string]docxStyle
+	numFmts   map[string]map[string]string
+	counters  map[string][]int
+	footnotes []string
+	images    []string
+}
+
+// docxRel 文档关系
+type docxRel struct {
+	Target   string
+	External bool
+}
+
+// docxStyle 段落样式
+type docxStyle struct {
+	Name    string
+	BasedOn string
+	Outline int
+}
+
+// docxSpan 格式相同的一段文字
+type docxSpan struct {
+	text   string
+	bold   bool
+	italic bool
+	code   bool
+	link   string
+	// raw 已生成的 Markdown（图片、脚注引用），不再加格式
+	raw bool
+}
+
+// ImportDOCX 将 Word 文档转换为 Markdown。嵌入图片引用为 media/ 下的相对路径，
+// 文件内容通过 ImportResult.Media 读取或用 SaveMedia 保存
+func ImportDOCX(r io.ReaderAt, size int64) (*ImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+	}
+
+	doc, err := readXMLPart(zr, "word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDOCX, err)
+	}
+	body := doc.find(func(n *htmlNode) bool { return n.Tag == "body" })
+	if body == nil {
+		return nil, fmt.Errorf("%w: missing document body", ErrInvalidDOCX)
+	}
+
+	imp := &docxImporter{
+		zip:      zr,
+		rels:     readDOCXRels(zr),
+		styles:   readDOCXStyles(zr),
+		numFmts:  readDOCXNumbering(zr),
+		counters: make(map[string][]int),
+	}
+
+	text := strings.Join(imp.blocks(body.Children), "\n\n")
+	if notes := imp.readFootnotes(); notes != "" {
+		text += "\n\n" + notes
+	}
+	text = strings.TrimSpace(blankLinesRegex.ReplaceAllString(text, "\n\n"))
+	if text == "" {
+		return nil, ErrNoContent
+	}
+
+	fm := readDOCXCoreProps(zr)
+	var media fs.FS
+	if sub, err := fs.Sub(zr, "word"); err == nil {
+		media = sub
+	}
+	return &ImportResult{
+		Markdown:    renderFrontMatter(fm) + text + "\n",
+		FrontMatter: fm,
+		Images:      imp.images,
+		Media:       media,
+	}, nil
+}
+
+// SaveMedia 将导入结果中的嵌入图片保存到 dir 下，保持 Markdown 中的相对路径
+func (r *ImportResult) SaveMedia(dir string) error {
+	if r.Media == nil {
+		return nil
+	}
+	for _, name := range r.Images {
+		if isRemoteImage(name) || !fs.ValidPath(name) {
+			continue
+		}
+		data, err := fs.ReadFile(r.Media, name)
+		if err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("image %s: %v", name, err))
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readXMLPart 读取并解析压缩包中的 XML 部件
+func readXMLPart(zr *zip.Reader, name string) (*htmlNode, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxDOCXPartBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDOCXPartBytes {
+		return nil, fmt.Errorf("%s larger than %d bytes", name, maxDOCXPartBytes)
+	}
+	return parseXML(data)
+}
+
+// parseXML 将 XML 解析为节点树，标签和属性只保留本地名
+func parseXML(data []byte) (*htmlNode, error) {
+	root := &htmlNode{Tag: "#root"}
+	cur := root
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &htmlNode{Tag: t.Name.Local, Attrs: make(map[string]string, len(t.Attr)), Parent: cur}
+			for _, a := range t.Attr {
+				n.Attrs[a.Name.Local] = a.Value
+			}
+			cur.Children = append(cur.Children, n)
+			cur = n
+		case xml.EndElement:
+			if cur.Parent != nil {
+				cur = cur.Parent
+			}
+		case xml.CharData:
+			if cur.Tag == "t" || cur.Tag == "instrText" || strings.TrimSpace(string(t)) != "" {
+				cur.Children = append(cur.Children, &htmlNode{Text: string(t), Parent: cur})
+			}
+		}
+	}
+}
+
+// child 返回第一个指定名称的子节点
+func (n *htmlNode) child(tag string) *htmlNode {
+	for _, c := range n.Children {
+		if c.Tag == tag {
+			return c
+		}
+	}
+	return nil
+}
+
+// childVal 返回子节点的 val 属性，如 <w:pStyle w:val="Heading1"/>
+func (n *htmlNode) childVal(tag string) string {
+	if c := n.child(tag); c != nil {
+		return c.attr("val")
+	}
+	return ""
+}
+
+// readDOCXRels 读取文档关系：图片和超链接地址
+func readDOCXRels(zr *zip.Reader) map[string]docxRel {
+	rels := make(map[string]docxRel)
+	root, err := readXMLPart(zr, "word/_rels/document.xml.rels")
+	if err != nil {
+		return rels
+	}
+	for _, list := range root.Children {
+		for _, rel := range list.Children {
+			rels[rel.attr("Id")] = docxRel{Target: rel.attr("Target"), External: rel.attr("TargetMode") == "External"}
+		}
+	}
+	return rels
+}
+
+// readDOCXStyles 读取段落样式名称和大纲级别
+func readDOCXStyles(zr *zip.Reader) map[string]docxStyle {
+	styles := make(map[string]docxStyle)
+	root, err := readXMLPart(zr, "word/styles.xml")
+	if err != nil {
+		return styles
+	}
+	for _, list := range root.Children {
+		for _, s := range list.Children {
+			if s.Tag != "style" {
+				continue
+			}
+			style := docxStyle{Name: strings.ToLower(s.childVal("name")), BasedOn: s.childVal("basedOn"), Outline: -1}
+			if ppr := s.child("pPr"); ppr != nil {
+				if lvl, err := strconv.Atoi(ppr.childVal("outlineLvl")); err == nil {
+					style.Outline = lvl
+				}
+			}
+			styles[s.attr("styleId")] = style
+		}
+	}
+	return styles
+}
+
+// readDOCXNumbering 读取列表编号格式：numId -> 级别 -> numFmt
+func readDOCXNumbering(zr *zip.Reader) map[string]map[string]string {
+	nums := make(map[string]map[string]string)
+	root, err := readXMLPart(zr, "word/numbering.xml")
+	if err != nil {
+		return nums
+	}
+	var numbering *htmlNode
+	for _, c := range root.Children {
+		if c.Tag == "numbering" {
+			numbering = c
+		}
+	}
+	if numbering == nil {
+		return nums
+	}
+
+	abstract := make(map[string]map[string]string)
+	for _, a := range numbering.Children {
+		if a.Tag != "abstractNum" {
+			continue
+		}
+		levels := make(map[string]string)
+		for _, lvl := range a.Children {
+			if lvl.Tag == "lvl" {
+				levels[lvl.attr("ilvl")] = lvl.childVal("numFmt")
+			}
+		}
+		abstract[a.attr("abstractNumId")] = levels
+	}
+	for _, n := range numbering.Children {
+		if n.Tag == "num" {
+			nums[n.attr("numId")] = abstract[n.childVal("abstractNumId")]
+		}
+	}
+	return nums
+}
+
+// readDOCXCoreProps 从 docProps/core.xml 读取标题、作者、日期和摘要
+func readDOCXCoreProps(zr *zip.Reader) FrontMatter {
+	fm := make(FrontMatter)
+	root, err := readXMLPart(zr, "docProps/core.xml")
+	if err != nil {
+		return fm
+	}
+	for _, props := range root.Children {
+		for _, p := range props.Children {
+			value := strings.TrimSpace(p.textContent())
+			if value == "" {
+				continue
+			}
+			switch p.Tag {
+			case "title":
+				fm["title"] = value
+			case "creator":
+				fm["author"] = value
+			case "description":
+				fm["digest"] = value
+			case "created":
+				if len(value) >= 10 {
+					fm["date"] = value[:10]
+				}
+			}
+		}
+	}
+	return fm
+}
+
+// styleInfo 沿 basedOn 链查找段落的标题级别，返回样式名称
+func (imp *docxImporter) styleInfo(id string) (name string, heading int) {
+	for depth := 0; id != "" && depth < 10; depth++ {
+		style, ok := imp.styles[id]
+		if !ok {
+			break
+		}
+		if name == "" {
+			name = style.Name
+		}
+		if m := headingStyleRegex.FindStringSubmatch(style.Name); m != nil {
+			level, _ := strconv.Atoi(m[1])
+			return name, level
+		}
+		if style.Name == "title" {
+			return name, 1
+		}
+		if style.Outline >= 0 && style.Outline < 6 {
+			return name, style.Outline + 1
+		}
+		id = style.BasedOn
+	}
+	return name, 0
+}
+
+// blocks 转换 body、表格单元格等容器中的段落和表格
+func (imp *docxImporter) blocks(nodes []*htmlNode) []string {
+	var out []string
+	var code []string
+	lastKind := ""
+
+	flushCode := func() {
+		if len(code) > 0 {
+			out = append(out, fence(strings.Join(code, "\n"), ""))
+			code = nil
+		}
+	}
+	add := func(block, kind string) {
+		if block == "" {
+			return
+		}
+		if kind != "" && kind == lastKind && len(out) > 0 {
+			out[len(out)-1] += "\n" + block
+		} else {
+			out = append(out, block)
+		}
+		lastKind = kind
+	}
+
+	for _, n := range nodes {
+		switch n.Tag {
+		case "p":
+			styleName, heading := imp.styleInfo(pPrVal(n, "pStyle"))
+			if strings.Contains(styleName, "code") || strings.Contains(styleName, "source") {
+				code = append(code, imp.plainText(n))
+				continue
+			}
+			flushCode()
+
+			text := imp.paragraph(n)
+			ppr := n.child("pPr")
+			switch {
+			case heading > 0:
+				if text = oneLine(text); text != "" {
+					add(strings.Repeat("#", heading)+" "+text, "")
+				}
+			case ppr != nil && ppr.child("numPr") != nil:
+				numPr := ppr.child("numPr")
+				add(imp.listItem(numPr, text), "list:"+numPr.childVal("numId"))
+			case strings.Contains(styleName, "quote") || strings.Contains(styleName, "引用"):
+				add(prefixLines(text, "> "), "quote")
+			default:
+				add(strings.TrimSpace(text), "")
+			}
+		case "tbl":
+			flushCode()
+			add(imp.table(n), "")
+		case "sdt":
+			flushCode()
+			if content := n.child("sdtContent"); content != nil {
+				for _, block := range imp.blocks(content.Children) {
+					add(block, "")
+				}
+			}
+		}
+	}
+	flushCode()
+	return out
+}
+
+// pPrVal 读取段落属性值
+func pPrVal(p *htmlNode, tag string) string {
+	if ppr := p.child("pPr"); ppr != nil {
+		return ppr.childVal(tag)
+	}
+	return ""
+}
+
+// prefixLines 给每行加前缀
+func prefixLines(text, prefix string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// listItem 生成列表项，有序列表按 numId 和级别计数
+func (imp *docxImporter) listItem(numPr *htmlNode, text string) string {
+	numID := numPr.childVal("numId")
+	level, _ := strconv.Atoi(numPr.childVal("ilvl"))
+	if level < 0 || level > 8 {
+		level = 0
+	}
+
+	counters := imp.counters[numID]
+	for len(counters) <= level {
+		counters = append(counters, 0)
+	}
+	counters[level]++
+	// 回到上级时重新计数下级
+	counters = counters[:level+1]
+	imp.counters[numID] = counters
+
+	marker := "- "
+	if format := imp.numFmts[numID][strconv.Itoa(level)]; format != "" && format != "bullet" && format != "none" {
+		marker = fmt.Sprintf("%d. ", counters[level])
+	}
+	return strings.Repeat("  ", level) + marker + oneLine(text)
+}
+
+// table 转换表格，合并单元格使用 "<" 和 "^" 标记
+func (imp *docxImporter) table(tbl *htmlNode) string {
+	var grid [][]string
+	for _, tr := range tbl.Children {
+		if tr.Tag != "tr" {
+			continue
+		}
+		var row []string
+		for _, tc := range tr.Children {
+			if tc.Tag != "tc" {
+				continue
+			}
+			span := 1
+			merge := ""
+			if tcPr := tc.child("tcPr"); tcPr != nil {
+				if n, err := strconv.Atoi(tcPr.childVal("gridSpan")); err == nil && n > 1 {
+					span = n
+				}
+				if vm := tcPr.child("vMerge"); vm != nil && vm.attr("val") != "restart" {
+					merge = rowspanMarker
+				}
+			}
+
+			text := merge
+			if merge == "" {
+				text = strings.ReplaceAll(oneLine(strings.Join(imp.blocks(tc.Children), " ")), "|", `\|`)
+			}
+			row = append(row, text)
+			for k := 1; k < span; k++ {
+				row = append(row, colspanMarker)
+			}
+		}
+		grid = append(grid, row)
+	}
+	if len(grid) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range grid {
+		columns = max(columns, len(row))
+	}
+	line := func(cells []string) string {
+		padded := make([]string, columns)
+		copy(padded, cells)
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+	out := []string{line(grid[0]), "|" + strings.Repeat(" --- |", columns)}
+	for _, row := range grid[1:] {
+		out = append(out, line(row))
+	}
+	return strings.Join(out, "\n")
+}
+
+// paragraph 转换段落中的文字、链接、图片和脚注引用
+func (imp *docxImporter) paragraph(p *htmlNode) string {
+	var spans []docxSpan
+	field := ""     // 当前复杂域的指令
+	fieldLink := "" // 当前 HYPERLINK 域的地址
+	inField := false
+
+	var walk func(n *htmlNode, link string)
+	walk = func(n *htmlNode, link string) {
+		for _, c := range n.Children {
+			switch c.Tag {
+			case "r":
+				if fc := c.child("fldChar"); fc != nil {
+					switch fc.attr("fldCharType") {
+					case "begin":
+						field, inField = "", true
+					case "separate":
+						if m := hyperlinkInstr.FindStringSubmatch(field); m != nil {
+							fieldLink = m[1]
+						}
+						inField = false
+					case "end":
+						field, fieldLink, inField = "", "", false
+					}
+					continue
+				}
+				if inField {
+					if instr := c.child("instrText"); instr != nil {
+						field += instr.textContent()
+					}
+					continue
+				}
+				l := link
+				if fieldLink != "" {
+					l = fieldLink
+				}
+				spans = append(spans, imp.run(c, l)...)
+			case "hyperlink":
+				href := ""
+				if rel, ok := imp.rels[c.attr("id")]; ok && rel.External {
+					href = rel.Target
+				}
+				walk(c, href)
+			case "fldSimple":
+				href := link
+				if m := hyperlinkInstr.FindStringSubmatch(c.attr("instr")); m != nil {
+					href = m[1]
+				}
+				walk(c, href)
+			case "ins", "smartTag", "customXml":
+				walk(c, link)
+			}
+		}
+	}
+	walk(p, "")
+
+	return renderSpans(spans)
+}
+
+// run 转换一个文字块
+func (imp *docxImporter) run(r *htmlNode, link string) []docxSpan {
+	base := docxSpan{link: link}
+	if rpr := r.child("rPr"); rpr != nil {
+		base.bold = onOff(rpr.child("b"))
+		base.italic = onOff(rpr.child("i"))
+		if fonts := rpr.child("rFonts"); fonts != nil {
+			font := strings.ToLower(fonts.attr("ascii"))
+			base.code = strings.Contains(font, "mono") || strings.Contains(font, "courier") || strings.Contains(font, "consolas")
+		}
+		if style := strings.ToLower(rpr.childVal("rStyle")); strings.Contains(style, "code") {
+			base.code = true
+		}
+	}
+
+	var spans []docxSpan
+	text := func(s string) {
+		span := base
+		span.text = s
+		spans = append(spans, span)
+	}
+	for _, c := range r.Children {
+		switch c.Tag {
+		case "t":
+			text(c.textContent())
+		case "tab":
+			text(" ")
+		case "br", "cr":
+			if c.attr("type") != "page" {
+				text("\n")
+			}
+		case "footnoteReference":
+			if !containsString(imp.footnotes, c.attr("id")) {
+				imp.footnotes = append(imp.footnotes, c.attr("id"))
+			}
+			spans = append(spans, docxSpan{text: "[^" + c.attr("id") + "]", raw: true})
+		case "drawing", "pict", "object":
+			if img := imp.image(c); img != "" {
+				spans = append(spans, docxSpan{text: img, raw: true})
+			}
+		}
+	}
+	return spans
+}
+
+// onOff 读取 <w:b/>、<w:b w:val="0"/> 等开关属性
+func onOff(n *htmlNode) bool {
+	if n == nil {
+		return false
+	}
+	v := n.attr("val")
+	return v == "" || v == "1" || v == "true" || v == "on"
+}
+
+// image 转换嵌入或链接的图片
+func (imp *docxImporter) image(n *htmlNode) string {
+	ref := n.find(func(c *htmlNode) bool {
+		return (c.Tag == "blip" && (c.attr("embed") != "" || c.attr("link") != "")) || (c.Tag == "imagedata" && c.attr("id") != "")
+	})
+	if ref == nil {
+		return ""
+	}
+	id := ref.attr("embed")
+	if id == "" {
+		id = ref.attr("link")
+	}
+	if id == "" {
+		id = ref.attr("id")
+	}
+	rel, ok := imp.rels[id]
+	if !ok {
+		return ""
+	}
+
+	src := rel.Target
+	if !rel.External {
+		// 内部图片相对于 word/ 目录
+		if strings.HasPrefix(src, "/") {
+			if !strings.HasPrefix(src, "/word/") {
+				return ""
+			}
+			src = strings.TrimPrefix(src, "/word/")
+		}
+		if src = path.Clean(src); !fs.ValidPath(src) {
+			return ""
+		}
+	}
+	if !containsString(imp.images, src) {
+		imp.images = append(imp.images, src)
+	}
+
+	alt := ""
+	if pr := n.find(func(c *htmlNode) bool { return c.Tag == "docPr" }); pr != nil {
+		alt = strings.NewReplacer("[", "", "]", "", "\n", " ").Replace(pr.attr("descr"))
+	}
+	return "![" + alt + "](" + src + ")"
+}
+
+// plainText 返回段落的纯文本，用于代码段落
+func (imp *docxImporter) plainText(p *htmlNode) string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		for _, c := range n.Children {
+			switch c.Tag {
+			case "t":
+				b.WriteString(c.textContent())
+			case "tab":
+				b.WriteString("\t")
+			case "br", "cr":
+				b.WriteString("\n")
+			case "instrText", "delText", "pPr", "rPr":
+			default:
+				walk(c)
+			}
+		}
+	}
+	walk(p)
+	return b.String()
+}
+
+// readFootnotes 生成被引用脚注的定义
+func (imp *docxImporter) readFootnotes() string {
+	if len(imp.footnotes) == 0 {
+		return ""
+	}
+	root, err := readXMLPart(imp.zip, "word/footnotes.xml")
+	if err != nil {
+		return ""
+	}
+
+	texts := make(map[string]string)
+	for _, list := range root.Children {
+		for _, note := range list.Children {
+			if note.Tag != "footnote" {
+				continue
+			}
+			var parts []string
+			for _, p := range note.Children {
+				if p.Tag == "p" {
+					parts = append(parts, oneLine(imp.paragraph(p)))
+				}
+			}
+			texts[note.attr("id")] = strings.TrimSpace(strings.Join(parts, " "))
+		}
+	}
+
+	var defs []string
+	for _, id := range imp.footnotes {
+		defs = append(defs, "[^"+id+"]: "+texts[id])
+	}
+	return strings.Join(defs, "\n")
+}
+
+// renderSpans 合并格式相同的相邻片段并生成 Markdown
+func renderSpans(spans []docxSpan) string {
+	var merged []docxSpan
+	for _, s := range spans {
+		if n := len(merged); n > 0 && !s.raw && !merged[n-1].raw &&
+			s.bold == merged[n-1].bold && s.italic == merged[n-1].italic && s.code == merged[n-1].code && s.link == merged[n-1].link {
+			merged[n-1].text += s.text
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(merged); i++ {
+		s := merged[i]
+		if s.link == "" || s.raw {
+			b.WriteString(formatSpan(s))
+			continue
+		}
+		// 链接内可以包含多种格式
+		var text strings.Builder
+		link := s.link
+		for ; i < len(merged) && merged[i].link == link && !merged[i].raw; i++ {
+			text.WriteString(formatSpan(merged[i]))
+		}
+		i--
+		if t := strings.TrimSpace(text.String()); t != "" {
+			b.WriteString("[" + t + "](" + link + ")")
+		}
+	}
+	return b.String()
+}
+
+// formatSpan 为片段加上粗体、斜体或行内代码标记
+func formatSpan(s docxSpan) string {
+	if s.raw {
+		return s.text
+	}
+	text := s.text
+	if s.code && strings.TrimSpace(text) != "" && !strings.Contains(text, "`") {
+		return wrapInline(text, "`")
+	}
+	if s.italic {
+		text = wrapInline(text, "*")
+	}
+	if s.bold {
+		text = wrapInline(text, "**")
+	}
+	return text
+}
@@ -0,0 +1,207 @@
+package converter
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// docxNS document.xml 等部件使用的命名空间声明
+const docxNS = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" ` +
+	`xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+	`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"`
+
+// docxFixture 测试用 Word 文档的各个部件
+var docxFixture = map[string]string{
+	"[Content_Types].xml": `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`,
+	"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/">` +
+		`<dc:title>文档标题</dc:title><dc:creator>作者</dc:creator><dcterms:created>2024-05-06T07:08:09Z</dcterms:created></cp:coreProperties>`,
+	"word/_rels/document.xml.rels": `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="hyperlink" Target="https://example.com/doc" TargetMode="External"/>` +
+		`<Relationship Id="rId2" Type="image" Target="media/image1.png"/>` +
+		`<Relationship Id="rId3" Type="image" Target="../../evil.png"/>` +
+		`<Relationship Id="rId4" Type="image" Target="/etc/evil.png"/>` +
+		`</Relationships>`,
+	"word/styles.xml": `<?xml version="1.0" encoding="UTF-8"?><w:styles ` + docxNS + `>` +
+		`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="MyHeading"><w:name w:val="My Heading"/><w:basedOn w:val="Heading2Char"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Heading2Char"><w:name w:val="Custom"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Source Code"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/></w:style>` +
+		`</w:styles>`,
+	"word/numbering.xml": `<?xml version="1.0" encoding="UTF-8"?><w:numbering ` + docxNS + `>` +
+		`<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl><w:lvl w:ilvl="1"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>` +
+		`<w:abstractNum w:abstractNumId="1"><w:lvl w:ilvl="0"><w:numFmt w:val="bullet"/></w:lvl></w:abstractNum>` +
+		`<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num><w:num w:numId="2"><w:abstractNumId w:val="1"/></w:num>` +
+		`</w:numbering>`,
+	"word/footnotes.xml": `<?xml version="1.0" encoding="UTF-8"?><w:footnotes ` + docxNS + `>` +
+		`<w:footnote w:id="0"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>` +
+		`<w:footnote w:id="2"><w:p><w:r><w:t xml:space="preserve"> 脚注</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>内容</w:t></w:r></w:p></w:footnote>` +
+		`</w:footnotes>`,
+	"word/media/image1.png": "PNG",
+	"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?><w:document ` + docxNS + `><w:body>` +
+		docxParagraph("Heading1", "", `<w:r><w:t>一级标题</w:t></w:r>`) +
+		docxParagraph("MyHeading", "", `<w:r><w:t>二级标题</w:t></w:r>`) +
+		docxParagraph("", "", `<w:r><w:t xml:space="preserve">正文 </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">粗体 </w:t></w:r>`+
+			`<w:r><w:rPr><w:b w:val="0"/><w:i/></w:rPr><w:t xml:space="preserve">斜体 </w:t></w:r><w:r><w:rPr><w:rFonts w:ascii="Consolas"/></w:rPr><w:t>code</w:t></w:r><w:r><w:t xml:space="preserve"> </w:t></w:r>`+
+			`<w:hyperlink r:id="rId1"><w:r><w:t>链接</w:t></w:r></w:hyperlink><w:r><w:t xml:space="preserve"> </w:t></w:r>`+
+			`<w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText> HYPERLINK "https://example.com/field" </w:instrText></w:r>`+
+			`<w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>域链接</w:t></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r>`+
+			`<w:r><w:footnoteReference w:id="2"/></w:r>`) +
+		docxParagraph("", `<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`, `<w:r><w:t>第一项</w:t></w:r>`) +
+		docxParagraph("", `<w:numPr><w:ilvl w:val="1"/><w:numId w:val="1"/></w:numPr>`, `<w:r><w:t>子项</w:t></w:r>`) +
+		docxParagraph("", `<w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr>`, `<w:r><w:t>第二项</w:t></w:r>`) +
+		docxParagraph("", "", `<w:r><w:t>间隔</w:t></w:r>`) +
+		docxParagraph("", `<w:numPr><w:ilvl w:val="0"/><w:numId w:val="2"/></w:numPr>`, `<w:r><w:t>圆点</w:t></w:r>`) +
+		docxParagraph("", `<w:numPr><w:ilvl w:val="0"/><w:numId w:val="2"/></w:numPr>`, `<w:r><w:t>圆点二</w:t></w:r>`) +
+		`<w:tbl><w:tr><w:tc><w:tcPr><w:gridSpan w:val="2"/></w:tcPr><w:p><w:r><w:t>合并</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:tcPr><w:vMerge w:val="restart"/></w:tcPr><w:p><w:r><w:t>a|b</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1</w:t></w:r></w:p></w:tc></w:tr>` +
+		`<w:tr><w:tc><w:tcPr><w:vMerge/></w:tcPr><w:p/></w:tc><w:tc><w:p><w:r><w:t>2</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+		docxParagraph("Code", "", `<w:r><w:t>func main() {</w:t></w:r>`) +
+		docxParagraph("Code", "", `<w:r><w:tab/><w:t>return</w:t></w:r>`) +
+		docxParagraph("Code", "", `<w:r><w:t>}</w:t></w:r>`) +
+		docxParagraph("Quote", "", `<w:r><w:t>引用</w:t></w:r>`) +
+		docxParagraph("", "", docxImage("rId2", "图片")+docxImage("rId3", "越界")+docxImage("rId4", "绝对路径")) +
+		`<w:sectPr/></w:body></w:document>`,
+}
+
+// docxParagraph 生成段落，style 为段落样式，props 为其他段落属性
+func docxParagraph(style, props, runs string) string {
+	if style != "" {
+		props = `<w:pStyle w:val="` + style + `"/>` + props
+	}
+	if props != "" {
+		props = "<w:pPr>" + props + "</w:pPr>"
+	}
+	return "<w:p>" + props + runs + "</w:p>"
+}
+
+// docxImage 生成引用关系 rel 的嵌入图片
+func docxImage(rel, alt string) string {
+	return `<w:r><w:drawing><wp:inline><wp:docPr id="1" name="p" descr="` + alt + `"/>` +
+		`<a:graphic><a:graphicData><a:blip r:embed="` + rel + `"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`
+}
+
+// docxArchive 将部件打包为 DOCX
+func docxArchive(t *testing.T, parts map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(parts[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+// TestImportDOCX 标题、列表、表格、链接、脚注、代码段落和嵌入图片；指向 word/ 之外的图片不引用
+func TestImportDOCX(t *testing.T) {
+	r := docxArchive(t, docxFixture)
+	result, err := ImportDOCX(r, r.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "---\ntitle: \"文档标题\"\nauthor: \"作者\"\ndate: \"2024-05-06\"\n---\n\n" +
+		"# 一级标题\n\n" +
+		"## 二级标题\n\n" +
+		"正文 **粗体** *斜体* `code` [链接](https://example.com/doc) [域链接](https://example.com/field)[^2]\n\n" +
+		"1. 第一项\n  - 子项\n2. 第二项\n\n" +
+		"间隔\n\n" +
+		"- 圆点\n- 圆点二\n\n" +
+		"| 合并 | < |\n| --- | --- |\n| a\\|b | 1 |\n| ^ | 2 |\n\n" +
+		"```\nfunc main() {\n\treturn\n}\n```\n\n" +
+		"> 引用\n\n" +
+		"![图片](media/image1.png)\n\n" +
+		"[^2]: 脚注**内容**\n"
+	if result.Markdown != want {
+		t.Errorf("markdown:\n%s\nwant:\n%s", result.Markdown, want)
+	}
+	if want := []string{"media/image1.png"}; !slices.Equal(result.Images, want) {
+		t.Errorf("images = %q, want %q", result.Images, want)
+	}
+
+	dir := t.TempDir()
+	if err := result.SaveMedia(dir); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "media", "image1.png")); err != nil || string(data) != "PNG" {
+		t.Errorf("saved image = %q, %v", data, err)
+	}
+}
+
+// TestImportDOCXInvalid 不是 zip 或缺少 document.xml 时返回 ErrInvalidDOCX，没有正文时返回 ErrNoContent
+func TestImportDOCXInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		parts map[string]string
+		want  error
+	}{
+		{"no document", map[string]string{"word/styles.xml": "<w:styles/>"}, ErrInvalidDOCX},
+		{"malformed document", map[string]string{"word/document.xml": "<w:document><w:body>"}, ErrInvalidDOCX},
+		{"no body", map[string]string{"word/document.xml": "<w:document " + docxNS + "/>"}, ErrInvalidDOCX},
+		{"empty body", map[string]string{"word/document.xml": "<w:document " + docxNS + "><w:body><w:p/></w:body></w:document>"}, ErrNoContent},
+	} {
+		r := docxArchive(t, tc.parts)
+		if _, err := ImportDOCX(r, r.Size()); !errors.Is(err, tc.want) {
+			t.Errorf("%s: err = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	r := strings.NewReader("not a zip")
+	if _, err := ImportDOCX(r, r.Size()); !errors.Is(err, ErrInvalidDOCX) {
+		t.Errorf("not a zip: err = %v, want ErrInvalidDOCX", err)
+	}
+}
+
+// TestSaveMediaStaysInDir SaveMedia 只写入 dir 之内，.. 和绝对路径的图片跳过，缺少的图片记录警告
+func TestSaveMediaStaysInDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	result := &ImportResult{
+		Images: []string{"../escape.png", "/abs.png", "media/../../up.png", "https://example.com/r.png", "media/a.png", "media/missing.png"},
+		Media: fstest.MapFS{
+			"escape.png":  {Data: []byte("x")},
+			"abs.png":     {Data: []byte("x")},
+			"up.png":      {Data: []byte("x")},
+			"media/a.png": {Data: []byte("A")},
+		},
+	}
+	if err := result.SaveMedia(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var written []string
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(root, path)
+			written = append(written, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if want := []string{"out/media/a.png"}; !slices.Equal(written, want) {
+		t.Errorf("written files = %q, want %q", written, want)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "media/missing.png") {
+		t.Errorf("warnings = %q, want one for media/missing.png", result.Warnings)
+	}
+}
@@ -0,0 +1,579 @@
+package converter
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EPUBSplit 电子书的分章方式
+type EPUBSplit string
+
+const (
+	// EPUBSplitArticle 每篇文章一章
+	EPUBSplitArticle EPUBSplit = "article"
+	// EPUBSplitH1 每个一级标题一章
+	EPUBSplitH1 EPUBSplit = "h1"
+)
+
+// ErrNoArticles 没有可以写入电子书的文章
+var ErrNoArticles = errors.New("no articles")
+
+// EPUBArticle 电子书中的一篇文章
+type EPUBArticle struct {
+	Markdown string
+	// Images 相对路径图片的解析方式，图片都会嵌入电子书
+	Images ImageOptions
+}
+
+// EPUBOptions 电子书元信息和选项，为空的字段取第一篇文章的 front matter
+type EPUBOptions struct {
+	// Title 书名，默认取 front matter 的 series，单篇文章时取文章标题
+	Title       string
+	Author      string
+	Language    string
+	Description string
+	// Date 出版日期，YYYY-MM-DD
+	Date string
+	// Identifier 唯一标识，默认由书名和作者生成 urn:uuid
+	Identifier string
+	// ThemeCSS 主题样式表原文
+	ThemeCSS string
+	Split    EPUBSplit
+	// Modified 修改时间，为零时使用当前时间
+	Modified time.Time
+}
+
+// EPUBWarning 带文章序号（从 0 开始）的转换警告
+type EPUBWarning struct {
+	Article int `json:"article"`
+	Warning
+}
+
+// epubProfile 电子书输出规则：保留链接，公式转为图片后嵌入
+var epubProfile = Profile{
+	Name:         "epub",
+	LinkPolicy:   LinkInline,
+	MathMode:     MathImage,
+	MathImageURL: DefaultMathImageURL,
+	CodeStyle:    CodeSection,
+	TableMode:    TableStandard,
+	DefaultTheme: DefaultTheme,
+}
+
+// epubCSS 电子书基础样式
+const epubCSS = `#wenyan img { max-width: 100%; }
+#wenyan table { border-collapse: collapse; }
+`
+
+// epubChapter 电子书中的一章
+type epubChapter struct {
+	File     string
+	Title    string
+	Body     string
+	Headings []epubHeading
+}
+
+// epubHeading 章节中可以跳转的标题
+type epubHeading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// epubImage 嵌入电子书的图片
+type epubImage struct {
+	Href      string
+	MediaType string
+	Data      []byte
+}
+
+// epubBook 写入过程中的电子书内容
+type epubBook struct {
+	chapters []epubChapter
+	images   []epubImage
+	// byHash、byURL 按内容和远程地址去重
+	byHash   map[string]string
+	byURL    map[string]string
+	warnings []EPUBWarning
+	// fetch 下载远程图片，受转换器的 ImageFetcher 限制
+	fetch func(src string) ([]byte, error)
+}
+
+// WriteEPUB 将文章转换为 EPUB 3 电子书写入 w，转换时使用电子书的输出规则；
+// 图片和公式图片都嵌入电子书，无法嵌入的图片替换为替代文字
+func (c *WechatConverter) WriteEPUB(w io.Writer, articles []EPUBArticle, opts EPUBOptions) ([]EPUBWarning, error) {
+	if len(articles) == 0 {
+		return nil, ErrNoArticles
+	}
+	switch opts.Split {
+	case "":
+		opts.Split = EPUBSplitArticle
+	case EPUBSplitArticle, EPUBSplitH1:
+	default:
+		return nil, fmt.Errorf("unknown split mode: %s", opts.Split)
+	}
+
+	saved := c.profile
+	c.SetProfile(epubProfile)
+	defer c.SetProfile(saved)
+
+	opts = resolveEPUBOptions(articles, opts)
+	book := &epubBook{byHash: make(map[string]string), byURL: make(map[string]string), fetch: c.fetchImage}
+
+	for i, article := range articles {
+		images := article.Images
+		if images.InlineMaxBytes == 0 {
+			// 本地图片先内联为 data URI，再统一提取为电子书中的文件
+			images.InlineMaxBytes = maxFetchBytes
+		}
+		c.SetImageOptions(images)
+
+		for _, part := range splitChapters(article.Markdown, opts.Split) {
+			body := c.ConvertMarkdownToWechat(part)
+			for _, warning := range c.Warnings() {
+				book.warnings = append(book.warnings, EPUBWarning{Article: i, Warning: warning})
+			}
+
+			root := parseHTML(body)
+			book.embedImages(root, i)
+			chapter := epubChapter{
+				File:     fmt.Sprintf("chapter-%03d.xhtml", len(book.chapters)+1),
+				Headings: collectHeadings(root),
+			}
+			chapter.Title = chapterTitle(Parse(part), chapter.Headings, opts.Split, len(book.chapters)+1)
+			chapter.Body = renderXHTML(root)
+			book.chapters = append(book.chapters, chapter)
+		}
+	}
+
+	return book.warnings, book.write(w, opts)
+}
+
+// resolveEPUBOptions 用第一篇文章的 front matter 补全元信息
+func resolveEPUBOptions(articles []EPUBArticle, opts EPUBOptions) EPUBOptions {
+	doc := Parse(articles[0].Markdown)
+	fm := doc.FrontMatter
+
+	if opts.Title == "" {
+		opts.Title = fm.Get("series")
+	}
+	if opts.Title == "" && len(articles) == 1 {
+		opts.Title = documentTitle(doc)
+	}
+	if opts.Title == "" {
+		opts.Title = "Untitled"
+	}
+	if opts.Author == "" {
+		opts.Author = fm.Get("author")
+	}
+	if opts.Language == "" {
+		opts.Language = fm.Get("lang", "language")
+	}
+	if opts.Language == "" {
+		opts.Language = "zh-CN"
+	}
+	if opts.Description == "" && len(articles) == 1 {
+		opts.Description = fm.Get("description", "digest", "summary")
+	}
+	if opts.Date == "" {
+		opts.Date = fm.Get("date")
+	}
+	// dc:date 只接受 W3C 日期格式
+	if len(opts.Date) >= 10 {
+		if _, err := time.Parse("2006-01-02", opts.Date[:10]); err == nil {
+			opts.Date = opts.Date[:10]
+		} else {
+			opts.Date = ""
+		}
+	} else {
+		opts.Date = ""
+	}
+	if opts.Identifier == "" {
+		opts.Identifier = bookUUID(opts.Title, opts.Author)
+	}
+	if opts.Modified.IsZero() {
+		opts.Modified = time.Now()
+	}
+	return opts
+}
+
+// bookUUID 由书名和作者生成稳定的 urn:uuid，同一本书重复导出时标识不变
+func bookUUID(title, author string) string {
+	sum := sha1.Sum([]byte(title + "\x00" + author))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	h := hex.EncodeToString(sum[:16])
+	return fmt.Sprintf("urn:uuid:%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// splitChapters 按分章方式拆分文章；拆分后的每一部分前面补空行，警告中的行号仍对应原文
+func splitChapters(markdown string, split EPUBSplit) []string {
+	if split != EPUBSplitH1 {
+		return []string{markdown}
+	}
+
+	doc := Parse(markdown)
+	lines := strings.Split(markdown, "\n")
+	var starts []int
+	for _, block := range doc.Blocks {
+		if block.Type == BlockHeading && block.Level == 1 {
+			starts = append(starts, block.StartLine-1)
+		}
+	}
+	// 第一个一级标题之前只有 front matter 或空行时，不单独成章
+	if len(starts) > 0 && strings.TrimSpace(strings.Join(strings.Split(blankFrontMatter(markdown), "\n")[:starts[0]], "\n")) == "" {
+		starts = starts[1:]
+	}
+	if len(starts) == 0 {
+		return []string{markdown}
+	}
+
+	parts := make([]string, 0, len(starts)+1)
+	prev := 0
+	for _, start := range append(starts, len(lines)) {
+		padding := strings.Repeat("\n", prev)
+		parts = append(parts, padding+strings.Join(lines[prev:start], "\n"))
+		prev = start
+	}
+	return parts
+}
+
+// chapterTitle 返回章节标题：按文章分章时优先取 front matter 的标题，否则取第一个标题
+func chapterTitle(doc *Document, headings []epubHeading, split EPUBSplit, n int) string {
+	if split == EPUBSplitArticle {
+		if title := doc.FrontMatter.Get("title"); title != "" {
+			return title
+		}
+	}
+	if len(headings) > 0 {
+		return headings[0].Text
+	}
+	return fmt.Sprintf("Chapter %d", n)
+}
+
+// collectHeadings 为一至三级标题设置 id 并按文档顺序返回
+func collectHeadings(root *htmlNode) []epubHeading {
+	var headings []epubHeading
+	n := 0
+	var walk func(*htmlNode)
+	walk = func(node *htmlNode) {
+		for _, child := range node.Children {
+			if len(child.Tag) == 2 && child.Tag[0] == 'h' && child.Tag[1] >= '1' && child.Tag[1] <= '3' {
+				n++
+				if child.Attrs["id"] == "" {
+					child.Attrs["id"] = fmt.Sprintf("sec-%d", n)
+				}
+				text := strings.Join(strings.Fields(child.textContent()), " ")
+				if text != "" {
+					headings = append(headings, epubHeading{Level: int(child.Tag[1] - '0'), ID: child.Attrs["id"], Text: text})
+				}
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(root)
+	return headings
+}
+
+// embedImages 把图片提取为电子书中的文件，无法嵌入的图片替换为替代文字
+func (b *epubBook) embedImages(root *htmlNode, article int) {
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		for _, child := range n.Children {
+			if child.Tag != "img" {
+				walk(child)
+				continue
+			}
+			src := child.Attrs["src"]
+			href, err := b.addImage(src)
+			if err != nil {
+				b.warnings = append(b.warnings, EPUBWarning{Article: article, Warning: Warning{Message: fmt.Sprintf("image not embedded: %s: %v", truncateSrc(src), err)}})
+				child.Tag, child.Text, child.Attrs = "", child.Attrs["alt"], nil
+				continue
+			}
+			child.Attrs["src"] = href
+			if _, ok := child.Attrs["alt"]; !ok {
+				child.Attrs["alt"] = ""
+			}
+		}
+	}
+	walk(root)
+}
+
+// addImage 嵌入单张图片，返回电子书中的路径
+func (b *epubBook) addImage(src string) (string, error) {
+	if href, ok := b.byURL[src]; ok {
+		return href, nil
+	}
+
+	var (
+		data      []byte
+		mediaType string
+		err       error
+	)
+	switch {
+	case strings.HasPrefix(strings.ToLower(src), "data:"):
+		data, mediaType, err = decodeDataURI(src)
+	case isRemoteImage(src):
+		data, err = b.fetch(src)
+		if err == nil {
+			name := src
+			if u, perr := url.Parse(src); perr == nil {
+				name = u.Path
+			}
+			mediaType = imageMimeType(name, data)
+		}
+	default:
+		err = errors.New("local image not resolved")
+	}
+	if err != nil {
+		return "", err
+	}
+	ext := extensionForMime(mediaType)
+	if ext == "" {
+		return "", fmt.Errorf("unsupported image type %s", mediaType)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	href, ok := b.byHash[hash]
+	if !ok {
+		href = "images/" + hash[:16] + ext
+		b.byHash[hash] = href
+		b.images = append(b.images, epubImage{Href: href, MediaType: mediaType, Data: data})
+	}
+	if isRemoteImage(src) {
+		b.byURL[src] = href
+	}
+	return href, nil
+}
+
+// truncateSrc 截断警告中过长的 data URI
+func truncateSrc(src string) string {
+	if len(src) > 64 {
+		return src[:64] + "..."
+	}
+	return src
+}
+
+// renderXHTML 将节点树输出为格式良好的 XHTML 片段
+func renderXHTML(root *htmlNode) string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.Tag == "" {
+			b.WriteString(html.EscapeString(n.Text))
+			return
+		}
+		if n.Tag == "script" || n.Tag == "style" {
+			return
+		}
+		if n.Tag != "#root" {
+			b.WriteString("<" + n.Tag)
+			keys := make([]string, 0, len(n.Attrs))
+			for key := range n.Attrs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(&b, ` %s="%s"`, key, html.EscapeString(n.Attrs[key]))
+			}
+			if voidTags[n.Tag] {
+				b.WriteString(" />")
+				return
+			}
+			b.WriteString(">")
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+		if n.Tag != "#root" {
+			b.WriteString("</" + n.Tag + ">")
+		}
+	}
+	walk(root)
+	return b.String()
+}
+
+// write 输出 EPUB 压缩包，mimetype 必须是第一个且不压缩
+func (b *epubBook) write(w io.Writer, opts EPUBOptions) error {
+	zw := zip.NewWriter(w)
+
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: opts.Modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", b.packageDocument(opts)},
+		{"OEBPS/nav.xhtml", b.navDocument(opts)},
+		{"OEBPS/style.css", epubCSS + opts.ThemeCSS},
+	}
+	for _, ch := range b.chapters {
+		files = append(files, struct {
+			name string
+			data string
+		}{"OEBPS/" + ch.File, chapterDocument(ch, opts.Language)})
+	}
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: opts.Modified})
+	}
+	for _, f := range files {
+		fw, err := create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.data); err != nil {
+			return err
+		}
+	}
+	for _, img := range b.images {
+		fw, err := create("OEBPS/" + img.Href)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(img.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// packageDocument 生成 content.opf：元信息、文件清单和阅读顺序
+func (b *epubBook) packageDocument(opts EPUBOptions) string {
+	esc := html.EscapeString
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&s, `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid" xml:lang="%s">`+"\n", esc(opts.Language))
+	s.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&s, "    <dc:identifier id=\"bookid\">%s</dc:identifier>\n", esc(opts.Identifier))
+	fmt.Fprintf(&s, "    <dc:title>%s</dc:title>\n", esc(opts.Title))
+	fmt.Fprintf(&s, "    <dc:language>%s</dc:language>\n", esc(opts.Language))
+	if opts.Author != "" {
+		fmt.Fprintf(&s, "    <dc:creator>%s</dc:creator>\n", esc(opts.Author))
+	}
+	if opts.Date != "" {
+		fmt.Fprintf(&s, "    <dc:date>%s</dc:date>\n", esc(opts.Date))
+	}
+	if opts.Description != "" {
+		fmt.Fprintf(&s, "    <dc:description>%s</dc:description>\n", esc(opts.Description))
+	}
+	fmt.Fprintf(&s, "    <meta property=\"dcterms:modified\">%s</meta>\n", opts.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	s.WriteString("  </metadata>\n  <manifest>\n")
+	s.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	s.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for i, ch := range b.chapters {
+		fmt.Fprintf(&s, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, ch.File)
+	}
+	for i, img := range b.images {
+		fmt.Fprintf(&s, "    <item id=\"image-%d\" href=\"%s\" media-type=\"%s\"/>\n", i+1, img.Href, img.MediaType)
+	}
+	s.WriteString("  </manifest>\n  <spine>\n")
+	for i := range b.chapters {
+		fmt.Fprintf(&s, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	s.WriteString("  </spine>\n</package>\n")
+	return s.String()
+}
+
+// navPoint 目录中的一项
+type navPoint struct {
+	Level    int
+	Title    string
+	Href     string
+	Children []*navPoint
+}
+
+// navDocument 生成目录：每章一项，章内的标题按层级嵌套
+func (b *epubBook) navDocument(opts EPUBOptions) string {
+	var points []*navPoint
+	for _, ch := range b.chapters {
+		point := &navPoint{Title: ch.Title, Href: ch.File}
+		stack := []*navPoint{point}
+		for i, h := range ch.Headings {
+			// 与章节标题相同的第一个标题不再重复列出
+			if i == 0 && h.Text == ch.Title {
+				continue
+			}
+			for len(stack) > 1 && stack[len(stack)-1].Level >= h.Level {
+				stack = stack[:len(stack)-1]
+			}
+			child := &navPoint{Level: h.Level, Title: h.Text, Href: ch.File + "#" + h.ID}
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, child)
+			stack = append(stack, child)
+		}
+		points = append(points, point)
+	}
+
+	contents := "Contents"
+	if strings.HasPrefix(strings.ToLower(opts.Language), "zh") {
+		contents = "目录"
+	}
+
+	var s strings.Builder
+	var writeList func([]*navPoint, string)
+	writeList = func(list []*navPoint, indent string) {
+		s.WriteString(indent + "<ol>\n")
+		for _, p := range list {
+			fmt.Fprintf(&s, "%s  <li><a href=\"%s\">%s</a>", indent, html.EscapeString(p.Href), html.EscapeString(p.Title))
+			if len(p.Children) > 0 {
+				s.WriteString("\n")
+				writeList(p.Children, indent+"    ")
+				s.WriteString(indent + "  ")
+			}
+			s.WriteString("</li>\n")
+		}
+		s.WriteString(indent + "</ol>\n")
+	}
+	writeList(points, "    ")
+
+	return xhtmlHead(opts.Language, opts.Title, false) +
+		fmt.Sprintf("<body>\n  <nav epub:type=\"toc\" id=\"toc\">\n    <h1>%s</h1>\n", contents) +
+		s.String() + "  </nav>\n</body>\n</html>\n"
+}
+
+// chapterDocument 生成章节 XHTML，正文放在 #wenyan 中以应用主题样式
+func chapterDocument(ch epubChapter, lang string) string {
+	return xhtmlHead(lang, ch.Title, true) +
+		"<body>\n<section id=\"wenyan\">" + ch.Body + "</section>\n</body>\n</html>\n"
+}
+
+// xhtmlHead 生成 XHTML 文档开头
+func xhtmlHead(lang, title string, stylesheet bool) string {
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<!DOCTYPE html>\n")
+	fmt.Fprintf(&s, `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="%s" xml:lang="%s">`+"\n", html.EscapeString(lang), html.EscapeString(lang))
+	fmt.Fprintf(&s, "<head>\n  <meta charset=\"UTF-8\"/>\n  <title>%s</title>\n", html.EscapeString(title))
+	if stylesheet {
+		s.WriteString(`  <link rel="stylesheet" type="text/css" href="style.css"/>` + "\n")
+	}
+	s.WriteString("</head>\n")
+	return s.String()
+}
@@ -0,0 +1,200 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxFetchBytes 远程图片下载大小上限
+const maxFetchBytes = 10 << 20
+
+// fetchTimeout 单张远程图片的下载超时
+const fetchTimeout = 30 * time.Second
+
+// ErrFetchBlocked 远程图片地址不在允许范围内，或超出下载次数、总大小限制
+var ErrFetchBlocked = errors.New("remote image blocked")
+
+// ImageFetcher 限制转换时下载的远程图片，用于处理不可信的请求；
+// 计数在同一个 ImageFetcher 上累计，每个请求应创建新的实例
+type ImageFetcher struct {
+	// AllowHosts 允许下载的主机名，为空时不限制主机
+	AllowHosts []string
+	// PublicOnly 只允许连接公网地址，拒绝回环、内网、链路本地（含云服务元数据）等地址；
+	// 按解析后实际连接的 IP 检查，重定向和 DNS 重绑定也无法绕过
+	PublicOnly bool
+	// MaxImages 最多下载的图片数，失败的下载也计数，0 表示不限制
+	MaxImages int
+	// MaxTotalBytes 所有图片的总大小上限，0 表示不限制；单张图片始终不超过 10MB
+	MaxTotalBytes int64
+
+	mu     sync.Mutex
+	count  int
+	total  int64
+	client *http.Client
+}
+
+// SetImageFetcher 设置远程图片的下载限制，为 nil 时只限制单张图片大小
+func (c *WechatConverter) SetImageFetcher(f *ImageFetcher) {
+	c.fetcher = f
+}
+
+// fetchImage 按转换器的下载限制下载远程图片
+func (c *WechatConverter) fetchImage(src string) ([]byte, error) {
+	if c.fetcher == nil {
+		return fetchImage(src)
+	}
+	return c.fetcher.Fetch(src)
+}
+
+// FetchImage 下载远程图片，超过 10MB 时返回错误
+func FetchImage(src string) ([]byte, error) {
+	return fetchImage(src)
+}
+
+// fetchImage 下载远程图片
+func fetchImage(src string) ([]byte, error) {
+	data, err := fetchURL(&http.Client{Timeout: fetchTimeout}, src, maxFetchBytes)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Fetch 检查地址和剩余额度后下载远程图片
+func (f *ImageFetcher) Fetch(src string) ([]byte, error) {
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if !f.allowHost(u.Hostname()) {
+		return nil, fmt.Errorf("%w: host %s is not allowed", ErrFetchBlocked, u.Hostname())
+	}
+
+	f.mu.Lock()
+	if f.MaxImages > 0 && f.count >= f.MaxImages {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("%w: more than %d remote images", ErrFetchBlocked, f.MaxImages)
+	}
+	limit := int64(maxFetchBytes)
+	if f.MaxTotalBytes > 0 {
+		if remaining := f.MaxTotalBytes - f.total; remaining < limit {
+			limit = remaining
+		}
+		if limit <= 0 {
+			f.mu.Unlock()
+			return nil, fmt.Errorf("%w: remote images exceed %d bytes in total", ErrFetchBlocked, f.MaxTotalBytes)
+		}
+	}
+	f.count++
+	if f.client == nil {
+		f.client = f.newClient()
+	}
+	client := f.client
+	f.mu.Unlock()
+
+	// 超过剩余额度的图片已下载的部分也计入总大小
+	data, err := fetchURL(client, src, limit)
+	f.mu.Lock()
+	f.total += int64(len(data))
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// allowHost 判断主机是否在 AllowHosts 中，AllowHosts 为空时都允许
+func (f *ImageFetcher) allowHost(host string) bool {
+	if len(f.AllowHosts) == 0 {
+		return true
+	}
+	for _, allowed := range f.AllowHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// newClient 创建下载用的客户端：重定向的目标也要在 AllowHosts 中；PublicOnly 时在建立连接前检查 IP，且不经过代理
+func (f *ImageFetcher) newClient() *http.Client {
+	client := &http.Client{
+		Timeout: fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !f.allowHost(req.URL.Hostname()) {
+				return fmt.Errorf("%w: host %s is not allowed", ErrFetchBlocked, req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	if !f.PublicOnly {
+		return client
+	}
+
+	dialer := &net.Dialer{
+		Timeout: fetchTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: address %s is not public", ErrFetchBlocked, host)
+			}
+			return nil
+		},
+	}
+	client.Transport = &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return client
+}
+
+// sharedAddressSpace 运营商级 NAT 地址段 100.64.0.0/10
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// publicIP 判断 IP 是否为公网单播地址
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip))
+}
+
+// fetchURL 下载远程图片，超过 limit 字节时返回错误和已读取的 limit+1 字节
+func fetchURL(client *http.Client, src string, limit int64) ([]byte, error) {
+	if strings.HasPrefix(src, "//") {
+		src = "https:" + src
+	}
+	resp, err := client.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return data, fmt.Errorf("image larger than %d bytes", limit)
+	}
+	return data, nil
+}
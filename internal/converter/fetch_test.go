@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newImageServer 返回每次请求都输出 size 字节的图片服务和请求计数
+func newImageServer(t *testing.T, size int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "http://example.invalid/a.png", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, strings.Repeat("x", size))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// TestImageFetcherLimits 地址、主机、数量和总大小超出限制时不再发起请求
+func TestImageFetcherLimits(t *testing.T) {
+	server, requests := newImageServer(t, 100)
+	host := strings.Split(strings.TrimPrefix(server.URL, "http://"), ":")[0]
+
+	for _, tc := range []struct {
+		name    string
+		fetcher *ImageFetcher
+		srcs    []string
+		// ok 成功的下载数，requests 实际发出的请求数
+		ok, requests int
+	}{
+		{"unrestricted", &ImageFetcher{}, []string{"/a.png", "/b.png"}, 2, 2},
+		{"loopback", &ImageFetcher{PublicOnly: true}, []string{"/a.png"}, 0, 0},
+		{"allowed host", &ImageFetcher{AllowHosts: []string{host}}, []string{"/a.png"}, 1, 1},
+		{"other host", &ImageFetcher{AllowHosts: []string{"example.com"}}, []string{"/a.png"}, 0, 0},
+		{"redirect to other host", &ImageFetcher{AllowHosts: []string{host}}, []string{"/redirect"}, 0, 1},
+		{"max images", &ImageFetcher{MaxImages: 2}, []string{"/a.png", "/b.png", "/c.png"}, 2, 2},
+		{"max total bytes", &ImageFetcher{MaxTotalBytes: 250}, []string{"/a.png", "/b.png", "/c.png", "/d.png"}, 2, 3},
+	} {
+		*requests = 0
+		ok := 0
+		for _, src := range tc.srcs {
+			if _, err := tc.fetcher.Fetch(server.URL + src); err == nil {
+				ok++
+			}
+		}
+		if ok != tc.ok || *requests != tc.requests {
+			t.Errorf("%s: %d fetched with %d requests, want %d with %d", tc.name, ok, *requests, tc.ok, tc.requests)
+		}
+	}
+}
+
+// TestImageFetcherBlocksPrivateAddresses 回环、内网、链路本地和元数据地址都不能连接
+func TestImageFetcherBlocksPrivateAddresses(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00::1", "fe80::1", "::ffff:127.0.0.1"} {
+		if publicIP(net.ParseIP(addr)) {
+			t.Errorf("%s is treated as public", addr)
+		}
+	}
+	for _, addr := range []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"} {
+		if !publicIP(net.ParseIP(addr)) {
+			t.Errorf("%s is treated as private", addr)
+		}
+	}
+
+	// 主机名解析到回环地址时同样在连接前拒绝
+	server, requests := newImageServer(t, 10)
+	u, _ := url.Parse(server.URL)
+	f := &ImageFetcher{PublicOnly: true}
+	_, err := f.Fetch("http://localhost:" + u.Port() + "/a.png")
+	if !errors.Is(err, ErrFetchBlocked) || *requests != 0 {
+		t.Errorf("localhost: error %v after %d requests, want ErrFetchBlocked before connecting", err, *requests)
+	}
+}
+
+// TestInlineRemoteImagesLimited 内联远程图片受转换器的下载限制，超出的图片保留原地址并给出警告
+func TestInlineRemoteImagesLimited(t *testing.T) {
+	server, _ := newImageServer(t, 3)
+	markdown := fmt.Sprintf("![a](%[1]s/a.png)\n\n![b](%[1]s/b.png)\n", server.URL)
+
+	conv := NewWechatConverterFixed()
+	conv.SetImageFetcher(&ImageFetcher{MaxImages: 1})
+	doc := conv.ConvertToDocument(markdown, DocumentOptions{InlineImages: true})
+	if !strings.Contains(doc, "data:image/png;base64,eHh4") || !strings.Contains(doc, server.URL+"/b.png") {
+		t.Errorf("want first image inlined and second kept:\n%s", doc)
+	}
+	if len(conv.Warnings()) != 1 || !strings.Contains(conv.Warnings()[0].Message, "more than 1 remote images") {
+		t.Errorf("warnings = %v", conv.Warnings())
+	}
+
+	conv = NewWechatConverterFixed()
+	conv.SetImageFetcher(&ImageFetcher{PublicOnly: true})
+	doc = conv.ConvertToDocument(markdown, DocumentOptions{InlineImages: true})
+	if strings.Contains(doc, "data:image") {
+		t.Errorf("loopback image was inlined:\n%s", doc)
+	}
+}
+
+// TestEPUBRemoteImagesLimited 电子书嵌入远程图片受转换器的下载限制，超出的图片替换为替代文字
+func TestEPUBRemoteImagesLimited(t *testing.T) {
+	server, requests := newImageServer(t, 3)
+	markdown := fmt.Sprintf("# 标题\n\n![a](%[1]s/a.png)\n\n![b](%[1]s/b.png)\n", server.URL)
+
+	for _, tc := range []struct {
+		name     string
+		fetcher  *ImageFetcher
+		warnings int
+		requests int
+	}{
+		{"max images", &ImageFetcher{MaxImages: 1}, 1, 1},
+		{"loopback", &ImageFetcher{PublicOnly: true}, 2, 0},
+	} {
+		*requests = 0
+		conv := NewWechatConverterFixed()
+		conv.SetImageFetcher(tc.fetcher)
+		warnings, err := conv.WriteEPUB(io.Discard, []EPUBArticle{{Markdown: markdown}}, EPUBOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(warnings) != tc.warnings || *requests != tc.requests {
+			t.Errorf("%s: %d warnings after %d requests, want %d after %d: %v", tc.name, len(warnings), *requests, tc.warnings, tc.requests, warnings)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	footnoteDefRegex = regexp.MustCompile(`(?m)^\[\^([^\]\s]+)\]:[ \t]*(.*)$`)
+	footnoteRefRegex = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+)
+
+// extractFootnoteDefs 提取 [^id]: 文本 形式的脚注定义并从正文中删除，定义加入 noteDefs 中已有的定义；
+// 同一 id 有多个定义时使用第一个
+func (c *WechatConverter) extractFootnoteDefs(text string) string {
+	if !strings.Contains(text, "[^") {
+		return text
+	}
+	return footnoteDefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := footnoteDefRegex.FindStringSubmatch(match)
+		if _, ok := c.noteDefs[m[1]]; !ok {
+			c.noteDefs[m[1]] = strings.TrimSpace(m[2])
+		}
+		return ""
+	})
+}
+
+// processFootnoteRefs 将 [^id] 引用转换为上标编号，脚注内容与链接脚注一起列在文末
+func (c *WechatConverter) processFootnoteRefs(text string) string {
+	if len(c.noteDefs) == 0 {
+		return text
+	}
+	return footnoteRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		// 正文已经转义，定义按原文保存
+		id := html.UnescapeString(footnoteRefRegex.FindStringSubmatch(match)[1])
+		def, ok := c.noteDefs[id]
+		if !ok {
+			return match
+		}
+		n, ok := c.noteNumbers[id]
+		if !ok {
+			c.footnotes = append(c.footnotes, def)
+			n = len(c.footnotes)
+			c.noteNumbers[id] = n
+		}
+		return c.inlineToken(fmt.Sprintf(`<sup>[%d]</sup>`, n))
+	})
+}
+
+// Footnotes 返回最近一次转换生成的脚注文本（不含编号），包括链接脚注
+func (c *WechatConverter) Footnotes() []string {
+	return c.footnotes
+}
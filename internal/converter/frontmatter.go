@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"strings"
+)
+
+// FrontMatter 文章头部的 YAML 元信息，仅支持 key: value 形式
+type FrontMatter map[string]string
+
+// Get 按顺序返回第一个非空字段，用于兼容字段别名
+func (fm FrontMatter) Get(keys ...string) string {
+	for _, key := range keys {
+		if v := fm[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseFrontMatter 拆分文章开头 --- 包裹的元信息，返回元信息和正文
+func ParseFrontMatter(markdown string) (FrontMatter, string) {
+	fm, end := parseFrontMatter(markdown)
+	if end < 0 {
+		return fm, markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	return fm, strings.Join(lines[end+1:], "\n")
+}
+
+// blankFrontMatter 将元信息替换为空行，保持正文行号不变
+func blankFrontMatter(markdown string) string {
+	_, end := parseFrontMatter(markdown)
+	if end < 0 {
+		return markdown
+	}
+	lines := strings.Split(markdown, "\n")
+	for i := 0; i <= end; i++ {
+		lines[i] = ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseFrontMatter 解析元信息，返回结束分隔符所在行（从 0 开始），没有元信息时为 -1
+func parseFrontMatter(markdown string) (FrontMatter, int) {
+	fm := make(FrontMatter)
+	markdown = strings.TrimPrefix(markdown, "\ufeff")
+	if !strings.HasPrefix(markdown, "---") {
+		return fm, -1
+	}
+
+	lines := strings.Split(markdown, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return fm, -1
+	}
+
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if strings.TrimSpace(line) == "---" || strings.TrimSpace(line) == "..." {
+			return fm, i
+		}
+
+		// 跳过注释、列表项和嵌套字段
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:colon])
+		fm[key] = unquoteYAML(strings.TrimSpace(line[colon+1:]))
+	}
+
+	// 没有结束分隔符，不视为元信息
+	return make(FrontMatter), -1
+}
+
+// unquoteYAML 去掉 YAML 标量两侧的引号
+func unquoteYAML(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' && last == '"') || (first == '\'' && last == '\'') {
+			value = value[1 : len(value)-1]
+			if first == '"' {
+				value = strings.ReplaceAll(value, `\"`, `"`)
+			} else {
+				value = strings.ReplaceAll(value, `''`, `'`)
+			}
+		}
+	}
+	return value
+}
@@ -0,0 +1,220 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fuzzSeeds 种子语料：示例文章和容易出错的写法；发现问题的输入保存在 testdata/fuzz 中
+var fuzzSeeds = []string{
+	"",
+	"# 标题\n\n正文 **粗体** *斜体* `代码` [链接](https://example.com) ![图](a.png)",
+	"<script>alert(1)</script>",
+	"<img src=x onerror=alert(1)>",
+	"a <b>c</b> & d \"e\" 'f'",
+	"[x](javascript:alert(1)) [y](JaVa\tScRiPt:1) ![z](data:text/html,1)",
+	"![a\"b](x\" onerror=\"y)",
+	"*a **b** c* `x*y` [a*](u) b* ![a*b](u) c*",
+	"**a\nb** *c\nd*",
+	"| a | b |\n|---|---|\n| <x> | [l](u?a=1&b=2) `|` *y* |",
+	"> <q> > b\n- <li>\n1. <ol>\n• <u>",
+	"```html\n<div>\n```\n\n`<c>`\n\n$$<m>$$ $<n>$",
+	"[^a&b] x\n\n[^a&b]: note <n>",
+	"\x00i0\x00 \ue0001\ue001 __CODE_BLOCK_0__ __MATH_INLINE_0__",
+	"Look __CODE_BLOCK_0__ here\n```\n<b>\n```\n\n$$\nx\n$$ __MATH_BLOCK_0__",
+	"x [a```\nb\n```](u) $$y$$ **z$$w$$**",
+	"1.\n\n内容\n\n---\ntitle: t\n---\n{{reading_time}}",
+}
+
+var (
+	fuzzTagRegex    = regexp.MustCompile(`^<(/?)([a-z][a-z0-9]*)((?:\s+[a-z][a-z-]*="[^"<>]*")*)\s*(/?)>`)
+	fuzzAttrRegex   = regexp.MustCompile(`([a-z][a-z-]*)="([^"]*)"`)
+	fuzzEntityRegex = regexp.MustCompile(`^&(?:[a-zA-Z][a-zA-Z0-9]*|#[0-9]+|#x[0-9a-fA-F]+);`)
+)
+
+// fuzzTags 转换器会生成的标签和属性，输出中出现其他标签或属性说明用户输入被当作 HTML 输出
+var (
+	fuzzTags = map[string]bool{
+		"a": true, "blockquote": true, "br": true, "code": true, "em": true, "h1": true, "h2": true,
+		"h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "img": true, "li": true, "ol": true,
+		"p": true, "pre": true, "section": true, "span": true, "strong": true, "sup": true, "table": true,
+		"tbody": true, "td": true, "th": true, "thead": true, "tr": true, "ul": true,
+	}
+	fuzzAttrs = map[string]bool{
+		"alt": true, "class": true, "colspan": true, "data-line": true, "eeimg": true, "href": true,
+		"rowspan": true, "src": true, "style": true,
+	}
+	fuzzVoidTags = map[string]bool{"br": true, "hr": true, "img": true}
+	// fuzzBlockTags 块级元素，不能出现在 <p> 中
+	fuzzBlockTags = map[string]bool{
+		"blockquote": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+		"hr": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "ul": true,
+	}
+)
+
+// checkWellFormed 检查 HTML 标签配对、属性带引号、文本中没有未转义的 < > 和 &，
+// 块级元素不在 <p> 中，且只含转换器生成的标签和属性，链接不使用脚本地址
+func checkWellFormed(out string) error {
+	var stack []string
+	for i := 0; i < len(out); {
+		switch out[i] {
+		case '<':
+			m := fuzzTagRegex.FindStringSubmatch(out[i:])
+			if m == nil {
+				return fmt.Errorf("unescaped < or malformed tag at %d: %.40q", i, out[i:])
+			}
+			closing, name, attrs, selfClosing := m[1] != "", m[2], m[3], m[4] != ""
+			if !fuzzTags[name] {
+				return fmt.Errorf("unexpected tag <%s> at %d", name, i)
+			}
+			for _, attr := range fuzzAttrRegex.FindAllStringSubmatch(attrs, -1) {
+				if !fuzzAttrs[attr[1]] {
+					return fmt.Errorf("unexpected attribute %s on <%s>", attr[1], name)
+				}
+				if (attr[1] == "href" || attr[1] == "src") && !fuzzSafeURL(html.UnescapeString(attr[2])) {
+					return fmt.Errorf("unsafe URL in %s: %q", attr[1], attr[2])
+				}
+			}
+			if fuzzBlockTags[name] && !closing && slices.Contains(stack, "p") {
+				return fmt.Errorf("block element <%s> inside <p> at %d", name, i)
+			}
+			switch {
+			case fuzzVoidTags[name]:
+				if closing {
+					return fmt.Errorf("closing tag for void element <%s>", name)
+				}
+			case selfClosing:
+				return fmt.Errorf("self-closing non-void element <%s>", name)
+			case closing:
+				if len(stack) == 0 || stack[len(stack)-1] != name {
+					return fmt.Errorf("unbalanced </%s> at %d, open: %v", name, i, stack)
+				}
+				stack = stack[:len(stack)-1]
+			default:
+				stack = append(stack, name)
+			}
+			i += len(m[0])
+		case '>':
+			return fmt.Errorf("unescaped > at %d: %.40q", i, out[max(i-20, 0):])
+		case '&':
+			m := fuzzEntityRegex.FindString(out[i:])
+			if m == "" {
+				return fmt.Errorf("unescaped & at %d: %.40q", i, out[i:])
+			}
+			i += len(m)
+		default:
+			i++
+		}
+	}
+	if len(stack) > 0 {
+		return fmt.Errorf("unclosed tags: %v", stack)
+	}
+	return nil
+}
+
+// fuzzSafeURL 与 isSafeURL 独立实现的同一规则，避免测试依赖被测代码
+func fuzzSafeURL(u string) bool {
+	var b strings.Builder
+	for _, r := range strings.ToLower(u) {
+		if r > ' ' {
+			b.WriteRune(r)
+		}
+	}
+	u = b.String()
+	if strings.HasPrefix(u, "data:") {
+		return strings.HasPrefix(u, "data:image/")
+	}
+	return !strings.HasPrefix(u, "javascript:") && !strings.HasPrefix(u, "vbscript:")
+}
+
+// fuzzTimeout 单次转换的耗时上限；输入不超过 fuzzMaxInput，正常情况下远小于这个值
+const (
+	fuzzTimeout  = 2 * time.Second
+	fuzzMaxInput = 64 << 10
+)
+
+// convertWithin 转换 markdown，超过 fuzzTimeout 未完成时立即失败；
+// 模糊测试本身不限制单个输入的耗时，死循环的输入只会让测试卡住而不会被记录
+func convertWithin(t *testing.T, conv *WechatConverter, markdown string) string {
+	t.Helper()
+	done := make(chan string, 1)
+	go func() { done <- conv.ConvertMarkdownToWechat(markdown) }()
+	select {
+	case out := <-done:
+		return out
+	case <-time.After(fuzzTimeout):
+		t.Fatalf("conversion of %d bytes did not finish in %v", len(markdown), fuzzTimeout)
+		return ""
+	}
+}
+
+// FuzzConvert 任意输入在所有平台下都不能 panic，在限定时间内完成，并输出配对完整、没有注入标签的 HTML
+func FuzzConvert(f *testing.F) {
+	for _, seed := range append(fuzzSeeds, astSample, largeFixture(f, 1)) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, markdown string) {
+		if len(markdown) > fuzzMaxInput {
+			return
+		}
+		for _, name := range Profiles() {
+			profile, _ := GetProfile(name)
+			conv := NewWechatConverterFixed()
+			conv.SetProfile(profile)
+			conv.SetHeadingNumbering(NumberingH2)
+
+			out := convertWithin(t, conv, markdown)
+			if err := checkWellFormed(out); err != nil {
+				t.Fatalf("%s: %v\noutput:\n%s", name, err, out)
+			}
+		}
+	})
+}
+
+// FuzzBlockCache 按块转换和带源码行号的转换结果应与整篇转换相同，且同样满足 FuzzConvert 的要求
+func FuzzBlockCache(f *testing.F) {
+	for _, seed := range append(fuzzSeeds, astSample) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, markdown string) {
+		if len(markdown) > fuzzMaxInput {
+			return
+		}
+		full := NewWechatConverterFixed()
+		full.SetSourceLines(true)
+		want := convertWithin(t, full, markdown)
+		if err := checkWellFormed(want); err != nil {
+			t.Fatalf("source lines: %v\noutput:\n%s", err, want)
+		}
+
+		cached := NewWechatConverterFixed()
+		cached.SetSourceLines(true)
+		cached.SetBlockCache(NewBlockCache(0))
+		if got := convertWithin(t, cached, markdown); got != want {
+			t.Fatalf("cached output differs from full conversion\nwant:\n%s\ngot:\n%s", want, got)
+		}
+	})
+}
+
+// TestPlaceholderLikeText 正文中与旧占位符写法相同的文字原样输出，不被代码块或公式替换
+func TestPlaceholderLikeText(t *testing.T) {
+	for _, markdown := range []string{
+		"Look __CODE_BLOCK_0__ here\n```\n<b>\n```",
+		"$x$ __MATH_INLINE_0__\n\n$$\ny\n$$\n__MATH_BLOCK_0__",
+	} {
+		out := NewWechatConverterFixed().ConvertMarkdownToWechat(markdown)
+		for _, word := range strings.Fields(markdown) {
+			if strings.HasPrefix(word, "__") && !strings.Contains(out, word) {
+				t.Errorf("%q: %s missing from output:\n%s", markdown, word, out)
+			}
+		}
+		if err := checkWellFormed(out); err != nil {
+			t.Errorf("%q: %v\noutput:\n%s", markdown, err, out)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package converter
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlNode 简单的 HTML 节点，Tag 为空表示文本节点
+type htmlNode struct {
+	Tag      string
+	Attrs    map[string]string
+	Text     string
+	Children []*htmlNode
+	Parent   *htmlNode
+}
+
+var voidTags = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextTags 内容不解析标签的元素
+var rawTextTags = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// autoClose 打开某个标签时自动关闭的未闭合标签，按从内到外的顺序排列
+var autoClose = map[string][]string{
+	"p":       {"p"},
+	"li":      {"p", "li"},
+	"tr":      {"td", "th", "tr"},
+	"td":      {"td", "th"},
+	"th":      {"td", "th"},
+	"div":     {"p"},
+	"section": {"p"},
+	"ul":      {"p"},
+	"ol":      {"p"},
+	"table":   {"p"},
+	"pre":     {"p"},
+	"h1":      {"p"}, "h2": {"p"}, "h3": {"p"}, "h4": {"p"}, "h5": {"p"}, "h6": {"p"},
+	"blockquote": {"p"},
+}
+
+// parseHTML 宽松地解析 HTML，容忍未闭合和错误嵌套的标签
+func parseHTML(src string) *htmlNode {
+	root := &htmlNode{Tag: "#root"}
+	cur := root
+
+	appendChild := func(n *htmlNode) {
+		n.Parent = cur
+		cur.Children = append(cur.Children, n)
+	}
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		appendChild(&htmlNode{Text: html.UnescapeString(text)})
+	}
+
+	for i := 0; i < len(src); {
+		lt := strings.IndexByte(src[i:], '<')
+		if lt < 0 {
+			appendText(src[i:])
+			break
+		}
+		appendText(src[i : i+lt])
+		i += lt
+
+		rest := src[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+			continue
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+			continue
+		}
+
+		closing := strings.HasPrefix(rest, "</")
+		nameStart := 1
+		if closing {
+			nameStart = 2
+		}
+		nameEnd := nameStart
+		for nameEnd < len(rest) && isTagNameByte(rest[nameEnd]) {
+			nameEnd++
+		}
+		if nameEnd == nameStart {
+			// 不是标签，按文本处理
+			appendText("<")
+			i++
+			continue
+		}
+		name := strings.ToLower(rest[nameStart:nameEnd])
+		end := tagEnd(rest, nameEnd)
+		if end < 0 {
+			appendText(rest)
+			break
+		}
+		i += end + 1
+
+		if closing {
+			for n := cur; n != root; n = n.Parent {
+				if n.Tag == name {
+					cur = n.Parent
+					break
+				}
+			}
+			continue
+		}
+
+		attrSrc := rest[nameEnd:end]
+		selfClosing := strings.HasSuffix(attrSrc, "/")
+		node := &htmlNode{Tag: name, Attrs: parseHTMLAttrs(strings.TrimSuffix(attrSrc, "/"))}
+
+		for _, tag := range autoClose[name] {
+			if cur.Tag == tag {
+				cur = cur.Parent
+			}
+		}
+		appendChild(node)
+
+		if rawTextTags[name] {
+			closeTag := "</" + name
+			j := strings.Index(strings.ToLower(src[i:]), closeTag)
+			if j < 0 {
+				j = len(src) - i
+			}
+			if name == "title" || name == "textarea" {
+				node.Children = []*htmlNode{{Text: html.UnescapeString(src[i : i+j]), Parent: node}}
+			}
+			i += j
+			if k := strings.IndexByte(src[i:], '>'); k >= 0 {
+				i += k + 1
+			}
+			continue
+		}
+
+		if !voidTags[name] && !selfClosing {
+			cur = node
+		}
+	}
+
+	return root
+}
+
+func isTagNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-' || b == ':'
+}
+
+// tagEnd 查找标签结束的 >，跳过引号中的内容
+func tagEnd(s string, from int) int {
+	var quote byte
+	for i := from; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLAttrs 解析标签属性
+func parseHTMLAttrs(src string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttrRegex.FindAllStringSubmatch(src, -1) {
+		value := m[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		attrs[strings.ToLower(m[1])] = html.UnescapeString(value)
+	}
+	return attrs
+}
+
+// attr 返回属性值
+func (n *htmlNode) attr(name string) string {
+	return n.Attrs[name]
+}
+
+// hasClass 判断是否包含指定 class
+func (n *htmlNode) hasClass(class string) bool {
+	for _, c := range strings.Fields(n.Attrs["class"]) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// find 深度优先查找第一个满足条件的节点
+func (n *htmlNode) find(match func(*htmlNode) bool) *htmlNode {
+	for _, child := range n.Children {
+		if child.Tag == "" {
+			continue
+		}
+		if match(child) {
+			return child
+		}
+		if found := child.find(match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent 返回节点的全部文本，<br> 转为换行
+func (n *htmlNode) textContent() string {
+	var b strings.Builder
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.Tag == "" {
+			b.WriteString(n.Text)
+			return
+		}
+		if n.Tag == "br" {
+			b.WriteString("\n")
+			return
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(n)
+	return b.String()
+}
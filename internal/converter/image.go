@@ -0,0 +1,293 @@
+package converter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ImageOptions 本地图片解析选项
+type ImageOptions struct {
+	// BaseDir 相对路径图片的根目录，FS 为空时生效
+	BaseDir string
+	// FS 图片所在的文件系统，设置后优先于 BaseDir
+	FS fs.FS
+	// BaseURL 未内联的本地图片改写为 BaseURL + 相对路径，为空时保留清理后的相对路径
+	BaseURL string
+	// InlineMaxBytes 不超过该大小的图片内联为 data URI，0 表示不内联
+	InlineMaxBytes int64
+}
+
+// Warning 转换过程中产生的警告；Column 从 1 开始按字符计数，为 0 时只知道行号
+type Warning struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+var (
+	imageRefRegex   = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`)
+	imageTitleRegex = regexp.MustCompile(`^(\S+)\s+("[^"]*"|'[^']*')$`)
+)
+
+// splitImageTitle 拆分 ![alt](src "title") 括号中的地址和可选的标题，标题不含引号
+func splitImageTitle(dest string) (src, title string) {
+	dest = strings.TrimSpace(dest)
+	if m := imageTitleRegex.FindStringSubmatch(dest); m != nil {
+		return m[1], m[2][1 : len(m[2])-1]
+	}
+	return dest, ""
+}
+
+// SetImageOptions 设置本地图片解析选项
+func (c *WechatConverter) SetImageOptions(opts ImageOptions) {
+	c.imageOptions = opts
+}
+
+// Warnings 返回最近一次转换产生的警告
+func (c *WechatConverter) Warnings() []Warning {
+	return c.warnings
+}
+
+// addWarning 记录一条警告
+func (c *WechatConverter) addWarning(line int, format string, args ...interface{}) {
+	c.warnings = append(c.warnings, Warning{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// resolveImages 逐行解析图片引用：本地相对路径解析到 BaseDir/FS 下，设置了上传器时改写为上传后的地址
+func (c *WechatConverter) resolveImages(text string) string {
+	if c.imageOptions.FS == nil && c.imageOptions.BaseDir == "" && c.uploader == nil {
+		return text
+	}
+
+	// 代码块中的内容保持原样；代码块可以在行中结束，片段的第一行不一定从行首开始
+	return outsideCodeFences(text, func(part string, start int) string {
+		line := strings.Count(text[:start], "\n") + 1
+		column := utf8.RuneCountInString(text[strings.LastIndexByte(text[:start], '\n')+1 : start])
+		lines := strings.Split(part, "\n")
+		for i := range lines {
+			if i > 0 {
+				column = 0
+			}
+			if strings.Contains(lines[i], "![") {
+				lines[i] = c.resolveImageLine(lines[i], line+i, column)
+			}
+		}
+		return strings.Join(lines, "\n")
+	})
+}
+
+// resolveImageLine 解析一行中的图片引用，line 为行号，column 为 text 之前同一行的字符数
+func (c *WechatConverter) resolveImageLine(text string, line, column int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range imageRefRegex.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(text[last:m[0]])
+		last = m[1]
+		// 标题不影响图片地址，改写后不再保留
+		src, _ := splitImageTitle(text[m[4]:m[5]])
+
+		// 解析图片产生的警告定位到图片引用的起始列
+		n := len(c.warnings)
+		resolved, ok := c.resolveImage(src, line)
+		for j := n; j < len(c.warnings); j++ {
+			c.warnings[j].Column = column + utf8.RuneCountInString(text[:m[0]]) + 1
+		}
+		if !ok {
+			b.WriteString(text[m[0]:m[1]])
+			continue
+		}
+		fmt.Fprintf(&b, "![%s](%s)", text[m[2]:m[3]], resolved)
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// resolveImage 解析单个图片地址，返回改写后的地址
+func (c *WechatConverter) resolveImage(src string, line int) (string, bool) {
+	if strings.HasPrefix(strings.ToLower(src), "data:") {
+		if c.uploader == nil {
+			return "", false
+		}
+		data, mimeType, err := decodeDataURI(src)
+		if err != nil {
+			c.addWarning(line, "invalid data URI: %v", err)
+			return "", false
+		}
+		return c.uploadImage("image"+extensionForMime(mimeType), data, line)
+	}
+
+	if isRemoteImage(src) {
+		if c.uploader == nil {
+			return "", false
+		}
+		if h, ok := c.uploader.(hostChecker); ok && h.IsHosted(src) {
+			return "", false
+		}
+		data, err := c.fetchImage(src)
+		if err != nil {
+			c.addWarning(line, "failed to fetch image %s: %v", src, err)
+			return "", false
+		}
+		name := "image"
+		if u, err := url.Parse(src); err == nil {
+			name = path.Base(u.Path)
+		}
+		return c.uploadImage(name, data, line)
+	}
+
+	if c.imageOptions.FS == nil && c.imageOptions.BaseDir == "" {
+		c.addWarning(line, "cannot resolve local image without base directory: %s", src)
+		return "", false
+	}
+	return c.resolveLocalImage(src, line)
+}
+
+// uploadImage 调用上传器上传图片
+func (c *WechatConverter) uploadImage(name string, data []byte, line int) (string, bool) {
+	uploaded, err := c.uploader.Upload(name, data)
+	if err != nil {
+		c.addWarning(line, "failed to upload image %s: %v", name, err)
+		return "", false
+	}
+	return uploaded, true
+}
+
+// resolveLocalImage 解析单个本地图片，返回改写后的地址
+func (c *WechatConverter) resolveLocalImage(src string, line int) (string, bool) {
+	fsys := c.imageOptions.FS
+	if fsys == nil {
+		fsys = os.DirFS(c.imageOptions.BaseDir)
+	}
+	rel, ok := localImagePath(src)
+	if !ok {
+		c.addWarning(line, "image path escapes base directory: %s", src)
+		return "", false
+	}
+	info, err := fs.Stat(fsys, rel)
+	if err != nil || info.IsDir() {
+		c.addWarning(line, "image not found: %s", src)
+		return "", false
+	}
+
+	// 设置了上传器时优先上传，不再内联
+	inline := c.imageOptions.InlineMaxBytes > 0 && info.Size() <= c.imageOptions.InlineMaxBytes
+	if c.uploader != nil || inline {
+		data, err := fs.ReadFile(fsys, rel)
+		if err != nil {
+			c.addWarning(line, "failed to read image %s: %v", src, err)
+			return "", false
+		}
+		if c.uploader != nil {
+			return c.uploadImage(path.Base(rel), data, line)
+		}
+		return dataURI(rel, data), true
+	}
+
+	if c.imageOptions.BaseURL != "" {
+		return strings.TrimSuffix(c.imageOptions.BaseURL, "/") + "/" + rel, true
+	}
+	return rel, true
+}
+
+// localImagePath 返回本地图片相对于根目录的路径：以 / 开头的路径相对于根目录，
+// 不能通过 .. 或绝对路径指向根目录之外，否则返回 false
+func localImagePath(src string) (string, bool) {
+	name := src
+	if unescaped, err := url.PathUnescape(src); err == nil {
+		name = unescaped
+	}
+	rel := path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "/"))
+	return rel, fs.ValidPath(rel)
+}
+
+// LocalImages 按出现顺序返回文章引用的本地图片相对于文章目录的路径（去重），
+// 解析规则与转换时相同，代码块中的图片和指向文章目录之外的路径不返回
+func LocalImages(markdown string) []string {
+	var images []string
+	seen := make(map[string]bool)
+	outsideCodeFences(blankFrontMatter(markdown), func(part string, _ int) string {
+		for _, line := range strings.Split(part, "\n") {
+			for _, m := range imageRefRegex.FindAllStringSubmatch(line, -1) {
+				src, _ := splitImageTitle(m[2])
+				if isRemoteImage(src) || strings.HasPrefix(strings.ToLower(src), "data:") {
+					continue
+				}
+				if rel, ok := localImagePath(src); ok && !seen[rel] {
+					seen[rel] = true
+					images = append(images, rel)
+				}
+			}
+		}
+		return part
+	})
+	return images
+}
+
+// isRemoteImage 判断图片地址是否为远程地址
+func isRemoteImage(src string) bool {
+	lower := strings.ToLower(src)
+	return strings.HasPrefix(lower, "http://") ||
+		strings.HasPrefix(lower, "https://") ||
+		strings.HasPrefix(lower, "//")
+}
+
+// decodeDataURI 解析 base64 编码的 data URI
+func decodeDataURI(uri string) ([]byte, string, error) {
+	comma := strings.Index(uri, ",")
+	if comma < 0 {
+		return nil, "", fmt.Errorf("missing data separator")
+	}
+	meta := uri[len("data:"):comma]
+	if !strings.HasSuffix(meta, ";base64") {
+		return nil, "", fmt.Errorf("only base64 data URIs are supported")
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[comma+1:])
+	if err != nil {
+		return nil, "", err
+	}
+	return data, strings.TrimSuffix(meta, ";base64"), nil
+}
+
+// extensionForMime 根据 MIME 类型推断文件扩展名
+func extensionForMime(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "image/svg+xml":
+		return ".svg"
+	}
+	return ""
+}
+
+// dataURI 将图片内容编码为 data URI
+func dataURI(name string, data []byte) string {
+	return "data:" + imageMimeType(name, data) + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// imageMimeType 根据文件扩展名推断图片 MIME 类型，无法识别时按内容推断
+func imageMimeType(name string, data []byte) string {
+	mimeType := mime.TypeByExtension(strings.ToLower(path.Ext(name)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return mimeType
+}
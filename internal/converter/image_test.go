@@ -0,0 +1,102 @@
+package converter
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestLocalImageStaysInBaseDir BaseDir 之外的文件不能通过 .. 或绝对路径读取并内联
+func TestLocalImageStaysInBaseDir(t *testing.T) {
+	root := t.TempDir()
+	baseDir := filepath.Join(root, "article")
+	if err := os.MkdirAll(filepath.Join(baseDir, "img"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	secret := filepath.Join(root, "secret.png")
+	for name, data := range map[string]string{secret: "SECRET", filepath.Join(baseDir, "img", "a.png"): "PNG"} {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, src := range []string{"../secret.png", "img/../../secret.png", secret} {
+		conv := NewWechatConverterFixed()
+		conv.SetImageOptions(ImageOptions{BaseDir: baseDir, InlineMaxBytes: 1 << 20})
+		out := conv.ConvertMarkdownToWechat("![x](" + src + ")")
+		if strings.Contains(out, "data:") {
+			t.Errorf("%s: file outside base directory was inlined:\n%s", src, out)
+		}
+		if len(conv.Warnings()) != 1 {
+			t.Errorf("%s: warnings = %v, want one", src, conv.Warnings())
+		}
+	}
+
+	// 以 / 开头的路径相对于 BaseDir
+	conv := NewWechatConverterFixed()
+	conv.SetImageOptions(ImageOptions{BaseDir: baseDir, InlineMaxBytes: 1 << 20})
+	if out := conv.ConvertMarkdownToWechat("![x](/img/a.png)"); !strings.Contains(out, `src="data:image/png;base64,UE5H"`) {
+		t.Errorf("root-relative image not inlined:\n%s", out)
+	}
+}
+
+// TestImageTitle ![alt](src "title") 的标题不属于图片地址
+func TestImageTitle(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "a.png"), []byte("PNG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts ImageOptions
+		want string
+	}{
+		{"inline", ImageOptions{BaseDir: baseDir, InlineMaxBytes: 1 << 20}, `src="data:image/png;base64,UE5H"`},
+		{"base URL", ImageOptions{BaseDir: baseDir, BaseURL: "https://cdn.example.com"}, `src="https://cdn.example.com/a.png"`},
+		{"unresolved", ImageOptions{}, `src="a.png"`},
+	} {
+		for _, markdown := range []string{`![图](a.png "标题")`, `![图](a.png 'title')`} {
+			conv := NewWechatConverterFixed()
+			conv.SetImageOptions(tc.opts)
+			out := conv.ConvertMarkdownToWechat(markdown)
+			if !strings.Contains(out, tc.want) {
+				t.Errorf("%s: %s: output lacks %s:\n%s", tc.name, markdown, tc.want, out)
+			}
+			if len(conv.Warnings()) > 0 {
+				t.Errorf("%s: %s: unexpected warnings %v", tc.name, markdown, conv.Warnings())
+			}
+		}
+	}
+}
+
+// TestImagesOutsideCodeFences 代码块的范围与转换时相同：在行中结束的代码块之后的图片和未闭合的 ``` 之后的图片照常解析
+func TestImagesOutsideCodeFences(t *testing.T) {
+	for _, tc := range []struct {
+		markdown string
+		want     []string
+	}{
+		{"```\n![a](in.png)\n``` ![b](after.png)", []string{"after.png"}},
+		{"  ```go\n![a](in.png)\n  ```\n![b](b.png)", []string{"b.png"}},
+		{"```\n![c](c.png)", []string{"c.png"}},
+		{"x ```y```\n![d](d.png)\n```\n![e](e.png)", []string{"e.png"}},
+	} {
+		if got := LocalImages(tc.markdown); !slices.Equal(got, tc.want) {
+			t.Errorf("LocalImages(%q) = %v, want %v", tc.markdown, got, tc.want)
+		}
+	}
+
+	conv := NewWechatConverterFixed()
+	conv.SetImageOptions(ImageOptions{BaseDir: t.TempDir()})
+	conv.ConvertMarkdownToWechat("```\n![a](in.png)\n```  ![b](missing.png)")
+	if w := conv.Warnings(); len(w) != 1 || w[0].Line != 3 || w[0].Column != 6 {
+		t.Errorf("warnings = %+v, want one at 3:6", w)
+	}
+
+	stats := Stats{Words: 7}
+	if got := expandStatsPlaceholders("```\n{{words}}\n``` {{words}} `{{words}}`", stats); got != "```\n{{words}}\n``` 7 `{{words}}`" {
+		t.Errorf("expandStatsPlaceholders = %q", got)
+	}
+}
@@ -0,0 +1,724 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoContent 导入的 HTML 中没有正文
+var ErrNoContent = errors.New("no article content found")
+
+// ImportResult HTML 导入结果
+type ImportResult struct {
+	// Markdown 包含 front matter 的完整 Markdown
+	Markdown    string      `json:"markdown"`
+	FrontMatter FrontMatter `json:"front_matter"`
+	// Images 正文和封面中的图片地址，按出现顺序去重
+	Images   []string `json:"images"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Media 嵌入图片所在的文件系统（DOCX 导入），Images 中的相对路径在其中解析
+	Media fs.FS `json:"-"`
+}
+
+// frontMatterKeys 导入时写入 front matter 的字段及顺序
+var frontMatterKeys = []string{"title", "author", "date", "digest", "cover", "url"}
+
+var (
+	wechatVarRegex  = regexp.MustCompile(`var\s+(msg_title|msg_desc|msg_cdn_url|msg_link|ct)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	footnoteRefText = regexp.MustCompile(`^\[(\d+)\]$`)
+	footnoteLine    = regexp.MustCompile(`^\[(\d+)\]\s*(.+)$`)
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+	blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+)
+
+// footnoteHeadings 本项目及常见编辑器生成的脚注标题
+var footnoteHeadings = []string{"参考", "参考资料", "参考链接", "References"}
+
+// blockTags 按块级元素处理的标签
+var blockTags = map[string]bool{
+	"#root": true, "html": true, "body": true, "article": true, "main": true, "header": true, "footer": true,
+	"section": true, "div": true, "p": true, "center": true, "aside": true, "nav": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ul": true, "ol": true, "li": true, "dl": true, "dt": true, "dd": true,
+	"blockquote": true, "pre": true, "table": true, "hr": true, "figure": true, "figcaption": true,
+}
+
+// skipTags 导入时整体忽略的标签
+var skipTags = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "iframe": true, "button": true,
+	"input": true, "textarea": true, "select": true, "form": true, "mpvoice": true, "mpprofile": true,
+	"mp-common-profile": true, "qqmusic": true, "template": true,
+}
+
+// htmlImporter 保存单次导入的状态
+type htmlImporter struct {
+	footnotes map[string]string
+	// notes 正文中引用到的文字脚注编号
+	notes  []string
+	images []string
+}
+
+// ImportHTML 将已发布文章的 HTML（包括微信公众号网页和本项目的输出）转换为 Markdown
+func ImportHTML(src string) (*ImportResult, error) {
+	root := parseHTML(src)
+	fm := importFrontMatter(root, src)
+
+	content := root.find(func(n *htmlNode) bool { return n.attr("id") == "js_content" })
+	if content == nil {
+		content = root.find(func(n *htmlNode) bool { return n.hasClass("rich_media_content") })
+	}
+	if content == nil {
+		content = root.find(func(n *htmlNode) bool { return n.Tag == "body" })
+	}
+	if content == nil {
+		content = root
+	}
+
+	imp := &htmlImporter{footnotes: make(map[string]string)}
+	imp.extractFootnotes(content)
+
+	body := strings.Join(imp.blocks(content), "\n\n")
+	if len(imp.notes) > 0 {
+		defs := make([]string, len(imp.notes))
+		for i, id := range imp.notes {
+			defs[i] = "[^" + id + "]: " + imp.footnotes[id]
+		}
+		body += "\n\n" + strings.Join(defs, "\n")
+	}
+	body = strings.TrimSpace(blankLinesRegex.ReplaceAllString(body, "\n\n"))
+	if body == "" {
+		return nil, ErrNoContent
+	}
+
+	if cover := fm["cover"]; cover != "" {
+		imp.addImage(cover)
+	}
+
+	return &ImportResult{
+		Markdown:    renderFrontMatter(fm) + body + "\n",
+		FrontMatter: fm,
+		Images:      imp.images,
+	}, nil
+}
+
+// importFrontMatter 从 meta 标签和微信页面脚本变量中提取元信息
+func importFrontMatter(root *htmlNode, src string) FrontMatter {
+	meta := make(map[string]string)
+	var walk func(*htmlNode)
+	walk = func(n *htmlNode) {
+		if n.Tag == "meta" {
+			key := n.attr("property")
+			if key == "" {
+				key = n.attr("name")
+			}
+			key = strings.ToLower(key)
+			if key != "" && meta[key] == "" {
+				meta[key] = strings.TrimSpace(n.attr("content"))
+			}
+		}
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	walk(root)
+
+	vars := make(map[string]string)
+	for _, m := range wechatVarRegex.FindAllStringSubmatch(src, -1) {
+		if vars[m[1]] == "" {
+			vars[m[1]] = strings.TrimSpace(m[2] + m[3])
+		}
+	}
+
+	nodeText := func(match func(*htmlNode) bool) string {
+		if n := root.find(match); n != nil {
+			return strings.TrimSpace(whitespaceRegex.ReplaceAllString(n.textContent(), " "))
+		}
+		return ""
+	}
+
+	fm := make(FrontMatter)
+	set := func(key string, values ...string) {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				fm[key] = v
+				return
+			}
+		}
+	}
+
+	set("title", meta["og:title"], meta["twitter:title"], vars["msg_title"],
+		nodeText(func(n *htmlNode) bool { return n.attr("id") == "activity-name" || n.hasClass("rich_media_title") }),
+		nodeText(func(n *htmlNode) bool { return n.Tag == "title" }))
+	set("author", meta["author"], meta["article:author"],
+		nodeText(func(n *htmlNode) bool { return n.attr("id") == "js_author_name" }))
+	set("digest", meta["og:description"], vars["msg_desc"], meta["description"])
+	set("cover", meta["og:image"], vars["msg_cdn_url"])
+	set("url", meta["og:url"], vars["msg_link"])
+
+	if published := meta["article:published_time"]; published != "" {
+		if t, err := time.Parse(time.RFC3339, published); err == nil {
+			fm["date"] = t.Format("2006-01-02")
+		}
+	} else if ts, err := strconv.ParseInt(vars["ct"], 10, 64); err == nil && ts > 0 {
+		fm["date"] = time.Unix(ts, 0).Format("2006-01-02")
+	}
+
+	return fm
+}
+
+// renderFrontMatter 生成 front matter，没有元信息时返回空字符串
+func renderFrontMatter(fm FrontMatter) string {
+	var b strings.Builder
+	for _, key := range frontMatterKeys {
+		if v := fm[key]; v != "" {
+			v = whitespaceRegex.ReplaceAllString(v, " ")
+			b.WriteString(key + ": \"" + strings.ReplaceAll(v, `"`, `\"`) + "\"\n")
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "---\n" + b.String() + "---\n\n"
+}
+
+// extractFootnotes 识别本项目生成的脚注列表（“参考”标题后的 [n] 链接），记录后从文档中移除
+func (imp *htmlImporter) extractFootnotes(content *htmlNode) {
+	heading := content.find(func(n *htmlNode) bool {
+		if n.Tag != "h2" && n.Tag != "h3" && n.Tag != "h4" && n.Tag != "p" {
+			return false
+		}
+		return containsString(footnoteHeadings, strings.TrimSpace(n.textContent()))
+	})
+	if heading == nil {
+		return
+	}
+
+	parent := heading.Parent
+	start := -1
+	for i, child := range parent.Children {
+		if child == heading {
+			start = i
+		}
+	}
+
+	end := start + 1
+	for ; end < len(parent.Children); end++ {
+		child := parent.Children[end]
+		if child.Tag == "" && strings.TrimSpace(child.Text) == "" {
+			continue
+		}
+		m := footnoteLine.FindStringSubmatch(strings.TrimSpace(child.textContent()))
+		if m == nil {
+			break
+		}
+		imp.footnotes[m[1]] = m[2]
+	}
+	if len(imp.footnotes) == 0 {
+		return
+	}
+
+	// 一并去掉脚注前的分隔线
+	for start > 0 {
+		prev := parent.Children[start-1]
+		if prev.Tag == "hr" || (prev.Tag == "" && strings.TrimSpace(prev.Text) == "") {
+			start--
+			continue
+		}
+		break
+	}
+	parent.Children = append(parent.Children[:start:start], parent.Children[end:]...)
+}
+
+// blocks 将节点的子节点转换为 Markdown 块
+func (imp *htmlImporter) blocks(n *htmlNode) []string {
+	var out []string
+	var run []*htmlNode
+	lastList := ""
+
+	add := func(block, kind string) {
+		if block == "" {
+			return
+		}
+		// 本项目的输出中每个列表项、每行引用都是单独的元素，合并相邻的同类块
+		if kind != "" && kind == lastList && len(out) > 0 {
+			out[len(out)-1] += "\n" + block
+		} else {
+			out = append(out, block)
+		}
+		lastList = kind
+	}
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		text := imp.inline(run)
+		run = nil
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		add(strings.Join(lines, "\n"), "")
+	}
+
+	for _, child := range n.Children {
+		if child.Tag != "" && skipTags[child.Tag] {
+			continue
+		}
+		if child.Tag == "" || !blockTags[child.Tag] {
+			run = append(run, child)
+			continue
+		}
+		flush()
+
+		switch child.Tag {
+		case "ul", "ol":
+			if child.hasClass("code-snippet__line-index") {
+				continue
+			}
+			start := 1
+			if child.Tag == "ol" && lastList == "ol" && len(out) > 0 {
+				start = strings.Count(out[len(out)-1], "\n") + 2
+			}
+			add(imp.list(child, start), child.Tag)
+		case "blockquote":
+			add(imp.quote(child), "blockquote")
+		default:
+			for _, block := range imp.block(child) {
+				add(block, "")
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// block 转换单个块级元素
+func (imp *htmlImporter) block(n *htmlNode) []string {
+	switch n.Tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := oneLine(imp.inline(n.Children))
+		if text == "" {
+			return nil
+		}
+		return []string{strings.Repeat("#", int(n.Tag[1]-'0')) + " " + text}
+	case "pre":
+		return []string{imp.codeBlock(n)}
+	case "table":
+		return []string{imp.table(n)}
+	case "hr":
+		return []string{"---"}
+	}
+
+	if n.hasClass("block-equation") || n.attr("data-formula") != "" {
+		return []string{"$$\n" + mathTeX(n) + "\n$$"}
+	}
+	if isCodeSection(n) {
+		if pre := n.find(func(c *htmlNode) bool { return c.Tag == "pre" }); pre != nil {
+			return []string{imp.codeBlock(pre)}
+		}
+		return []string{fence(n.textContent(), "")}
+	}
+	return imp.blocks(n)
+}
+
+// isCodeSection 判断是否为代码块容器：本项目输出的 white-space: pre 样式 section，或微信编辑器的 code-snippet
+func isCodeSection(n *htmlNode) bool {
+	if n.hasClass("code-snippet__fix") || n.hasClass("code-snippet") {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(n.attr("style")), " ", "")
+	return (n.Tag == "section" || n.Tag == "div") && strings.Contains(style, "white-space:pre;")
+}
+
+// codeBlock 转换 <pre>，微信代码片段中每行是一个 <code>
+func (imp *htmlImporter) codeBlock(pre *htmlNode) string {
+	var codes []*htmlNode
+	for _, child := range pre.Children {
+		if child.Tag == "code" {
+			codes = append(codes, child)
+		}
+	}
+
+	var code string
+	if len(codes) > 1 {
+		lines := make([]string, len(codes))
+		for i, c := range codes {
+			lines[i] = c.textContent()
+		}
+		code = strings.Join(lines, "\n")
+	} else {
+		code = pre.textContent()
+	}
+
+	lang := pre.attr("data-lang")
+	for _, n := range append([]*htmlNode{pre}, codes...) {
+		for _, class := range strings.Fields(n.attr("class")) {
+			for _, prefix := range []string{"language-", "lang-", "code-snippet__"} {
+				if lang == "" && strings.HasPrefix(class, prefix) && class != "code-snippet__fix" {
+					lang = strings.TrimPrefix(class, prefix)
+				}
+			}
+		}
+	}
+	return fence(code, lang)
+}
+
+// fence 生成围栏代码块
+func fence(code, lang string) string {
+	code = strings.Trim(strings.ReplaceAll(code, "\u00a0", " "), "\n")
+	return "```" + lang + "\n" + code + "\n```"
+}
+
+// list 转换列表，嵌套列表缩进两个空格
+func (imp *htmlImporter) list(n *htmlNode, start int) string {
+	var items []string
+	number := start
+	for _, li := range n.Children {
+		if li.Tag != "li" {
+			continue
+		}
+		marker := "- "
+		if n.Tag == "ol" {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		var lines []string
+		for _, block := range imp.blocks(li) {
+			lines = append(lines, strings.Split(block, "\n")...)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		item := marker + lines[0]
+		for _, line := range lines[1:] {
+			item += "\n  " + line
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, "\n")
+}
+
+// quote 转换引用
+func (imp *htmlImporter) quote(n *htmlNode) string {
+	var lines []string
+	for i, block := range imp.blocks(n) {
+		if i > 0 {
+			lines = append(lines, ">")
+		}
+		for _, line := range strings.Split(block, "\n") {
+			lines = append(lines, strings.TrimSpace("> "+line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// table 转换表格，合并单元格使用 "<" 和 "^" 标记
+func (imp *htmlImporter) table(n *htmlNode) string {
+	var rows [][]*htmlNode
+	var collect func(*htmlNode)
+	collect = func(n *htmlNode) {
+		for _, child := range n.Children {
+			switch child.Tag {
+			case "tr":
+				var cells []*htmlNode
+				for _, cell := range child.Children {
+					if cell.Tag == "td" || cell.Tag == "th" {
+						cells = append(cells, cell)
+					}
+				}
+				rows = append(rows, cells)
+			case "thead", "tbody", "tfoot":
+				collect(child)
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	// 按合并单元格展开为网格
+	var grid [][]string
+	aligns := map[int]string{}
+	pending := map[[2]int]string{}
+	for i, cells := range rows {
+		var row []string
+		col := 0
+		next := func() {
+			for {
+				if marker, ok := pending[[2]int{i, col}]; ok {
+					row = append(row, marker)
+					col++
+					continue
+				}
+				return
+			}
+		}
+		for _, cell := range cells {
+			next()
+			text := strings.ReplaceAll(oneLine(imp.inline(cell.Children)), "|", `\|`)
+			if i == 0 {
+				aligns[col] = cellAlign(cell)
+			}
+			colspan, _ := strconv.Atoi(cell.attr("colspan"))
+			rowspan, _ := strconv.Atoi(cell.attr("rowspan"))
+			row = append(row, text)
+			for k := 1; k < colspan; k++ {
+				row = append(row, colspanMarker)
+			}
+			for r := 1; r < rowspan; r++ {
+				for k := 0; k < max(colspan, 1); k++ {
+					pending[[2]int{i + r, col + k}] = rowspanMarker
+				}
+			}
+			col += max(colspan, 1)
+		}
+		next()
+		grid = append(grid, row)
+	}
+
+	columns := 0
+	for _, row := range grid {
+		columns = max(columns, len(row))
+	}
+
+	line := func(cells []string) string {
+		padded := make([]string, columns)
+		copy(padded, cells)
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+	separator := make([]string, columns)
+	for j := range separator {
+		switch aligns[j] {
+		case "center":
+			separator[j] = ":---:"
+		case "right":
+			separator[j] = "---:"
+		case "left":
+			separator[j] = ":---"
+		default:
+			separator[j] = "---"
+		}
+	}
+
+	out := []string{line(grid[0]), "| " + strings.Join(separator, " | ") + " |"}
+	for _, row := range grid[1:] {
+		out = append(out, line(row))
+	}
+	return strings.Join(out, "\n")
+}
+
+// cellAlign 读取单元格的对齐方式
+func cellAlign(cell *htmlNode) string {
+	if align := strings.ToLower(cell.attr("align")); align != "" {
+		return align
+	}
+	for _, decl := range strings.Split(cell.attr("style"), ";") {
+		if k, v, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(strings.ToLower(k)) == "text-align" {
+			return strings.TrimSpace(strings.ToLower(v))
+		}
+	}
+	return ""
+}
+
+// inline 转换一组行内节点，<br> 转为换行
+func (imp *htmlImporter) inline(nodes []*htmlNode) string {
+	var pieces []string
+	for _, n := range nodes {
+		if n.Tag == "" {
+			pieces = append(pieces, whitespaceRegex.ReplaceAllString(strings.ReplaceAll(n.Text, "\u00a0", " "), " "))
+			continue
+		}
+		if skipTags[n.Tag] {
+			continue
+		}
+
+		switch n.Tag {
+		case "br":
+			pieces = append(pieces, "\n")
+		case "strong", "b":
+			pieces = append(pieces, wrapInline(imp.inline(n.Children), "**"))
+		case "em", "i":
+			pieces = append(pieces, wrapInline(imp.inline(n.Children), "*"))
+		case "code", "kbd":
+			if code := strings.TrimSpace(n.textContent()); code != "" {
+				pieces = append(pieces, "`"+code+"`")
+			}
+		case "a":
+			text := oneLine(imp.inline(n.Children))
+			href := strings.TrimSpace(n.attr("href"))
+			if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") || text == "" {
+				pieces = append(pieces, text)
+			} else {
+				pieces = append(pieces, "["+text+"]("+href+")")
+			}
+		case "img":
+			src := n.attr("data-src")
+			if src == "" {
+				src = n.attr("src")
+			}
+			if src != "" {
+				imp.addImage(src)
+				pieces = append(pieces, "!["+n.attr("alt")+"]("+src+")")
+			}
+		case "sup":
+			// 本项目的脚注：链接为 <span>文字</span><sup>[n]</sup>，文字脚注还原为 [^n]
+			text := strings.TrimSpace(n.textContent())
+			if m := footnoteRefText.FindStringSubmatch(text); m != nil && imp.footnotes[m[1]] != "" {
+				if !isFootnoteURL(imp.footnotes[m[1]]) {
+					if !containsString(imp.notes, m[1]) {
+						imp.notes = append(imp.notes, m[1])
+					}
+					pieces = append(pieces, "[^"+m[1]+"]")
+					continue
+				}
+				if len(pieces) > 0 {
+					last := pieces[len(pieces)-1]
+					if t := strings.TrimSpace(last); t != "" {
+						pieces[len(pieces)-1] = strings.Replace(last, t, "["+t+"]("+imp.footnotes[m[1]]+")", 1)
+						continue
+					}
+				}
+			}
+			pieces = append(pieces, imp.inline(n.Children))
+		case "svg":
+			if n.attr("data-formula") != "" {
+				pieces = append(pieces, "$"+mathTeX(n)+"$")
+			}
+		default:
+			if n.hasClass("inline-equation") || n.attr("data-formula") != "" {
+				pieces = append(pieces, "$"+mathTeX(n)+"$")
+				continue
+			}
+			if blockTags[n.Tag] {
+				pieces = append(pieces, "\n"+strings.Join(imp.blocks(n), "\n")+"\n")
+				continue
+			}
+			text := imp.inline(n.Children)
+			// 微信编辑器常用 font-weight 样式表示加粗
+			style := strings.ReplaceAll(strings.ToLower(n.attr("style")), " ", "")
+			if strings.Contains(style, "font-weight:bold") || strings.Contains(style, "font-weight:700") {
+				text = wrapInline(text, "**")
+			}
+			pieces = append(pieces, text)
+		}
+	}
+	return strings.Join(pieces, "")
+}
+
+// isFootnoteURL 判断脚注内容是否为链接地址
+func isFootnoteURL(s string) bool {
+	return !strings.ContainsAny(s, " \t") && (strings.Contains(s, "://") || strings.HasPrefix(s, "mailto:"))
+}
+
+// addImage 记录图片地址
+func (imp *htmlImporter) addImage(src string) {
+	if !containsString(imp.images, src) {
+		imp.images = append(imp.images, src)
+	}
+}
+
+// wrapInline 用强调标记包裹文本，标记放在首尾空白之内
+func wrapInline(text, mark string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || strings.Contains(trimmed, "\n") {
+		return text
+	}
+	if strings.HasPrefix(trimmed, mark) && strings.HasSuffix(trimmed, mark) {
+		return text
+	}
+	i := strings.Index(text, trimmed)
+	return text[:i] + mark + trimmed + mark + text[i+len(trimmed):]
+}
+
+// mathTeX 提取公式源码：优先使用 data-formula，其次去掉 \( \) 等定界符
+func mathTeX(n *htmlNode) string {
+	if f := n.attr("data-formula"); f != "" {
+		return strings.TrimSpace(f)
+	}
+	if svg := n.find(func(c *htmlNode) bool { return c.attr("data-formula") != "" }); svg != nil {
+		return strings.TrimSpace(svg.attr("data-formula"))
+	}
+	tex := strings.TrimSpace(n.textContent())
+	for _, pair := range [][2]string{{`\(`, `\)`}, {`\[`, `\]`}, {"$$", "$$"}, {"$", "$"}} {
+		if strings.HasPrefix(tex, pair[0]) && strings.HasSuffix(tex, pair[1]) && len(tex) >= len(pair[0])+len(pair[1]) {
+			return strings.TrimSpace(tex[len(pair[0]) : len(tex)-len(pair[1])])
+		}
+	}
+	return tex
+}
+
+// oneLine 将文本合并为一行
+func oneLine(text string) string {
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(text, " "))
+}
+
+// SaveImages 下载导入结果中的图片到 dir，并把 Markdown 中的地址改为 linkPrefix 加文件名；下载失败的图片保留原地址并记录警告
+func (r *ImportResult) SaveImages(dir, linkPrefix string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, src := range r.Images {
+		var data []byte
+		var mimeType string
+		var err error
+		if strings.HasPrefix(src, "data:") {
+			data, mimeType, err = decodeDataURI(src)
+		} else if isRemoteImage(src) {
+			data, err = fetchImage(src)
+		} else {
+			continue
+		}
+		if err != nil {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("image %s: %v", src, err))
+			continue
+		}
+
+		name := contentHash(data)[:16] + importImageExt(src, mimeType, data)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+
+		local := linkPrefix + name
+		r.Markdown = strings.ReplaceAll(r.Markdown, "]("+src+")", "]("+local+")")
+		if r.FrontMatter["cover"] == src {
+			r.FrontMatter["cover"] = local
+			r.Markdown = strings.Replace(r.Markdown, "cover: \""+src+"\"", "cover: \""+local+"\"", 1)
+		}
+		r.Images[i] = local
+	}
+	return nil
+}
+
+// importImageExt 推断下载图片的扩展名：微信图片地址带 wx_fmt 参数
+func importImageExt(src, mimeType string, data []byte) string {
+	if u, err := url.Parse(src); err == nil && !strings.HasPrefix(src, "data:") {
+		if f := u.Query().Get("wx_fmt"); f != "" {
+			if f == "jpeg" {
+				f = "jpg"
+			}
+			return "." + f
+		}
+		if ext := strings.ToLower(path.Ext(u.Path)); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return extensionForMime(mimeType)
+}
@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// importSample 包含导入时需要还原的各种元素，脚注编号与转换器生成的编号相同，导入结果应与原文一致
+const importSample = "# 标题\n\n" +
+	"正文 **粗体** *斜体* `code` [链接](https://example.com) 见[^1]\n\n" +
+	"- 甲\n- 乙\n\n" +
+	"1. 一\n2. 二\n\n" +
+	"> 引用\n\n" +
+	"```\nfunc main() {\n\tx := 1\n}\n```\n\n" +
+	"| 名称 | 值 |\n| :--- | ---: |\n| a | 1 |\n\n" +
+	"![图](https://example.com/a.png)\n\n" +
+	"[^1]: 注释\n"
+
+// TestImportConverterOutput 导入本项目的输出得到原文，再次转换和导入的结果不变
+func TestImportConverterOutput(t *testing.T) {
+	result, err := ImportHTML(NewWechatConverterFixed().ConvertMarkdownToWechat(importSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Markdown != importSample {
+		t.Errorf("imported markdown:\n%s\nwant:\n%s", result.Markdown, importSample)
+	}
+	if want := []string{"https://example.com/a.png"}; !slices.Equal(result.Images, want) {
+		t.Errorf("images = %q, want %q", result.Images, want)
+	}
+
+	// 各平台的输出（表格可能转为字符画、代码块可能去掉语言）导入一次后即稳定
+	for _, name := range Profiles() {
+		profile, _ := GetProfile(name)
+		roundTrip := func(markdown string) string {
+			conv := NewWechatConverterFixed()
+			conv.SetProfile(profile)
+			result, err := ImportHTML(conv.ConvertMarkdownToWechat(markdown))
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			return result.Markdown
+		}
+		once := roundTrip(importSample)
+		if twice := roundTrip(once); twice != once {
+			t.Errorf("%s: round trip not stable\nfirst:\n%s\nsecond:\n%s", name, once, twice)
+		}
+	}
+}
+
+// TestImportHTML 微信编辑器等生成的常见写法
+func TestImportHTML(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		html   string
+		want   string
+		images []string
+	}{
+		{
+			"front matter",
+			`<html><head><title>页面标题</title><meta property="og:title" content="文章  &quot;标题&quot;"><meta property="og:image" content="https://mmbiz.qpic.cn/c.jpg">` +
+				`<meta property="article:published_time" content="2024-03-05T23:00:00+08:00"><meta name="author" content="作者">` +
+				`<script>var msg_desc = "摘要";var msg_link = 'https://mp.weixin.qq.com/s/x';</script></head>` +
+				`<body><div id="js_content"><p>正文</p></div></body></html>`,
+			"---\ntitle: \"文章 \\\"标题\\\"\"\nauthor: \"作者\"\ndate: \"2024-03-05\"\ndigest: \"摘要\"\n" +
+				"cover: \"https://mmbiz.qpic.cn/c.jpg\"\nurl: \"https://mp.weixin.qq.com/s/x\"\n---\n\n正文\n",
+			[]string{"https://mmbiz.qpic.cn/c.jpg"},
+		},
+		{
+			"title from page",
+			`<h1 class="rich_media_title"> 页面 标题 </h1><div class="rich_media_content"><p>正文</p></div>`,
+			"---\ntitle: \"页面 标题\"\n---\n\n正文\n",
+			nil,
+		},
+		{
+			"code snippet",
+			`<div id="js_content"><section class="code-snippet__fix code-snippet__js"><ul class="code-snippet__line-index code-snippet__js"><li></li><li></li></ul>` +
+				`<pre class="code-snippet__js" data-lang="javascript"><code><span class="code-snippet__keyword">let</span> a&nbsp;=&nbsp;1;</code><code>a &lt; 2;</code></pre></section></div>`,
+			"```javascript\nlet a = 1;\na < 2;\n```\n",
+			nil,
+		},
+		{
+			"pre-styled section",
+			`<div id="js_content"><section style="white-space: pre;">x &lt; 1
+y</section></div>`,
+			"```\nx < 1\ny\n```\n",
+			nil,
+		},
+		{
+			"pre with language class",
+			`<div id="js_content"><pre><code class="language-go">fmt.Println()</code></pre></div>`,
+			"```go\nfmt.Println()\n```\n",
+			nil,
+		},
+		{
+			"table",
+			`<div id="js_content"><table><thead><tr><th style="text-align: center;">名称</th><th align="right">值</th></tr></thead>` +
+				`<tbody><tr><td colspan="2">合并</td></tr><tr><td rowspan="2">a|b</td><td><strong>1</strong></td></tr><tr><td>2</td></tr></tbody></table></div>`,
+			"| 名称 | 值 |\n| :---: | ---: |\n| 合并 | < |\n| a\\|b | **1** |\n| ^ | 2 |\n",
+			nil,
+		},
+		{
+			"footnote sup",
+			`<div id="js_content"><p>见<span>文档</span><sup>[1]</sup>，另有说明<sup>[2]</sup>，未知<sup>[3]</sup>。</p>` +
+				`<hr><h3>参考资料</h3><p>[1] https://a.example/doc</p><p>[2] 说明 文字</p></div>`,
+			"见[文档](https://a.example/doc)，另有说明[^2]，未知[3]。\n\n[^2]: 说明 文字\n",
+			nil,
+		},
+		{
+			"editor styles",
+			`<div id="js_content"><p><span style="font-weight: bold;">加粗</span> <span style="color:red">红</span><br>第二行</p>` +
+				`<p><img data-src="https://mmbiz.qpic.cn/a.png?wx_fmt=png" src="data:image/gif;base64,R0" alt="图"></p>` +
+				`<p><span class="inline-equation" data-formula="a+b"></span> <a href="javascript:void(0)">脚本</a></p><mpvoice name="x"></mpvoice></div>`,
+			"**加粗** 红\n第二行\n\n![图](https://mmbiz.qpic.cn/a.png?wx_fmt=png)\n\n$a+b$ 脚本\n",
+			[]string{"https://mmbiz.qpic.cn/a.png?wx_fmt=png"},
+		},
+		{
+			"lists and quotes",
+			`<div id="js_content"><ol><li>一</li><li>二<ul><li>嵌套</li></ul></li></ol><blockquote><p>甲</p><p>乙</p></blockquote></div>`,
+			"1. 一\n2. 二\n  - 嵌套\n\n> 甲\n>\n> 乙\n",
+			nil,
+		},
+	} {
+		result, err := ImportHTML(tc.html)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if result.Markdown != tc.want {
+			t.Errorf("%s: markdown = %q, want %q", tc.name, result.Markdown, tc.want)
+		}
+		if !slices.Equal(result.Images, tc.images) {
+			t.Errorf("%s: images = %q, want %q", tc.name, result.Images, tc.images)
+		}
+	}
+
+	for _, src := range []string{"", "<div id=\"js_content\"> <script>x</script> </div>"} {
+		if _, err := ImportHTML(src); !errors.Is(err, ErrNoContent) {
+			t.Errorf("%q: err = %v, want ErrNoContent", src, err)
+		}
+	}
+}
+
+// dumpHTML 以 tag[attr=value](子节点) 的形式输出节点树，文本节点带引号
+func dumpHTML(n *htmlNode) string {
+	if n.Tag == "" {
+		return fmt.Sprintf("%q", n.Text)
+	}
+	var b strings.Builder
+	b.WriteString(n.Tag)
+	keys := make([]string, 0, len(n.Attrs))
+	for k := range n.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "[%s=%s]", k, n.Attrs[k])
+	}
+	if len(n.Children) > 0 {
+		children := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = dumpHTML(child)
+		}
+		b.WriteString("(" + strings.Join(children, " ") + ")")
+	}
+	return b.String()
+}
+
+// TestParseHTML 宽松解析：未闭合的标签自动关闭，多余的结束标签忽略，脚本内容不解析标签
+func TestParseHTML(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want string
+	}{
+		{`<p>a<p>b`, `#root(p("a") p("b"))`},
+		{`<ul><li>a<li>b</ul>`, `#root(ul(li("a") li("b")))`},
+		{`<table><tr><td>1<td>2<tr><td>3</table>`, `#root(table(tr(td("1") td("2")) tr(td("3"))))`},
+		{`<P CLASS='x y' data-a=1 hidden>t</P>`, `#root(p[class=x y][data-a=1][hidden=]("t"))`},
+		{`a &amp; &lt;b&gt; &nbsp;<br>c<img src="x.png"/>d`, `#root("a & <b> \u00a0" br "c" img[src=x.png] "d")`},
+		{`<div>a</span>b</div>`, `#root(div("a" "b"))`},
+		{`<script>if (a<b) {}</div></script><p>x`, `#root(script p("x"))`},
+		{`<!-- <p>注释</p> --><p>y</p><!DOCTYPE html>`, `#root(p("y"))`},
+		{`<b><i>x</b>y`, `#root(b(i("x")) "y")`},
+		{`<ul><li><p>a<li>b</ul>`, `#root(ul(li(p("a")) li("b")))`},
+		{`<title>a &amp; <b></title>`, `#root(title("a & <b>"))`},
+	} {
+		if got := dumpHTML(parseHTML(tc.src)); got != tc.want {
+			t.Errorf("parseHTML(%q) = %s, want %s", tc.src, got, tc.want)
+		}
+	}
+}
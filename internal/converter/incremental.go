@@ -0,0 +1,306 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RenderedBlock 单独渲染的顶层块；ID 为 HTML 的哈希，内容相同的块 ID 相同
+type RenderedBlock struct {
+	ID      string `json:"id"`
+	HTML    string `json:"-"`
+	Line    int    `json:"line"`
+	EndLine int    `json:"end_line"`
+}
+
+// blockResult 块的渲染结果，以及渲染后新增的脚注和标题编号状态；放入缓存后只读
+type blockResult struct {
+	html string
+	// refNotes、linkNotes 新增的引用脚注和链接脚注
+	refNotes  []string
+	linkNotes []string
+	// numbers 新编号的引用脚注，refIDs 按编号顺序排列的这些脚注的 id
+	numbers map[string]int
+	refIDs  []string
+	// counters 渲染后的标题编号计数，只在块中有标题时使用
+	counters   [3]int
+	hasHeading bool
+}
+
+// blockState 按顶层块渲染时前面的块留下的状态。整篇转换先为全部 [^id] 引用、再按出现顺序为链接（包括表格中的链接）编号，
+// 因此两类脚注分开记录，链接脚注从 totalRefs 之后开始编号
+type blockState struct {
+	refNotes  []string
+	linkNotes []string
+	numbers   map[string]int
+	counters  [3]int
+	totalRefs int
+}
+
+// BlockRenderer 按顶层块增量渲染文章：块的结果按原文、渲染选项和用到的上下文（脚注编号、标题编号）缓存，
+// 编辑时只有内容或上下文变化的块重新渲染
+type BlockRenderer struct {
+	conv  *WechatConverter
+	cache *BlockCache
+}
+
+// NewBlockRenderer 创建增量渲染器，样式、平台、表格和标题编号等选项取自 conv；
+// conv 设置了 SetBlockCache 时共用该缓存，否则使用默认大小的缓存
+func NewBlockRenderer(conv *WechatConverter) *BlockRenderer {
+	cache := conv.blockCache
+	if cache == nil {
+		cache = NewBlockCache(0)
+	}
+	return &BlockRenderer{conv: conv, cache: cache}
+}
+
+// SetBlockCache 设置块缓存，之后 ConvertMarkdownToWechat 按顶层块转换，未变化的块直接使用缓存的 HTML；
+// 为 nil 时整篇转换。行内的 ``` 或 $$ 与其他块中的配对成代码块或公式时仍整篇转换
+func (c *WechatConverter) SetBlockCache(cache *BlockCache) {
+	c.blockCache = cache
+}
+
+// Render 渲染整篇文章，返回顶层块和文末脚注的 HTML；
+// 块的 HTML 以换行连接、再接上脚注，与 ConvertMarkdownToWechat 的结果相同（不含图片处理和源码行号，
+// 行内的 ``` 与其他块中的 ``` 配对成代码块的情况除外）
+func (r *BlockRenderer) Render(markdown string) ([]RenderedBlock, string) {
+	markdown = expandStatsPlaceholders(markdown, ComputeStats(Parse(markdown)))
+	markdown = blankFrontMatter(markdown)
+
+	c := *r.conv
+	blocks, _ := c.renderBlocks(markdown, Parse(markdown), r.cache)
+	if len(c.footnotes) == 0 {
+		return blocks, ""
+	}
+	return blocks, sanitizeHTML(c.generateFootnotes(), c.profile.AllowedTags)
+}
+
+// convertBlocks 使用块缓存转换正文，结果与 renderBody 加上文末脚注、过滤标签和源码行号后相同；
+// 有跨块配对的 ```、$$ 等按块渲染与整篇转换不一致的情况时返回 false，需要重置转换状态后整篇转换
+func (c *WechatConverter) convertBlocks(markdown string) (string, bool) {
+	doc := Parse(markdown)
+	lines := strings.Split(markdown, "\n")
+	for _, block := range doc.Blocks {
+		text := block.Text()
+		if block.Type == BlockCode {
+			// 未闭合的代码块在整篇转换中不是代码块，结束围栏之后的文字留在正文中
+			closed := block.EndLine > block.StartLine && strings.TrimSpace(lines[block.EndLine-1]) == "```"
+			if !closed || strings.Contains(text, "```") {
+				return "", false
+			}
+			continue
+		}
+		// 块中未配对的 $$ 会与其他块的 $$ 配对成公式
+		if strings.Contains(text, "```") || strings.Contains(text, "$$") && strings.Contains(blockMathRegex.ReplaceAllString(text, ""), "$$") {
+			return "", false
+		}
+	}
+	blocks, ok := c.renderBlocks(markdown, doc, c.blockCache)
+	if !ok {
+		return "", false
+	}
+
+	c.sourceMap = nil
+	parts := make([]string, len(blocks))
+	for i, block := range blocks {
+		parts[i] = block.HTML
+		if !c.sourceLines {
+			continue
+		}
+		var n int
+		for parts[i], n = addDataLines(block.HTML, block.Line); n > 0; n-- {
+			c.sourceMap = append(c.sourceMap, SourceBlock{Line: block.Line, EndLine: block.EndLine})
+		}
+	}
+	html := strings.Join(parts, "\n")
+	if len(c.footnotes) > 0 {
+		html += sanitizeHTML(c.generateFootnotes(), c.profile.AllowedTags)
+	}
+	return html, true
+}
+
+// renderBlocks 逐个渲染 doc 的顶层块，块的结果经 cache 缓存，块的 HTML 已按平台过滤标签，
+// 没有输出的块（如脚注定义）不返回；脚注、脚注定义和标题编号写回 c。
+// 各块的脚注数与单独渲染时不一致时返回 false
+func (c *WechatConverter) renderBlocks(markdown string, doc *Document, cache *BlockCache) ([]RenderedBlock, bool) {
+	// 与 renderBody 相同替换 NUL，脚注定义在这里直接从原文收集
+	lines := strings.Split(strings.ReplaceAll(markdown, "\x00", "\uFFFD"), "\n")
+	texts := make([]string, len(doc.Blocks))
+	for i, block := range doc.Blocks {
+		texts[i] = strings.Join(lines[block.StartLine-1:block.EndLine], "\n")
+	}
+
+	// 脚注定义可以写在引用之后，先收集全部定义
+	defs := make(map[string]string)
+	for _, block := range doc.Blocks {
+		if block.Type != BlockParagraph {
+			continue
+		}
+		// 与 extractFootnoteDefs 一样按原始行匹配，缩进的定义不算
+		for _, line := range lines[block.StartLine-1 : block.EndLine] {
+			if m := footnoteDefRegex.FindStringSubmatch(line); m != nil {
+				if _, ok := defs[m[1]]; !ok {
+					defs[m[1]] = strings.TrimSpace(m[2])
+				}
+			}
+		}
+	}
+
+	// 先用空状态渲染（或从缓存取）各块，得到引用的 id，算出链接脚注的起始编号
+	opts := c.optionsKey()
+	empty := &blockState{numbers: make(map[string]int)}
+	st := &blockState{numbers: make(map[string]int)}
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		res := c.cachedBlock(cache, opts, text, empty, defs)
+		for _, id := range res.refIDs {
+			if !seen[id] {
+				seen[id] = true
+				st.totalRefs++
+			}
+		}
+	}
+
+	ok := true
+	var blocks []RenderedBlock
+	for i, block := range doc.Blocks {
+		res := c.cachedBlock(cache, opts, texts[i], st, defs)
+		if len(st.refNotes)+len(res.refNotes) > st.totalRefs {
+			ok = false
+		}
+
+		st.refNotes = append(st.refNotes, res.refNotes...)
+		st.linkNotes = append(st.linkNotes, res.linkNotes...)
+		for id, n := range res.numbers {
+			st.numbers[id] = n
+		}
+		if res.hasHeading {
+			st.counters = res.counters
+		}
+		if res.html != "" {
+			blocks = append(blocks, RenderedBlock{ID: hashHTML(res.html), HTML: res.html, Line: block.StartLine, EndLine: block.EndLine})
+		}
+	}
+
+	c.footnotes = append(st.refNotes, st.linkNotes...)
+	c.noteNumbers = st.numbers
+	c.noteDefs = defs
+	c.numberer = newHeadingNumberer(c.headingNumbering)
+	c.numberer.counters = st.counters
+	return blocks, ok
+}
+
+// cachedBlock 从缓存中取块在状态 st 下的渲染结果，没有时渲染并放入缓存
+func (c *WechatConverter) cachedBlock(cache *BlockCache, opts, text string, st *blockState, defs map[string]string) *blockResult {
+	key := c.blockKey(opts, text, st, defs)
+	res, ok := cache.get(key)
+	if !ok {
+		res = c.renderBlock(text, st, defs)
+		cache.put(key, res)
+	}
+	return res
+}
+
+// optionsKey 返回影响块渲染结果的选项的哈希：样式、平台、表格和标题编号
+func (c *WechatConverter) optionsKey() string {
+	data, _ := json.Marshal(struct {
+		Styles    WechatStyles
+		Profile   Profile
+		Table     TableOptions
+		Numbering HeadingNumbering
+	}{c.styles, c.profile, c.tableOptions, c.headingNumbering})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// blockKey 生成块的缓存键：选项、原文加上块的渲染结果依赖的上下文
+func (c *WechatConverter) blockKey(opts, text string, st *blockState, defs map[string]string) string {
+	var b strings.Builder
+	b.WriteString(opts)
+	b.WriteString(text)
+	// 链接的编号依赖前面的同类脚注数，以及全文中先编号的脚注数
+	if strings.Contains(text, "](") {
+		b.WriteString("\x00l" + strconv.Itoa(st.totalRefs+len(st.linkNotes)))
+	}
+	// 引用的编号依赖前面的引用脚注数和已有的编号
+	if strings.Contains(text, "[^") {
+		b.WriteString("\x00r" + strconv.Itoa(len(st.refNotes)))
+		refs := footnoteRefRegex.FindAllStringSubmatch(text, -1)
+		ids := make([]string, 0, len(refs))
+		for _, m := range refs {
+			ids = append(ids, m[1])
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			def, ok := defs[id]
+			b.WriteString("\x00" + id + "\x00" + strconv.Itoa(st.numbers[id]) + "\x00" + strconv.FormatBool(ok) + def)
+		}
+	}
+	if c.headingNumbering != NumberingNone && numberedHeadingRegex.MatchString(text) {
+		b.WriteString("\x00h" + strconv.Itoa(st.counters[0]) + "." + strconv.Itoa(st.counters[1]) + "." + strconv.Itoa(st.counters[2]))
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// renderBlock 以前面的块留下的状态渲染单个块，不修改 c 和 st
+func (c *WechatConverter) renderBlock(text string, st *blockState, defs map[string]string) *blockResult {
+	b := *c
+	b.footnotes = nil
+	b.noteBases = []int{len(st.refNotes), st.totalRefs + len(st.linkNotes)}
+	b.noteMarks = nil
+	b.warnings = nil
+	b.sourceLines = false
+	b.noteDefs = make(map[string]string, len(defs))
+	for id, def := range defs {
+		b.noteDefs[id] = def
+	}
+	b.noteNumbers = make(map[string]int, len(st.numbers))
+	for id, n := range st.numbers {
+		b.noteNumbers[id] = n
+	}
+	b.numberer = newHeadingNumberer(c.headingNumbering)
+	b.numberer.counters = st.counters
+
+	res := &blockResult{
+		html:       sanitizeHTML(b.renderBody(text), b.profile.AllowedTags),
+		numbers:    make(map[string]int),
+		counters:   b.numberer.counters,
+		hasHeading: numberedHeadingRegex.MatchString(text),
+	}
+	for id, n := range b.noteNumbers {
+		if _, ok := st.numbers[id]; !ok {
+			res.numbers[id] = n
+			res.refIDs = append(res.refIDs, id)
+		}
+	}
+	sort.Slice(res.refIDs, func(i, j int) bool { return res.numbers[res.refIDs[i]] < res.numbers[res.refIDs[j]] })
+
+	// noteMarks 为处理引用和链接之前的脚注数，前面的块脚注数超出预计时没有补齐，从标记处开始
+	refStart := max(b.noteMarks[0], b.noteBases[0])
+	linkStart := max(b.noteMarks[1], b.noteBases[1])
+	res.refNotes = b.footnotes[refStart:b.noteMarks[1]]
+	res.linkNotes = b.footnotes[linkStart:]
+	return res
+}
+
+// padNotes 按块渲染时在处理第 i 类脚注之前记录当前的脚注数，并用空项补足到该类脚注在全文中的起始位置
+func (c *WechatConverter) padNotes(i int) {
+	if i >= len(c.noteBases) {
+		return
+	}
+	c.noteMarks = append(c.noteMarks, len(c.footnotes))
+	for len(c.footnotes) < c.noteBases[i] {
+		c.footnotes = append(c.footnotes, "")
+	}
+}
+
+// hashHTML 返回 HTML 的短哈希，用作块 ID
+func hashHTML(html string) string {
+	sum := sha256.Sum256([]byte(html))
+	return hex.EncodeToString(sum[:8])
+}
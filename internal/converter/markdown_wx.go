@@ -4,31 +4,65 @@ import (
 	"fmt"
 	"html"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
+// 转换用到的正则表达式，只在包初始化时编译一次；正文已转义，链接和图片不跨行也不跨越已生成的标签
+var (
+	linkRegex  = regexp.MustCompile(`\[([^\]\n<>]+)\]\(([^)\n<>]+)\)`)
+	imageRegex = regexp.MustCompile(`!\[([^\]\n<>]*)\]\(([^)\n<>]+)\)`)
+)
+
 // WechatConverter 微信公众号Markdown转换器
 type WechatConverter struct {
-	footnotes  []string
-	styles     WechatStyles
-	codeBlocks map[string]string
+	footnotes        []string
+	styles           WechatStyles
+	codeBlocks       map[string]string
+	imageOptions     ImageOptions
+	uploader         ImageUploader
+	fetcher          *ImageFetcher
+	warnings         []Warning
+	stats            Stats
+	profile          Profile
+	mathBlocks       map[string]string
+	tableOptions     TableOptions
+	noteDefs         map[string]string
+	noteNumbers      map[string]int
+	headingNumbering HeadingNumbering
+	source           string
+	sourceLines      bool
+	sourceMap        []SourceBlock
+	sourceEnds       map[int]int
+	numberer         *headingNumberer
+	blockCache       *BlockCache
+	// inlineTokens 行内元素生成的 HTML，正文中用 inlineToken 占位，粗体斜体处理后恢复
+	inlineTokens []string
+	// tableLinks 表格单元格中的链接原文，由 processLinks 与正文链接一起按文档顺序处理
+	tableLinks []string
+	// noteBases 按块渲染时引用脚注和链接脚注在全文中的起始位置，noteMarks 为补齐前的脚注数；整篇转换时为空
+	noteBases []int
+	noteMarks []int
 }
 
 // WechatStyles 微信公众号样式定义
 type WechatStyles struct {
-	H1Style         string
-	H2Style         string
-	H3Style         string
-	ParagraphStyle  string
-	QuoteStyle      string
-	CodeBlockStyle  string
-	InlineCodeStyle string
-	ListStyle       string
-	LinkStyle       string
-	ImageStyle      string
-	TableStyle      string
-	TableHeaderStyle string
-	TableCellStyle   string
+	H1Style             string
+	H2Style             string
+	H3Style             string
+	ParagraphStyle      string
+	QuoteStyle          string
+	CodeBlockStyle      string
+	InlineCodeStyle     string
+	ListStyle           string
+	LinkStyle           string
+	ImageStyle          string
+	TableStyle          string
+	TableHeaderStyle    string
+	TableCellStyle      string
+	TableScrollStyle    string
+	TableCardStyle      string
+	TableCardLabelStyle string
 }
 
 // NewWechatConverterFixed 创建新的转换器
@@ -37,6 +71,8 @@ func NewWechatConverterFixed() *WechatConverter {
 		footnotes:  make([]string, 0),
 		styles:     getDefaultStyles(),
 		codeBlocks: make(map[string]string),
+		profile:    profiles[DefaultProfile],
+		mathBlocks: make(map[string]string),
 	}
 }
 
@@ -68,148 +104,188 @@ func getDefaultStyles() WechatStyles {
 		TableHeaderStyle: `style="background: rgb(0 0 0 / 5%); border: 1px solid #ddd; padding: 0.25em 0.5em;"`,
 		
 		TableCellStyle: `style="border: 1px solid #ddd; padding: 0.25em 0.5em;"`,
+		
+		TableScrollStyle: `style="overflow-x: auto; -webkit-overflow-scrolling: touch; margin: 1em 0;"`,
+		
+		TableCardStyle: `style="margin: 0.8em 0; padding: 0.6em 0.8em; border: 1px solid #ddd; border-radius: 4px; font-size: 14px; line-height: 1.6;"`,
+		
+		TableCardLabelStyle: `style="color: #888; margin-right: 0.5em;"`,
 	}
 }
 
 // ConvertMarkdownToWechat 将Markdown转换为微信公众号格式
 func (c *WechatConverter) ConvertMarkdownToWechat(markdown string) string {
-	// 重置脚注
+	// 重置警告，保留原文用于诊断
+	c.warnings = nil
+	c.source = markdown
+	
+	// 统计信息，并替换正文中的 {{reading_time}} 等占位符
+	c.stats = ComputeStats(Parse(markdown))
+	markdown = expandStatsPlaceholders(markdown, c.stats)
+	
+	// 去掉文章元信息，保留空行以保持行号
+	markdown = blankFrontMatter(markdown)
+	
+	// 解析本地图片路径（需要原始行号）
+	markdown = c.resolveImages(markdown)
+	markdown = c.escapeLineMarks(markdown)
+	
+	// 设置了块缓存时按块转换，未变化的块不重新渲染
+	if c.blockCache != nil {
+		if html, ok := c.convertBlocks(markdown); ok {
+			return html
+		}
+	}
+	
+	// 重置脚注和标题编号
 	c.footnotes = make([]string, 0)
+	c.noteDefs = make(map[string]string)
+	c.noteNumbers = make(map[string]int)
+	c.numberer = newHeadingNumberer(c.headingNumbering)
+	markdown = c.markSourceLines(markdown)
+	html := c.renderBody(markdown)
 	
-	// 预处理：处理特殊格式
-	html := c.preprocessText(markdown)
+	// 按目标平台过滤标签和属性，源码行号在过滤之后添加，文末脚注不加行号
+	html = c.applySourceLines(sanitizeHTML(html, c.profile.AllowedTags))
 	
-	// 预处理：处理代码块，避免其他规则干扰
+	// 添加脚注
+	if len(c.footnotes) > 0 {
+		html += sanitizeHTML(c.generateFootnotes(), c.profile.AllowedTags)
+	}
+	return html
+}
+
+// renderBody 转换去掉元信息后的正文，不含文末脚注和标签过滤；
+// 脚注编号和标题编号接着转换器中已有的状态继续
+func (c *WechatConverter) renderBody(markdown string) string {
+	// 预处理：处理特殊格式，NUL 用作行内元素占位符
+	html := c.preprocessText(strings.ReplaceAll(markdown, "\x00", "\uFFFD"))
+	
+	// 预处理：处理代码块和数学公式，避免其他规则干扰
+	c.codeBlocks = make(map[string]string)
 	html = c.extractCodeBlocks(html)
+	html = c.extractFootnoteDefs(html)
+	c.mathBlocks = make(map[string]string)
+	html = c.extractMath(html)
+	
+	// 转义其余文本，之后只有转换规则生成的标签
+	html = escapeText(html)
+	c.inlineTokens = nil
+	c.tableLinks = nil
 	
 	// 转换各种元素
+	html = c.numberHeadings(html)
 	html = c.processHeaders(html)
 	html = c.processQuotes(html)
 	html = c.processTables(html)
 	html = c.processLists(html)
-	html = c.processLinks(html)
-	html = c.processImages(html)
 	html = c.processInlineCode(html)
+	html = c.processImages(html)
+	c.padNotes(0)
+	html = c.processFootnoteRefs(html)
+	c.padNotes(1)
+	html = c.processLinks(html)
 	html = c.processBoldItalic(html)
+	html = c.restoreInlineTokens(html)
 	html = c.processParagraphs(html)
 	
-	// 恢复代码块
+	// 恢复代码块和数学公式
+	html = c.restoreMath(html)
 	html = c.restoreCodeBlocks(html)
 	
-	// 添加脚注
-	if len(c.footnotes) > 0 {
-		html += c.generateFootnotes()
-	}
-	
 	return html
 }
 
 // extractCodeBlocks 提取代码块并用占位符替换
 func (c *WechatConverter) extractCodeBlocks(text string) string {
-	codeBlockRegex := regexp.MustCompile("(?s)```(.*?)\n(.*?)```")
-	counter := 0
-	
-	result := codeBlockRegex.ReplaceAllStringFunc(text, func(match string) string {
-		placeholder := fmt.Sprintf("__CODE_BLOCK_%d__", counter)
-		
+	return replaceCodeFences(text, func(info, code string) string {
 		// 提取语言和代码
-		matches := codeBlockRegex.FindStringSubmatch(match)
-		code := matches[2]
-		
-		// 生成微信公众号代码块HTML（不显示语言标识符）
-		escapedCode := html.EscapeString(code)
-		codeHTML := fmt.Sprintf(`<section %s>%s</section>`,
-			c.styles.CodeBlockStyle, escapedCode)
-		
-		c.codeBlocks[placeholder] = codeHTML
-		counter++
-		return placeholder
+		return c.stashCodeBlock(c.renderCodeBlock(code, strings.TrimSpace(info)))
 	})
+}
+
+// renderCodeBlock 生成代码块HTML
+func (c *WechatConverter) renderCodeBlock(code, lang string) string {
+	escapedCode := html.EscapeString(code)
+	
+	// 支持代码高亮的平台使用 pre/code，保留语言标识
+	if c.profile.CodeStyle == CodePre {
+		class := ""
+		if lang != "" {
+			class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(lang))
+		}
+		return fmt.Sprintf(`<pre %s><code%s>%s</code></pre>`,
+			c.styles.CodeBlockStyle, class, escapedCode)
+	}
 	
-	return result
+	// 生成微信公众号代码块HTML（不显示语言标识符）
+	return fmt.Sprintf(`<section %s>%s</section>`,
+		c.styles.CodeBlockStyle, escapedCode)
+}
+
+// 代码块和公式的占位符为 前缀 + 序号 + "\x00"，与行内元素占位符一样不会与正文冲突
+const (
+	codeBlockPrefix  = "\x00c"
+	mathBlockPrefix  = "\x00m"
+	mathInlinePrefix = "\x00n"
+)
+
+// stashCodeBlock 保存已生成的代码块HTML，返回占位符
+func (c *WechatConverter) stashCodeBlock(codeHTML string) string {
+	placeholder := codeBlockPrefix + strconv.Itoa(len(c.codeBlocks)) + "\x00"
+	c.codeBlocks[placeholder] = codeHTML
+	return placeholder
 }
 
 // restoreCodeBlocks 恢复代码块
 func (c *WechatConverter) restoreCodeBlocks(text string) string {
-	for placeholder, codeHTML := range c.codeBlocks {
-		text = strings.ReplaceAll(text, placeholder, codeHTML)
-	}
-	return text
+	return restorePlaceholders(text, c.codeBlocks, codeBlockPrefix)
 }
 
-// processHeaders 处理标题
+// processHeaders 处理标题：以 "# "、"## "、"### " 开头且后面有内容的行
 func (c *WechatConverter) processHeaders(text string) string {
-	// H1
-	h1Regex := regexp.MustCompile(`(?m)^# (.+)$`)
-	text = h1Regex.ReplaceAllStringFunc(text, func(match string) string {
-		title := strings.TrimSpace(strings.TrimPrefix(match, "#"))
-		return fmt.Sprintf(`<h1 %s>%s</h1>`, c.styles.H1Style, html.EscapeString(title))
-	})
-	
-	// H2
-	h2Regex := regexp.MustCompile(`(?m)^## (.+)$`)
-	text = h2Regex.ReplaceAllStringFunc(text, func(match string) string {
-		title := strings.TrimSpace(strings.TrimPrefix(match, "##"))
-		return fmt.Sprintf(`<h2 %s>%s</h2>`, c.styles.H2Style, html.EscapeString(title))
-	})
-	
-	// H3
-	h3Regex := regexp.MustCompile(`(?m)^### (.+)$`)
-	text = h3Regex.ReplaceAllStringFunc(text, func(match string) string {
-		title := strings.TrimSpace(strings.TrimPrefix(match, "###"))
-		return fmt.Sprintf(`<h3 %s>%s</h3>`, c.styles.H3Style, html.EscapeString(title))
+	return replaceLines(text, func(line string) (string, bool) {
+		for level, style := range []string{c.styles.H1Style, c.styles.H2Style, c.styles.H3Style} {
+			marker := strings.Repeat("#", level+1)
+			if len(line) > len(marker)+1 && strings.HasPrefix(line, marker+" ") {
+				title := strings.TrimSpace(line[len(marker):])
+				return fmt.Sprintf(`<h%d %s>%s</h%d>`, level+1, style, title, level+1), true
+			}
+		}
+		return line, false
 	})
-	
-	return text
 }
 
 // processTables 处理表格
 func (c *WechatConverter) processTables(text string) string {
 	lines := strings.Split(text, "\n")
 	var result []string
-	var tableLines []string
-	inTable := false
 	
-	for i, line := range lines {
-		line = strings.TrimSpace(line)
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
 		
-		// 检测表格开始（包含 | 分隔符的行）
-		if strings.Contains(line, "|") && !inTable {
-			// 检查下一行是否是分隔符行
-			if i+1 < len(lines) {
-				nextLine := strings.TrimSpace(lines[i+1])
-				if isTableSeparator(nextLine) {
-					inTable = true
-					tableLines = []string{line} // 只保存表头，不保存分隔符行
-					i++ // 跳过分隔符行
-					continue
-				}
-			}
+		// 检测表格开始：包含 | 的行，且下一行是分隔符行
+		if !strings.Contains(line, "|") || i+1 >= len(lines) || !isTableSeparator(strings.TrimSpace(lines[i+1])) {
+			result = append(result, line)
+			continue
 		}
 		
-		// 在表格中
-		if inTable {
-			if strings.Contains(line, "|") && !isTableSeparator(line) {
-				tableLines = append(tableLines, line)
-			} else {
-				// 表格结束
-				result = append(result, c.convertTable(tableLines))
-				tableLines = []string{}
-				inTable = false
-				
-				// 添加当前行
-				if line != "" {
-					result = append(result, line)
-				}
+		// 单元格由 renderCellInline 自行转义，先还原为原文
+		header := splitTableRow(html.UnescapeString(line))
+		aligns := parseTableAligns(strings.TrimSpace(lines[i+1]))
+		var rows [][]string
+		
+		// 跳过分隔符行，收集数据行直到表格结束
+		for i += 2; i < len(lines); i++ {
+			row := strings.TrimSpace(lines[i])
+			if !strings.Contains(row, "|") || isTableSeparator(row) {
+				break
 			}
-		} else {
-			result = append(result, line)
+			rows = append(rows, splitTableRow(html.UnescapeString(row)))
 		}
-	}
-	
-	// 处理末尾的表格
-	if inTable && len(tableLines) > 0 {
-		result = append(result, c.convertTable(tableLines))
+		i--
+		
+		result = append(result, c.convertTable(header, aligns, rows))
 	}
 	
 	return strings.Join(result, "\n")
@@ -228,51 +304,51 @@ func isTableSeparator(line string) bool {
 	return strings.Contains(line, "-") && strings.Contains(line, "|")
 }
 
-// convertTable 转换表格
-func (c *WechatConverter) convertTable(tableLines []string) string {
-	if len(tableLines) < 1 {
-		return strings.Join(tableLines, "\n")
+// convertTable 按表格模式转换表格
+func (c *WechatConverter) convertTable(header []string, aligns []string, rows [][]string) string {
+	t := c.buildTable(header, aligns, rows)
+	switch c.tableMode() {
+	case TableASCII:
+		// 字符表格放在代码块中，避免被后续规则处理
+		return c.stashCodeBlock(c.renderCodeBlock(renderASCIITable(t), ""))
+	case TableCard:
+		return c.renderCardTable(t)
+	case TableScroll:
+		return fmt.Sprintf(`<section %s>%s</section>`, c.styles.TableScrollStyle,
+			c.renderHTMLTable(t, appendStyle(c.styles.TableStyle, "width: auto; min-width: 100%; white-space: nowrap;")))
+	default:
+		return c.renderHTMLTable(t, c.styles.TableStyle)
 	}
-	
+}
+
+// renderHTMLTable 生成带样式的 HTML 表格
+func (c *WechatConverter) renderHTMLTable(t *table, tableStyle string) string {
 	var tableHTML strings.Builder
-	tableHTML.WriteString(fmt.Sprintf(`<table %s>`, c.styles.TableStyle))
-	
-	// 处理表头
-	headerLine := strings.Trim(tableLines[0], "|")
-	headerCells := strings.Split(headerLine, "|")
-	
-	tableHTML.WriteString("<tr>")
-	for _, cell := range headerCells {
-		cell = strings.TrimSpace(cell)
-		if cell != "" {
-			tableHTML.WriteString(fmt.Sprintf(`<th %s>%s</th>`, c.styles.TableHeaderStyle, html.EscapeString(cell)))
-		}
-	}
-	tableHTML.WriteString("</tr>")
+	tableHTML.WriteString(fmt.Sprintf(`<table %s>`, tableStyle))
 	
-	// 处理数据行（从第1行开始，因为第0行是表头）
-	for i := 1; i < len(tableLines); i++ {
-		line := strings.Trim(tableLines[i], "|")
-		cells := strings.Split(line, "|")
-		
+	for i, row := range t.rows {
 		tableHTML.WriteString("<tr>")
-		for j, cell := range cells {
-			cell = strings.TrimSpace(cell)
-			
-			// 处理特殊样式的单元格
-			cellStyle := c.styles.TableCellStyle
-			
-			// 如果是代码类型的单元格，使用特殊样式
-			if strings.HasPrefix(cell, "-") || strings.HasPrefix(cell, "`") {
-				cellStyle = strings.Replace(cellStyle, "color: #333", "color: #d63384; font-family: 'SFMono-Regular', Consolas, monospace", 1)
+		for j, cell := range row {
+			if cell.merged {
+				continue
 			}
 			
-			// 如果是第一列且包含特殊标记，添加特殊样式
-			if j == 0 && (strings.HasPrefix(cell, "-") || strings.Contains(cell, "login") || strings.Contains(cell, "upload")) {
-				cellStyle = strings.Replace(cellStyle, "color: #333", "color: #d63384; font-weight: bold; font-family: 'SFMono-Regular', Consolas, monospace", 1)
+			tag, style := "td", c.cellStyle(c.styles.TableCellStyle, j, cell.text)
+			if i == 0 {
+				tag, style = "th", c.styles.TableHeaderStyle
+			}
+			if align := t.aligns[j]; align != "" {
+				style = appendStyle(style, "text-align: "+align+";")
 			}
 			
-			tableHTML.WriteString(fmt.Sprintf(`<td %s>%s</td>`, cellStyle, html.EscapeString(cell)))
+			span := ""
+			if cell.colspan > 1 {
+				span += fmt.Sprintf(` colspan="%d"`, cell.colspan)
+			}
+			if cell.rowspan > 1 {
+				span += fmt.Sprintf(` rowspan="%d"`, cell.rowspan)
+			}
+			tableHTML.WriteString(fmt.Sprintf(`<%s %s%s>%s</%s>`, tag, style, span, c.renderCellInline(cell.text), tag))
 		}
 		tableHTML.WriteString("</tr>")
 	}
@@ -281,99 +357,132 @@ func (c *WechatConverter) convertTable(tableLines []string) string {
 	return tableHTML.String()
 }
 
-// processQuotes 处理引用
+// processQuotes 处理引用：以 "> " 开头且后面有内容的行，此时 ">" 已转义为 "&gt;"
 func (c *WechatConverter) processQuotes(text string) string {
-	quoteRegex := regexp.MustCompile(`(?m)^> (.+)$`)
-	text = quoteRegex.ReplaceAllStringFunc(text, func(match string) string {
-		content := strings.TrimSpace(strings.TrimPrefix(match, ">"))
-		return fmt.Sprintf(`<blockquote %s>%s</blockquote>`, c.styles.QuoteStyle, html.EscapeString(content))
+	return replaceLines(text, func(line string) (string, bool) {
+		if len(line) <= len("&gt; ") || !strings.HasPrefix(line, "&gt; ") {
+			return line, false
+		}
+		content := strings.TrimSpace(line[len("&gt;"):])
+		return fmt.Sprintf(`<blockquote %s>%s</blockquote>`, c.styles.QuoteStyle, content), true
 	})
-	return text
 }
 
-// processLists 处理列表
+// processLists 处理列表：• 开头、"- "/"* "/"+ " 开头或 "数字." 开头且后面有内容的行
 func (c *WechatConverter) processLists(text string) string {
-	// 处理 • 符号开头的列表项
-	bulletRegex := regexp.MustCompile(`(?m)^•(.+)$`)
-	text = bulletRegex.ReplaceAllStringFunc(text, func(match string) string {
-		content := strings.TrimSpace(strings.TrimPrefix(match, "•"))
-		return fmt.Sprintf(`<ul %s><li style="margin: 0; line-height: 1.5em; font-size: 14px;">%s</li></ul>`, c.styles.ListStyle, html.EscapeString(content))
-	})
-	
-	// 无序列表（- * + 开头）
-	unorderedRegex := regexp.MustCompile(`(?m)^[-*+] (.+)$`)
-	text = unorderedRegex.ReplaceAllStringFunc(text, func(match string) string {
-		content := regexp.MustCompile(`^[-*+] `).ReplaceAllString(match, "")
-		return fmt.Sprintf(`<ul %s><li style="margin: 0; line-height: 1.5em; font-size: 14px;">%s</li></ul>`, c.styles.ListStyle, html.EscapeString(content))
-	})
-	
-	// 有序列表（数字. 开头）
-	orderedRegex := regexp.MustCompile(`(?m)^\d+\.(.+)$`)
-	text = orderedRegex.ReplaceAllStringFunc(text, func(match string) string {
-		content := regexp.MustCompile(`^\d+\.`).ReplaceAllString(match, "")
-		content = strings.TrimSpace(content)
-		return fmt.Sprintf(`<ol %s><li style="margin: 0; line-height: 1.5em; font-size: 14px;">%s</li></ol>`, c.styles.ListStyle, html.EscapeString(content))
+	const item = `<%s %s><li style="margin: 0; line-height: 1.5em; font-size: 14px;">%s</li></%s>`
+	return replaceLines(text, func(line string) (string, bool) {
+		switch {
+		case len(line) > len("•") && strings.HasPrefix(line, "•"):
+			content := strings.TrimSpace(strings.TrimPrefix(line, "•"))
+			return fmt.Sprintf(item, "ul", c.styles.ListStyle, content, "ul"), true
+		case len(line) > 2 && (line[0] == '-' || line[0] == '*' || line[0] == '+') && line[1] == ' ':
+			return fmt.Sprintf(item, "ul", c.styles.ListStyle, line[2:], "ul"), true
+		}
+		digits := len(line) - len(strings.TrimLeft(line, "0123456789"))
+		if digits > 0 && len(line) > digits+1 && line[digits] == '.' {
+			content := strings.TrimSpace(line[digits+1:])
+			return fmt.Sprintf(item, "ol", c.styles.ListStyle, content, "ol"), true
+		}
+		return line, false
 	})
-	
-	return text
 }
 
-// processLinks 处理链接，转换为脚注
+// processLinks 处理链接，转换为脚注；text 中的链接文字和地址已经转义，
+// 表格单元格中的链接占位符与正文链接一起按出现顺序编号
 func (c *WechatConverter) processLinks(text string) string {
-	linkRegex := regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
-	
-	return linkRegex.ReplaceAllStringFunc(text, func(match string) string {
-		matches := linkRegex.FindStringSubmatch(match)
-		linkText := matches[1]
-		linkURL := matches[2]
+	return replaceSubmatches(tableLinkRegex, text, func(matches []string) string {
+		linkText := matches[2]
+		linkURL := matches[3]
+		if matches[1] != "" {
+			n, _ := strconv.Atoi(matches[1])
+			m := linkRegex.FindStringSubmatch(c.tableLinks[n])
+			linkText, linkURL = m[1], m[2]
+		}
 		
-		// 添加到脚注
+		switch c.profile.LinkPolicy {
+		case LinkInline:
+			// 不安全的地址只保留链接文字
+			linkURL = c.attrText(linkURL)
+			if !isSafeURL(html.UnescapeString(linkURL)) {
+				return c.inlineToken(linkText)
+			}
+			return c.inlineToken(fmt.Sprintf(`<a %s href="%s">%s</a>`,
+				c.styles.LinkStyle, linkURL, linkText))
+		case LinkText:
+			return linkText
+		}
+		
+		// 添加到脚注，脚注在文末统一转义
 		footnoteIndex := len(c.footnotes) + 1
-		c.footnotes = append(c.footnotes, linkURL)
+		c.footnotes = append(c.footnotes, html.UnescapeString(c.attrText(linkURL)))
 		
 		// 返回带脚注标记的文本
-		return fmt.Sprintf(`<span %s>%s</span><sup>[%d]</sup>`, 
-			c.styles.LinkStyle, html.EscapeString(linkText), footnoteIndex)
+		return c.inlineToken(fmt.Sprintf(`<span %s>%s</span><sup>[%d]</sup>`, 
+			c.styles.LinkStyle, linkText, footnoteIndex))
 	})
 }
 
 // processImages 处理图片
 func (c *WechatConverter) processImages(text string) string {
-	imageRegex := regexp.MustCompile(`!\[([^\]]*)\]\(([^)]+)\)`)
-	
-	return imageRegex.ReplaceAllStringFunc(text, func(match string) string {
-		matches := imageRegex.FindStringSubmatch(match)
+	return replaceSubmatches(imageRegex, text, func(matches []string) string {
 		altText := matches[1]
-		imageURL := matches[2]
+		imageURL, _ := splitImageTitle(matches[2])
 		
-		return fmt.Sprintf(`<img %s src="%s" alt="%s" />`, 
-			c.styles.ImageStyle, imageURL, html.EscapeString(altText))
+		imageURL = c.attrText(imageURL)
+		if !isSafeURL(html.UnescapeString(imageURL)) {
+			return altText
+		}
+		return c.inlineToken(fmt.Sprintf(`<img %s src="%s" alt="%s" />`, 
+			c.styles.ImageStyle, imageURL, c.attrText(altText)))
 	})
 }
 
-// processInlineCode 处理行内代码
+// processInlineCode 处理行内代码；段落按行生成，行内代码不跨行
 func (c *WechatConverter) processInlineCode(text string) string {
-	inlineCodeRegex := regexp.MustCompile("`([^`]+)`")
-	
-	return inlineCodeRegex.ReplaceAllStringFunc(text, func(match string) string {
-		code := strings.Trim(match, "`")
-		return fmt.Sprintf(`<code %s>%s</code>`, c.styles.InlineCodeStyle, html.EscapeString(code))
+	return replaceLines(text, func(line string) (string, bool) {
+		if !strings.Contains(line, "`") {
+			return line, false
+		}
+		return replaceDelimited(line, "`", func(code string) string {
+			return c.inlineToken(fmt.Sprintf(`<code %s>%s</code>`, c.styles.InlineCodeStyle, code))
+		}), true
 	})
 }
 
-// processBoldItalic 处理粗体和斜体
+// processBoldItalic 处理粗体和斜体；强调不跨行，也不跨越已生成的标签
 func (c *WechatConverter) processBoldItalic(text string) string {
-	// 粗体
-	boldRegex := regexp.MustCompile(`\*\*([^*]+)\*\*`)
-	text = boldRegex.ReplaceAllString(text, `<strong>$1</strong>`)
-	
-	// 斜体
-	italicRegex := regexp.MustCompile(`\*([^*]+)\*`)
-	text = italicRegex.ReplaceAllString(text, `<em>$1</em>`)
+	if strings.Contains(text, "*") {
+		text = replaceLines(text, func(line string) (string, bool) {
+			if !strings.Contains(line, "*") {
+				return line, false
+			}
+			return c.processEmphasis(line), true
+		})
+	}
 	
 	return text
 }
 
+// processEmphasis 处理一行中的粗体和斜体
+func (c *WechatConverter) processEmphasis(line string) string {
+	// 粗体
+	line = replaceDelimited(line, "**", func(inner string) string {
+		if strings.ContainsAny(inner, "<>") {
+			return "**" + inner + "**"
+		}
+		return c.inlineToken("<strong>" + inner + "</strong>")
+	})
+
+	// 斜体
+	return replaceDelimited(line, "*", func(inner string) string {
+		if strings.ContainsAny(inner, "<>") {
+			return "*" + inner + "*"
+		}
+		return "<em>" + inner + "</em>"
+	})
+}
+
 // processParagraphs 处理段落
 func (c *WechatConverter) processParagraphs(text string) string {
 	lines := strings.Split(text, "\n")
@@ -388,15 +497,66 @@ func (c *WechatConverter) processParagraphs(text string) string {
 		// 跳过已经是HTML标签的行
 		if strings.HasPrefix(line, "<") {
 			result = append(result, line)
-		} else {
-			// 包装为段落
-			result = append(result, fmt.Sprintf(`<p %s>%s</p>`, c.styles.ParagraphStyle, line))
+			continue
+		}
+		// 行中间开始的代码块和块级公式单独成块，其余文字包装为段落
+		for _, part := range splitBlockPlaceholders(line) {
+			part = strings.TrimSpace(part)
+			switch {
+			case part == "":
+			case isBlockPlaceholder(part):
+				result = append(result, part)
+			case strings.Contains(part, codeBlockPrefix) || strings.Contains(part, mathBlockPrefix):
+				// 占位符在行内元素中，段落不能包含块级元素
+				result = append(result, fmt.Sprintf(`<section %s>%s</section>`, c.styles.ParagraphStyle, part))
+			default:
+				result = append(result, fmt.Sprintf(`<p %s>%s</p>`, c.styles.ParagraphStyle, part))
+			}
 		}
 	}
 	
 	return strings.Join(result, "\n")
 }
 
+// blockPrefixes 块级占位符的前缀
+var blockPrefixes = []string{codeBlockPrefix, mathBlockPrefix}
+
+// isBlockPlaceholder 判断 text 是否恰好是一个代码块或块级公式占位符
+func isBlockPlaceholder(text string) bool {
+	return placeholderAt(text, blockPrefixes) == text
+}
+
+// splitBlockPlaceholders 在不属于任何行内元素的块级占位符前后切分 line，占位符单独成为一项
+func splitBlockPlaceholders(line string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '<':
+			end := strings.IndexByte(line[i:], '>')
+			if end < 0 {
+				continue
+			}
+			switch {
+			case line[i+1] == '/':
+				depth--
+			case line[i+end-1] != '/':
+				depth++
+			}
+			i += end
+		case 0:
+			key := placeholderAt(line[i:], blockPrefixes)
+			if key == "" || depth != 0 {
+				continue
+			}
+			parts = append(parts, line[last:i], key)
+			last = i + len(key)
+			i = last - 1
+		}
+	}
+	return append(parts, line[last:])
+}
+
 // generateFootnotes 生成脚注
 func (c *WechatConverter) generateFootnotes() string {
 	if len(c.footnotes) == 0 {
@@ -423,7 +583,7 @@ func (c *WechatConverter) preprocessText(text string) string {
 	
 	for i, line := range lines {
 		// 处理带空行的编号列表格式，如 "1.\n\n内容"
-		if regexp.MustCompile(`^\d+\.$`).MatchString(strings.TrimSpace(line)) {
+		if orderedItemOnlyRegex.MatchString(strings.TrimSpace(line)) {
 			// 查找下一个非空行
 			for j := i + 1; j < len(lines); j++ {
 				nextLine := strings.TrimSpace(lines[j])
@@ -446,3 +606,286 @@ func (c *WechatConverter) preprocessText(text string) string {
 	
 	return strings.Join(result, "\n")
 }
+
+// textEscaper 转义正文中的 HTML 特殊字符；attrEscaper 转义属性值中的引号，正文中的引号保留原样
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	attrEscaper = strings.NewReplacer(`"`, "&#34;", "'", "&#39;")
+)
+
+// escapeText 转义正文；代码块、公式和脚注定义在此之前提取，由各自的渲染函数转义
+func escapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// attrText 把已转义的正文片段转为属性值：行内元素、公式和代码块只保留文字，并转义引号
+func (c *WechatConverter) attrText(text string) string {
+	if strings.Contains(text, "\x00") {
+		text = c.restoreCodeBlocks(c.restoreMath(c.restoreInlineTokens(text)))
+		text = inlineTagRegex.ReplaceAllString(text, "")
+	}
+	return attrEscaper.Replace(text)
+}
+
+// inlineTokenPrefix 行内元素占位符为 inlineTokenPrefix + 序号 + "\x00"，正文中的 NUL 在转换前已被替换
+const inlineTokenPrefix = "\x00i"
+
+// inlineToken 保存生成的行内 HTML 并返回占位符，避免粗体斜体等规则匹配到标签内部
+func (c *WechatConverter) inlineToken(html string) string {
+	c.inlineTokens = append(c.inlineTokens, html)
+	return inlineTokenPrefix + strconv.Itoa(len(c.inlineTokens)-1) + "\x00"
+}
+
+// restoreInlineTokens 恢复文本中的行内元素，元素中嵌套的占位符一并恢复
+func (c *WechatConverter) restoreInlineTokens(text string) string {
+	if !strings.Contains(text, inlineTokenPrefix) {
+		return text
+	}
+	var b strings.Builder
+	c.writeInlineTokens(&b, text)
+	return b.String()
+}
+
+func (c *WechatConverter) writeInlineTokens(b *strings.Builder, text string) {
+	for {
+		i := strings.Index(text, inlineTokenPrefix)
+		if i < 0 {
+			break
+		}
+		start := i + len(inlineTokenPrefix)
+		end := strings.IndexByte(text[start:], 0)
+		n, err := strconv.Atoi(text[start : start+max(end, 0)])
+		if end < 0 || err != nil || n < 0 || n >= len(c.inlineTokens) {
+			b.WriteString(text[:start])
+			text = text[start:]
+			continue
+		}
+		b.WriteString(text[:i])
+		c.writeInlineTokens(b, c.inlineTokens[n])
+		text = text[start+end+1:]
+	}
+	b.WriteString(text)
+}
+
+// isSafeURL 判断链接和图片地址能否输出到属性中：拒绝 javascript:、vbscript: 和图片以外的 data: 地址
+func isSafeURL(u string) bool {
+	// 浏览器解析协议时忽略空白和控制字符
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(u))
+	switch {
+	case strings.HasPrefix(u, "javascript:"), strings.HasPrefix(u, "vbscript:"):
+		return false
+	case strings.HasPrefix(u, "data:"):
+		return strings.HasPrefix(u, "data:image/")
+	}
+	return true
+}
+
+// replaceSubmatches 与 ReplaceAllStringFunc 相同，但直接把子匹配传给 repl，不对每个匹配再匹配一次
+func replaceSubmatches(re *regexp.Regexp, text string, repl func(matches []string) string) string {
+	locs := re.FindAllStringSubmatchIndex(text, -1)
+	if locs == nil {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	matches := make([]string, re.NumSubexp()+1)
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(text[last:loc[0]])
+		for i := range matches {
+			matches[i] = ""
+			if loc[2*i] >= 0 {
+				matches[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		b.WriteString(repl(matches))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// replaceCodeFences 把每个 ```info\ncode``` 替换为 repl 的返回值，匹配规则与正则
+// (?s)```(.*?)\n(.*?)``` 相同；长文章中逐字符回溯的正则占了转换的大部分时间
+func replaceCodeFences(text string, repl func(info, code string) string) string {
+	spans, _ := codeFences(text)
+	if len(spans) == 0 {
+		return text
+	}
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, span := range spans {
+		newline := span[0] + 3 + strings.IndexByte(text[span[0]+3:], '\n')
+		b.WriteString(text[last:span[0]])
+		b.WriteString(repl(text[span[0]+3:newline], text[newline+1:span[1]-3]))
+		last = span[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// codeFences 返回 replaceCodeFences 识别的代码块的字节范围 [开始 ```, 结束 ``` 之后)；
+// 结束标记可以在行中间。unclosed 为第一个没有配对的 ``` 的位置，全部配对时为 -1
+func codeFences(text string) (spans [][2]int, unclosed int) {
+	pos := 0
+	for {
+		open := strings.Index(text[pos:], "```")
+		if open < 0 {
+			return spans, -1
+		}
+		open += pos
+		newline := strings.IndexByte(text[open+3:], '\n')
+		if newline < 0 {
+			return spans, open
+		}
+		newline += open + 3
+		closing := strings.Index(text[newline+1:], "```")
+		if closing < 0 {
+			// 之后的起始位置也找不到结束标记
+			return spans, open
+		}
+		closing += newline + 1
+		spans = append(spans, [2]int{open, closing + 3})
+		pos = closing + 3
+	}
+}
+
+// outsideCodeFences 用 repl 的返回值替换 codeFences 识别的代码块之外的文字，
+// start 为片段在 text 中的字节偏移；代码块保持原样
+func outsideCodeFences(text string, repl func(part string, start int) string) string {
+	spans, _ := codeFences(text)
+	var b strings.Builder
+	b.Grow(len(text))
+	last := 0
+	for _, span := range spans {
+		b.WriteString(repl(text[last:span[0]], last))
+		b.WriteString(text[span[0]:span[1]])
+		last = span[1]
+	}
+	b.WriteString(repl(text[last:], last))
+	return b.String()
+}
+
+// replaceDelimited 把 mark 包围的非空文字（不含 mark 的首字符）替换为 repl 的返回值，
+// 与正则 \*\*([^*]+)\*\*、`([^`]+)` 等的替换结果相同
+func replaceDelimited(text, mark string, repl func(inner string) string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); {
+		open := strings.Index(text[i:], mark)
+		if open < 0 {
+			break
+		}
+		open += i
+		start := open + len(mark)
+		end := strings.IndexByte(text[start:], mark[0])
+		if end <= 0 || !strings.HasPrefix(text[start+end:], mark) {
+			i = open + 1
+			continue
+		}
+		end += start
+		if b.Cap() == 0 {
+			b.Grow(len(text) + 64)
+		}
+		b.WriteString(text[last:open])
+		b.WriteString(repl(text[start:end]))
+		last = end + len(mark)
+		i = last
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// replaceLines 对每一行调用 repl，repl 返回 false 时保留原行；代替 (?m)^...$ 形式的正则逐行替换
+func replaceLines(text string, repl func(line string) (string, bool)) string {
+	var b strings.Builder
+	changed := false
+	start := 0
+	for start <= len(text) {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		line := text[start:end]
+		if out, ok := repl(line); ok {
+			if !changed {
+				b.Grow(len(text))
+				b.WriteString(text[:start])
+				changed = true
+			}
+			b.WriteString(out)
+		} else if changed {
+			b.WriteString(line)
+		}
+		if end < len(text) && changed {
+			b.WriteByte('\n')
+		}
+		start = end + 1
+	}
+	if !changed {
+		return text
+	}
+	return b.String()
+}
+
+// restorePlaceholders 一次替换文本中形如 前缀+序号+"\x00" 的全部占位符，不在 blocks 中的保留原样
+func restorePlaceholders(text string, blocks map[string]string, prefixes ...string) string {
+	if len(blocks) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for i := 0; ; {
+		n := strings.IndexByte(text[i:], 0)
+		if n < 0 {
+			break
+		}
+		i += n
+		key := placeholderAt(text[i:], prefixes)
+		block, ok := blocks[key]
+		if key == "" || !ok {
+			i++
+			continue
+		}
+		if b.Cap() == 0 {
+			b.Grow(len(text))
+		}
+		b.WriteString(text[last:i])
+		b.WriteString(block)
+		last = i + len(key)
+		i = last
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// placeholderAt 返回 text 开头的占位符，不是占位符时返回空串
+func placeholderAt(text string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(text, prefix) {
+			continue
+		}
+		j := len(prefix)
+		for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+			j++
+		}
+		if j > len(prefix) && j < len(text) && text[j] == 0 {
+			return text[:j+1]
+		}
+	}
+	return ""
+}
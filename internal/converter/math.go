@@ -0,0 +1,127 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var blockMathRegex = regexp.MustCompile(`(?s)\$\$(.+?)\$\$`)
+
+// extractMath 提取数学公式并用占位符替换，避免被粗体、斜体等规则破坏
+func (c *WechatConverter) extractMath(text string) string {
+	counter := len(c.mathBlocks)
+
+	text = replaceSubmatches(blockMathRegex, text, func(matches []string) string {
+		// 公式中包含代码块时不是公式，代码块在公式之后恢复，会被放进公式的属性中
+		if strings.Contains(matches[1], codeBlockPrefix) {
+			return matches[0]
+		}
+		tex := strings.TrimSpace(matches[1])
+		placeholder := mathBlockPrefix + strconv.Itoa(counter) + "\x00"
+		c.mathBlocks[placeholder] = c.renderMath(tex, true)
+		counter++
+		// 块级公式独占一行，避免被包装成段落
+		return "\n" + placeholder + "\n"
+	})
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "$") {
+			continue
+		}
+		// 行内代码中的 $ 保持原样
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = replaceInlineMath(parts[j], func(tex string) string {
+				if strings.Contains(tex, codeBlockPrefix) {
+					return "$" + tex + "$"
+				}
+				placeholder := mathInlinePrefix + strconv.Itoa(counter) + "\x00"
+				c.mathBlocks[placeholder] = c.renderMath(tex, false)
+				counter++
+				return placeholder
+			})
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replaceInlineMath 替换行内 $...$ 公式：开头 $ 后和结尾 $ 前不能是空白，结尾 $ 后不能是数字（避免匹配金额）
+func replaceInlineMath(line string, replace func(tex string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '$' || (i > 0 && line[i-1] == '\\') || i+1 >= len(line) || line[i+1] == ' ' || line[i+1] == '$' {
+			b.WriteByte(line[i])
+			continue
+		}
+
+		end := -1
+		for j := i + 1; j < len(line); j++ {
+			if line[j] == '$' && line[j-1] != '\\' {
+				if line[j-1] != ' ' && (j+1 >= len(line) || line[j+1] < '0' || line[j+1] > '9') {
+					end = j
+				}
+				break
+			}
+		}
+		if end < 0 {
+			b.WriteByte(line[i])
+			continue
+		}
+
+		b.WriteString(replace(line[i+1 : end]))
+		i = end
+	}
+	return b.String()
+}
+
+// renderMath 按平台规则输出公式
+func (c *WechatConverter) renderMath(tex string, display bool) string {
+	switch c.profile.MathMode {
+	case MathImage:
+		style := `style="vertical-align: middle;"`
+		if display {
+			style = `style="display: block; margin: 1em auto;"`
+		}
+		return fmt.Sprintf(`<img %s src="%s" alt="%s" eeimg="1" />`,
+			style, html.EscapeString(c.mathImage(tex)), html.EscapeString(tex))
+	case MathTeX:
+		if display {
+			return fmt.Sprintf(`<p %s>$$%s$$</p>`, c.styles.ParagraphStyle, html.EscapeString(tex))
+		}
+		return "$" + html.EscapeString(tex) + "$"
+	default:
+		// MathJax 识别 \( \) 和 \[ \]，渲染后外层容器与前端 addContainer 的结构一致
+		if display {
+			return fmt.Sprintf(`<section class="block-equation">\[%s\]</section>`, html.EscapeString(tex))
+		}
+		return fmt.Sprintf(`<span class="inline-equation">\(%s\)</span>`, html.EscapeString(tex))
+	}
+}
+
+// mathImage 返回公式图片地址；设置了上传器时（如发布到公众号）下载后上传，平台不显示外部图片
+func (c *WechatConverter) mathImage(tex string) string {
+	src := c.profile.MathImageURL + strings.ReplaceAll(url.QueryEscape(tex), "+", "%20")
+	if c.uploader == nil {
+		return src
+	}
+	data, err := c.fetchImage(src)
+	if err != nil {
+		c.addWarning(0, "failed to fetch formula image %s: %v", src, err)
+		return src
+	}
+	if uploaded, ok := c.uploadImage("formula.png", data, 0); ok {
+		return uploaded
+	}
+	return src
+}
+
+// restoreMath 恢复数学公式
+func (c *WechatConverter) restoreMath(text string) string {
+	return restorePlaceholders(text, c.mathBlocks, mathBlockPrefix, mathInlinePrefix)
+}
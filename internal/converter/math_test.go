@@ -0,0 +1,60 @@
+package converter
+
+import (
+	"strings"
+	"testing"
+)
+
+// uploadFunc 用函数实现 ImageUploader
+type uploadFunc func(name string, data []byte) (string, error)
+
+func (f uploadFunc) Upload(name string, data []byte) (string, error) { return f(name, data) }
+
+// TestMathModes 各平台默认的公式输出：公众号输出公式图片，mathjax 输出 MathJax 标记，tex 保留源码
+func TestMathModes(t *testing.T) {
+	for _, tc := range []struct {
+		platform string
+		mode     MathMode
+		want     string
+	}{
+		{"wechat", "", `src="https://latex.codecogs.com/png.image?x%5E2"`},
+		{"wechat", MathJax, `<span class="inline-equation">\(x^2\)</span>`},
+		{"zhihu", "", `src="https://www.zhihu.com/equation?tex=x%5E2"`},
+		{"juejin", "", `$x^2$`},
+	} {
+		conv := NewWechatConverterFixed()
+		profile, _ := GetProfile(tc.platform)
+		if tc.mode != "" {
+			profile.MathMode = tc.mode
+		}
+		conv.SetProfile(profile)
+		if html := conv.ConvertMarkdownToWechat("公式 $x^2$ 结束\n"); !strings.Contains(html, tc.want) {
+			t.Errorf("%s %q: want %s in\n%s", tc.platform, tc.mode, tc.want, html)
+		}
+	}
+}
+
+// TestMathImageUpload 设置了上传器时公式图片下载后上传，下载失败时保留原地址并给出警告
+func TestMathImageUpload(t *testing.T) {
+	server, requests := newImageServer(t, 3)
+	var uploads []string
+	conv := NewWechatConverterFixed()
+	profile, _ := GetProfile("wechat")
+	profile.MathImageURL = server.URL + "/tex?"
+	conv.SetProfile(profile)
+	conv.SetImageUploader(uploadFunc(func(name string, data []byte) (string, error) {
+		uploads = append(uploads, name+" "+string(data))
+		return "http://mmbiz.qpic.cn/formula", nil
+	}))
+
+	html := conv.ConvertMarkdownToWechat("$$\nE=mc^2\n$$\n")
+	if !strings.Contains(html, `src="http://mmbiz.qpic.cn/formula"`) || *requests != 1 || len(uploads) != 1 || uploads[0] != "formula.png xxx" {
+		t.Errorf("uploads %v after %d requests:\n%s", uploads, *requests, html)
+	}
+
+	conv.SetImageFetcher(&ImageFetcher{AllowHosts: []string{"example.com"}})
+	html = conv.ConvertMarkdownToWechat("$x$\n")
+	if !strings.Contains(html, `src="`+server.URL+`/tex?x"`) || len(conv.Warnings()) != 1 {
+		t.Errorf("blocked formula image: warnings %v\n%s", conv.Warnings(), html)
+	}
+}
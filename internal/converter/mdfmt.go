@@ -0,0 +1,119 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrFormatChangesOutput 格式化后渲染的 HTML 与原文不同，此时不应使用格式化结果
+var ErrFormatChangesOutput = errors.New("formatting would change the rendered HTML")
+
+// FormatOptions Markdown 格式化约定，默认约定见 DefaultFormatOptions
+type FormatOptions struct {
+	// Bullet 无序列表符号：-（默认）、*、+
+	Bullet string `json:"bullet,omitempty"`
+	// Ordered 编号方式：sequential 按顺序编号（默认），one 全部写成 1.
+	Ordered string `json:"ordered,omitempty"`
+	// ListIndent 子列表缩进的空格数（1-8），默认 2
+	ListIndent int `json:"list_indent,omitempty"`
+	// CompactTables 不补齐表格各列的宽度
+	CompactTables bool `json:"compact_tables,omitempty"`
+}
+
+// DefaultFormatOptions 返回默认的格式化约定
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{Bullet: "-", Ordered: "sequential", ListIndent: 2}
+}
+
+// validate 检查选项，列表符号和编号方式为空时使用默认值
+func (o *FormatOptions) validate() error {
+	switch o.Bullet {
+	case "":
+		o.Bullet = "-"
+	case "-", "*", "+":
+	default:
+		return fmt.Errorf("invalid bullet %q: want -, * or +", o.Bullet)
+	}
+	switch o.Ordered {
+	case "":
+		o.Ordered = "sequential"
+	case "sequential", "one":
+	default:
+		return fmt.Errorf("invalid ordered style %q: want sequential or one", o.Ordered)
+	}
+	if o.ListIndent < 1 || o.ListIndent > 8 {
+		return fmt.Errorf("invalid list indent %d: want 1-8", o.ListIndent)
+	}
+	return nil
+}
+
+// FormatMarkdown 按约定输出规范的 Markdown：统一列表符号和编号、合并 "1.\n\n内容" 形式的编号、
+// 去掉行尾空白和多余空行、对齐表格。front matter 原样保留。
+// 格式化结果与原文在任一平台下渲染出的 HTML 不同时返回原文和 ErrFormatChangesOutput
+func FormatMarkdown(markdown string, opts FormatOptions) (string, error) {
+	if err := opts.validate(); err != nil {
+		return markdown, err
+	}
+
+	doc := BuildAST(markdown)
+	normalizeLists(doc.Children, opts)
+
+	var b strings.Builder
+	if _, end := parseFrontMatter(markdown); end >= 0 {
+		lines := strings.Split(strings.TrimPrefix(markdown, "\ufeff"), "\n")
+		for _, line := range lines[:end+1] {
+			b.WriteString(strings.TrimRight(line, " \t\r") + "\n")
+		}
+		if len(doc.Children) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	w := &markdownWriter{listIndent: opts.ListIndent, alignTables: !opts.CompactTables}
+	if body := w.blocks(doc.Children, 1); len(body) > 0 {
+		b.WriteString(strings.Join(body, "\n") + "\n")
+	}
+	formatted := b.String()
+
+	if !sameRendering(markdown, formatted) {
+		return markdown, ErrFormatChangesOutput
+	}
+	return formatted, nil
+}
+
+// sameRendering 判断两段 Markdown 在每个平台下渲染出的 HTML 是否相同
+func sameRendering(a, b string) bool {
+	render := func(markdown string, profile Profile) string {
+		conv := NewWechatConverterFixed()
+		conv.SetProfile(profile)
+		return conv.ConvertMarkdownToWechat(markdown)
+	}
+	for _, name := range Profiles() {
+		profile, _ := GetProfile(name)
+		if render(a, profile) != render(b, profile) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeLists 统一列表项的符号和编号；引用中的列表按原文渲染，保持不变
+func normalizeLists(nodes []*ASTNode, opts FormatOptions) {
+	for _, node := range nodes {
+		if node.Type != NodeList {
+			continue
+		}
+		for i, item := range node.Children {
+			switch {
+			case !strings.HasSuffix(item.Attrs["marker"], "."):
+				item.Attrs["marker"] = opts.Bullet
+			case opts.Ordered == "one":
+				item.Attrs["marker"] = "1."
+			default:
+				item.Attrs["marker"] = strconv.Itoa(i+1) + "."
+			}
+			normalizeLists(item.Children, opts)
+		}
+	}
+}
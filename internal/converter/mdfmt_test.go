@@ -0,0 +1,72 @@
+package converter
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestFormatMarkdown 每条格式化规则的结果，格式化后再次格式化不再变化
+func TestFormatMarkdown(t *testing.T) {
+	defaults := DefaultFormatOptions()
+	compact := FormatOptions{Bullet: "*", Ordered: "one", ListIndent: 4, CompactTables: true}
+	for _, tc := range []struct {
+		name     string
+		markdown string
+		opts     FormatOptions
+		want     string
+	}{
+		{"bullets", "* a\n+ b\n- c\n", defaults, "- a\n- b\n- c\n"},
+		{"default bullet and numbering", "* a\n\n3. b\n", FormatOptions{ListIndent: 2}, "- a\n\n1. b\n"},
+		{"bullet option", "- a\n+ b\n", compact, "* a\n* b\n"},
+		{"sequential numbers", "3. a\n7. b\n", defaults, "1. a\n2. b\n"},
+		{"numbers all one", "3. a\n7. b\n", compact, "1. a\n1. b\n"},
+		{"number on its own line", "1.\n\n内容\n2.\n\n其他\n", defaults, "1. 内容\n2. 其他\n"},
+		{"nested list indent", "- a\n    - b\n", defaults, "- a\n  - b\n"},
+		{"nested list indent option", "- a\n  - b\n", compact, "* a\n    * b\n"},
+		{"trailing whitespace", "a   \nb\t\r\n", defaults, "a\nb\n"},
+		{"blank lines", "a\n\n\n\nb\n", defaults, "a\n\nb\n"},
+		{"blank line before list", "a\n- b\n", defaults, "a\n\n- b\n"},
+		{"aligned table", "| a | bbb |\n|---|:-:|\n| cc | d |\n", defaults, "| a   | bbb |\n| --- | :-: |\n| cc  |  d  |\n"},
+		{"compact table", "| a | bbb |\n|---|:-:|\n| cc | d |\n", compact, "| a | bbb |\n| --- | :-: |\n| cc | d |\n"},
+		{"escaped pipe in table", "| a | b |\n|---|---|\n| x \\| y | z |\n", defaults, "| a      | b   |\n| ------ | --- |\n| x \\| y | z   |\n"},
+		{"front matter", "---\ntitle: x  \n---\n# T\n", defaults, "---\ntitle: x\n---\n\n# T\n"},
+		{"code block kept", "```\na  \n\n\n\nb\n```\n", defaults, "```\na  \n\n\n\nb\n```\n"},
+		{"list in quote kept", "> * a\n> * b\n", defaults, "> * a\n> * b\n"},
+	} {
+		got, err := FormatMarkdown(tc.markdown, tc.opts)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: FormatMarkdown(%q) = %q, %v; want %q", tc.name, tc.markdown, got, err, tc.want)
+			continue
+		}
+		if again, err := FormatMarkdown(got, tc.opts); err != nil || again != got {
+			t.Errorf("%s: formatting again = %q, %v; want unchanged", tc.name, again, err)
+		}
+	}
+}
+
+// TestFormatMarkdownRefuses 格式化会改变渲染结果时返回原文和 ErrFormatChangesOutput，选项无效时返回错误
+func TestFormatMarkdownRefuses(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		markdown string
+	}{
+		// 去掉行尾空白会改变公式内容
+		{"trailing whitespace in block math", "$$\na  \nb\n$$\n"},
+		// 在行中结束的代码块无法按原样重新输出
+		{"fence closed mid-line", "```go\nx\n\ntail ``` here\n"},
+		{"empty quote lines", "> a\n>\n>\n> b\n"},
+	} {
+		got, err := FormatMarkdown(tc.markdown, DefaultFormatOptions())
+		if !errors.Is(err, ErrFormatChangesOutput) || got != tc.markdown {
+			t.Errorf("%s: FormatMarkdown = %q, %v; want original text and ErrFormatChangesOutput", tc.name, got, err)
+		}
+	}
+
+	for _, opts := range []FormatOptions{
+		{Bullet: "x", ListIndent: 2}, {Ordered: "roman", ListIndent: 2}, {ListIndent: 9}, {ListIndent: -1}, {ListIndent: 0}, {},
+	} {
+		if got, err := FormatMarkdown("* a\n", opts); err == nil || got != "* a\n" {
+			t.Errorf("options %+v: FormatMarkdown = %q, %v; want original text and an error", opts, got, err)
+		}
+	}
+}
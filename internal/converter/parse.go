@@ -0,0 +1,195 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// BlockType 块类型
+type BlockType string
+
+const (
+	BlockHeading   BlockType = "heading"
+	BlockParagraph BlockType = "paragraph"
+	BlockCode      BlockType = "code"
+	BlockQuote     BlockType = "quote"
+	BlockList      BlockType = "list"
+	BlockTable     BlockType = "table"
+)
+
+// Block 文档中的一个块级元素
+type Block struct {
+	Type BlockType
+	// Level 标题级别
+	Level int
+	// Lang 代码块语言
+	Lang string
+	// Ordered 是否为有序列表
+	Ordered bool
+	// Lines 块的源文本，代码块不含围栏，标题和引用不含前缀
+	Lines []string
+	// StartLine、EndLine 块在原文中的起止行（从 1 开始，包含围栏）
+	StartLine int
+	EndLine   int
+}
+
+// Text 返回块的源文本
+func (b *Block) Text() string {
+	return strings.Join(b.Lines, "\n")
+}
+
+// Document 解析后的文档
+type Document struct {
+	FrontMatter FrontMatter
+	Blocks      []*Block
+}
+
+var (
+	headingLineRegex     = regexp.MustCompile(`^(#{1,6}) (.+)$`)
+	unorderedItemRegex   = regexp.MustCompile(`^(?:[-*+] |•)`)
+	orderedItemRegex     = regexp.MustCompile(`^\d+\.`)
+	orderedItemOnlyRegex = regexp.MustCompile(`^\d+\.$`)
+)
+
+// Parse 将 Markdown 解析为块级结构
+func Parse(markdown string) *Document {
+	fm, _ := parseFrontMatter(markdown)
+	text := blankFrontMatter(markdown)
+	lines := strings.Split(text, "\n")
+	fences := fenceLines(text)
+	doc := &Document{FrontMatter: fm}
+
+	for i := 0; i < len(lines); {
+		line := strings.TrimRight(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+		end, fence := fences[i]
+
+		switch {
+		case trimmed == "":
+			i++
+
+		// 代码块的配对与转换器相同：没有配对的 ``` 按普通文字处理，结束标记可以在行中间
+		case fence:
+			block := &Block{Type: BlockCode, Lang: strings.TrimSpace(strings.TrimPrefix(trimmed, "```")), StartLine: i + 1, EndLine: end + 1}
+			for _, l := range lines[i+1 : end] {
+				block.Lines = append(block.Lines, strings.TrimRight(l, "\r"))
+			}
+			if code, _, _ := strings.Cut(lines[end], "```"); strings.TrimSpace(code) != "" {
+				block.Lines = append(block.Lines, code)
+			}
+			doc.Blocks = append(doc.Blocks, block)
+			i = end + 1
+
+		case headingLineRegex.MatchString(trimmed):
+			m := headingLineRegex.FindStringSubmatch(trimmed)
+			doc.Blocks = append(doc.Blocks, &Block{
+				Type:      BlockHeading,
+				Level:     len(m[1]),
+				Lines:     []string{strings.TrimSpace(m[2])},
+				StartLine: i + 1,
+				EndLine:   i + 1,
+			})
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			block := &Block{Type: BlockQuote, StartLine: i + 1}
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					break
+				}
+				block.Lines = append(block.Lines, strings.TrimSpace(strings.TrimPrefix(t, ">")))
+			}
+			block.EndLine = i
+			doc.Blocks = append(doc.Blocks, block)
+
+		case strings.Contains(trimmed, "|") && i+1 < len(lines) && isTableSeparator(strings.TrimSpace(lines[i+1])):
+			block := &Block{Type: BlockTable, Lines: []string{trimmed, strings.TrimSpace(lines[i+1])}, StartLine: i + 1}
+			for i += 2; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.Contains(t, "|") {
+					break
+				}
+				block.Lines = append(block.Lines, t)
+			}
+			block.EndLine = i
+			doc.Blocks = append(doc.Blocks, block)
+
+		case isListItem(trimmed):
+			block := &Block{Type: BlockList, Ordered: orderedItemRegex.MatchString(trimmed), StartLine: i + 1}
+			for i < len(lines) {
+				t := strings.TrimSpace(lines[i])
+				if !isListItem(t) {
+					break
+				}
+				// 兼容 "1.\n\n内容" 形式的编号列表
+				if orderedItemOnlyRegex.MatchString(t) {
+					j := i + 1
+					for j < len(lines) && strings.TrimSpace(lines[j]) == "" {
+						j++
+					}
+					if j < len(lines) {
+						t = t + " " + strings.TrimSpace(lines[j])
+						i = j
+					}
+				}
+				block.Lines = append(block.Lines, t)
+				i++
+			}
+			block.EndLine = i
+			doc.Blocks = append(doc.Blocks, block)
+
+		default:
+			block := &Block{Type: BlockParagraph, StartLine: i + 1}
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if t == "" || (len(block.Lines) > 0 && startsBlock(lines, i)) {
+					break
+				}
+				block.Lines = append(block.Lines, t)
+			}
+			block.EndLine = i
+			doc.Blocks = append(doc.Blocks, block)
+		}
+	}
+
+	return doc
+}
+
+// isListItem 判断是否为列表项
+func isListItem(line string) bool {
+	return unorderedItemRegex.MatchString(line) || orderedItemRegex.MatchString(line)
+}
+
+// startsBlock 判断第 i 行是否开始一个新的非段落块
+func startsBlock(lines []string, i int) bool {
+	t := strings.TrimSpace(lines[i])
+	if strings.HasPrefix(t, "```") || strings.HasPrefix(t, ">") || headingLineRegex.MatchString(t) || isListItem(t) {
+		return true
+	}
+	return strings.Contains(t, "|") && i+1 < len(lines) && isTableSeparator(strings.TrimSpace(lines[i+1]))
+}
+
+// fenceLines 返回从行首开始的代码块的起始行到结束行（下标从 0 开始），配对与 replaceCodeFences 相同
+func fenceLines(text string) map[int]int {
+	spans, _ := codeFences(text)
+	if len(spans) == 0 {
+		return nil
+	}
+	fences := make(map[int]int, len(spans))
+	line, offset := 0, 0
+	lineOf := func(pos int) int {
+		line += strings.Count(text[offset:pos], "\n")
+		offset = pos
+		return line
+	}
+	for _, span := range spans {
+		start := lineOf(span[0])
+		lineStart := strings.LastIndexByte(text[:span[0]], '\n') + 1
+		end := lineOf(span[1])
+		if strings.TrimSpace(text[lineStart:span[0]]) == "" {
+			fences[start] = end
+		}
+	}
+	return fences
+}
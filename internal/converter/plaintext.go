@@ -0,0 +1,408 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ThreadOptions 分段发布选项
+type ThreadOptions struct {
+	// Limit 每段的最大字符数
+	Limit int `json:"limit"`
+	// Numbered 在每段末尾加上 (1/3) 形式的序号，序号计入字数
+	Numbered bool `json:"numbered,omitempty"`
+}
+
+var (
+	autoLinkRegex     = regexp.MustCompile(`<(https?://[^>\s]+)>`)
+	footnoteDefPrefix = regexp.MustCompile(`^\[\^([^\]]+)\]:\s*`)
+	thematicRegex     = regexp.MustCompile(`^(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	taskMarkerRegex   = regexp.MustCompile(`^[-*+] \[([ xX])\] `)
+	bulletMarkerRegex = regexp.MustCompile(`^(?:[-*+] |•\s*)`)
+	orderedMarker     = regexp.MustCompile(`^(\d+)\.\s*`)
+	escapedCharRegex  = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!|~<>$])`)
+)
+
+// RenderText 将 Markdown 渲染为适合微博、朋友圈等纯文本平台的文字：
+// 列表使用项目符号，表格按列对齐，链接写成 "文字 (地址)"，代码缩进四格
+func RenderText(markdown string) string {
+	doc := Parse(markdown)
+	markdown = expandStatsPlaceholders(markdown, ComputeStats(doc))
+	doc = Parse(markdown)
+	lines := strings.Split(blankFrontMatter(markdown), "\n")
+
+	var parts []string
+	if title := doc.FrontMatter.Get("title"); title != "" {
+		if len(doc.Blocks) == 0 || doc.Blocks[0].Type != BlockHeading || plainInline(doc.Blocks[0].Text()) != title {
+			parts = append(parts, title)
+		}
+	}
+
+	for _, block := range doc.Blocks {
+		switch block.Type {
+		case BlockHeading:
+			parts = append(parts, plainInline(block.Text()))
+		case BlockCode:
+			var b strings.Builder
+			for i, line := range block.Lines {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				if line != "" {
+					b.WriteString("    " + line)
+				}
+			}
+			parts = append(parts, b.String())
+		case BlockQuote:
+			quoted := make([]string, len(block.Lines))
+			for i, line := range block.Lines {
+				quoted[i] = strings.TrimRight("> "+plainInline(line), " ")
+			}
+			parts = append(parts, strings.Join(quoted, "\n"))
+		case BlockList:
+			parts = append(parts, plainList(lines[block.StartLine-1:block.EndLine]))
+		case BlockTable:
+			parts = append(parts, plainTable(block.Lines))
+		default:
+			parts = append(parts, plainParagraph(block.Lines))
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
+
+// plainParagraph 合并段落中的行，中文之间不加空格
+func plainParagraph(lines []string) string {
+	if len(lines) == 1 && thematicRegex.MatchString(lines[0]) {
+		return "————"
+	}
+	if m := footnoteDefPrefix.FindStringSubmatch(lines[0]); m != nil {
+		lines[0] = "[" + m[1] + "] " + lines[0][len(m[0]):]
+	}
+	var text string
+	for _, line := range lines {
+		text = joinText(text, plainInline(line))
+	}
+	return text
+}
+
+// plainList 渲染列表，按原文缩进保留层级
+func plainList(src []string) string {
+	var items []string
+	for i := 0; i < len(src); i++ {
+		raw := strings.TrimRight(src[i], "\r")
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			continue
+		}
+		// 兼容 "1.\n\n内容" 形式的编号列表
+		if orderedItemOnlyRegex.MatchString(trimmed) {
+			for j := i + 1; j < len(src); j++ {
+				if t := strings.TrimSpace(src[j]); t != "" {
+					trimmed += " " + t
+					i = j
+					break
+				}
+			}
+		}
+
+		indent := strings.Repeat("  ", leadingWidth(raw)/2)
+		switch {
+		case taskMarkerRegex.MatchString(trimmed):
+			m := taskMarkerRegex.FindStringSubmatch(trimmed)
+			box := "☐ "
+			if m[1] != " " {
+				box = "☑ "
+			}
+			items = append(items, indent+box+plainInline(trimmed[len(m[0]):]))
+		case orderedMarker.MatchString(trimmed):
+			m := orderedMarker.FindStringSubmatch(trimmed)
+			items = append(items, indent+m[1]+". "+plainInline(trimmed[len(m[0]):]))
+		default:
+			items = append(items, indent+"• "+plainInline(bulletMarkerRegex.ReplaceAllString(trimmed, "")))
+		}
+	}
+	return strings.Join(items, "\n")
+}
+
+// leadingWidth 计算行首空白的宽度，制表符计 4
+func leadingWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// plainTable 按显示宽度对齐表格各列，表头下加分隔线
+func plainTable(lines []string) string {
+	header := splitTableRow(lines[0])
+	aligns := parseTableAligns(lines[1])
+	columns := len(header)
+
+	rows := [][]string{header}
+	for _, line := range lines[2:] {
+		rows = append(rows, splitTableRow(line))
+	}
+	texts := make([][]string, len(rows))
+	widths := make([]int, columns)
+	for i, row := range rows {
+		texts[i] = make([]string, columns)
+		for j := 0; j < columns && j < len(row); j++ {
+			texts[i][j] = plainInline(row[j])
+			if w := displayWidth(texts[i][j]); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+
+	line := func(cells []string) string {
+		parts := make([]string, columns)
+		for j, cell := range cells {
+			align := ""
+			if j < len(aligns) {
+				align = aligns[j]
+			}
+			parts[j] = padCell(cell, widths[j], align)
+		}
+		return strings.TrimRight(strings.Join(parts, "  "), " ")
+	}
+
+	out := []string{line(texts[0])}
+	rule := make([]string, columns)
+	for j, w := range widths {
+		rule[j] = strings.Repeat("-", max(w, 1))
+	}
+	out = append(out, strings.Join(rule, "  "))
+	for _, row := range texts[1:] {
+		out = append(out, line(row))
+	}
+	return strings.Join(out, "\n")
+}
+
+// plainInline 去掉行内标记：链接写成 "文字 (地址)"，图片保留替代文字，公式保留 TeX
+func plainInline(text string) string {
+	// 行内代码和转义字符先替换为占位符，避免其中的内容被当作标记处理
+	var codes []string
+	text = inlineCodeRegex.ReplaceAllStringFunc(text, func(match string) string {
+		codes = append(codes, inlineCodeRegex.FindStringSubmatch(match)[1])
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+	text = escapedCharRegex.ReplaceAllStringFunc(text, func(match string) string {
+		codes = append(codes, match[1:])
+		return fmt.Sprintf("\x00%d\x00", len(codes)-1)
+	})
+
+	text = imageRefRegex.ReplaceAllStringFunc(text, func(match string) string {
+		if alt := strings.TrimSpace(imageRefRegex.FindStringSubmatch(match)[1]); alt != "" {
+			return "[" + alt + "]"
+		}
+		return ""
+	})
+	text = inlineLinkRegex.ReplaceAllStringFunc(text, func(match string) string {
+		m := inlineLinkRegex.FindStringSubmatch(match)
+		label, href := m[1], strings.Fields(m[2])
+		if len(href) == 0 || href[0] == label {
+			return label
+		}
+		return label + " (" + href[0] + ")"
+	})
+	text = autoLinkRegex.ReplaceAllString(text, "$1")
+	text = footnoteRefRegex.ReplaceAllString(text, "[$1]")
+	text = inlineTagRegex.ReplaceAllString(text, "")
+	for _, re := range emphasisRegexes {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	text = replaceInlineMath(text, func(tex string) string { return tex })
+	text = html.UnescapeString(text)
+
+	for i, code := range codes {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), code, 1)
+	}
+	return strings.TrimSpace(text)
+}
+
+// joinText 拼接两段文字，两侧都是中日韩字符时不加空格
+func joinText(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	last, _ := utf8.DecodeLastRuneInString(a)
+	first, _ := utf8.DecodeRuneInString(b)
+	if isCJK(last) || isCJK(first) || isCJKPunct(last) || isCJKPunct(first) {
+		return a + b
+	}
+	return a + " " + b
+}
+
+// isCJKPunct 判断是否为全角标点
+func isCJKPunct(r rune) bool {
+	return (r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// threadSegment 分段时不再拆分的最小单位，sep 为与前一个单位之间的分隔
+type threadSegment struct {
+	text string
+	sep  string
+}
+
+// SplitThread 将纯文本拆分为不超过 Limit 个字符的多段，优先在段落和句子之间断开；
+// 超长的句子在逗号或空格处断开，仍然过长时按字数截断
+func SplitThread(text string, opts ThreadOptions) []string {
+	text = strings.TrimSpace(text)
+	if opts.Limit <= 0 || (utf8.RuneCountInString(text) <= opts.Limit) {
+		return []string{text}
+	}
+
+	segments := threadSegments(text)
+	if !opts.Numbered {
+		return packThread(segments, opts.Limit)
+	}
+
+	// 序号的长度取决于总段数，段数增加导致序号变长时重新拆分
+	reserve := len(" (1/1)")
+	for {
+		chunks := packThread(segments, max(opts.Limit-reserve, 1))
+		suffix := fmt.Sprintf(" (%d/%d)", len(chunks), len(chunks))
+		if len(suffix) <= reserve {
+			for i := range chunks {
+				chunks[i] += fmt.Sprintf(" (%d/%d)", i+1, len(chunks))
+			}
+			return chunks
+		}
+		reserve = len(suffix)
+	}
+}
+
+// threadSegments 将文本拆分为句子，记录句子之间原有的段落和换行
+func threadSegments(text string) []threadSegment {
+	var segments []threadSegment
+	for p, para := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(para, "\n"), "\n")
+		for l, line := range lines {
+			// 列表、表格、代码等多行段落和缩进的行按行拆分，保留缩进
+			sentences := []string{strings.TrimRight(line, " ")}
+			if len(lines) == 1 && !strings.HasPrefix(line, " ") {
+				sentences = splitSentences(line)
+			}
+			for s, sentence := range sentences {
+				seg := threadSegment{text: sentence}
+				switch {
+				case s > 0:
+				case l > 0:
+					seg.sep = "\n"
+				case p > 0:
+					seg.sep = "\n\n"
+				}
+				segments = append(segments, seg)
+			}
+		}
+	}
+	return segments
+}
+
+// splitSentences 在句末标点后断句，句号后的引号、括号归入前一句；
+// 英文标点后需要跟空白才断句，数字后的句点不断句，避免拆开小数、网址和编号
+func splitSentences(line string) []string {
+	var sentences []string
+	runes := []rune(line)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		end := false
+		switch r {
+		case '。', '！', '？', '；', '…':
+			end = true
+		case '.':
+			end = (i+1 == len(runes) || runes[i+1] == ' ') && (i == 0 || runes[i-1] < '0' || runes[i-1] > '9')
+		case '!', '?', ';':
+			end = i+1 == len(runes) || runes[i+1] == ' '
+		}
+		if !end {
+			continue
+		}
+		for i+1 < len(runes) && strings.ContainsRune("。！？…”’」』）)\"'", runes[i+1]) {
+			i++
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+
+	// 句子之间的空格留给 joinSegment 处理
+	out := sentences[:0]
+	for _, s := range sentences {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		// 空行或只有空白的行保留为空句子，维持原有换行
+		return []string{strings.TrimSpace(line)}
+	}
+	return out
+}
+
+// packThread 按顺序把句子装入不超过 limit 个字符的段落
+func packThread(segments []threadSegment, limit int) []string {
+	var chunks []string
+	var cur string
+	for _, seg := range segments {
+		joined := joinSegment(cur, seg)
+		if utf8.RuneCountInString(joined) <= limit {
+			cur = joined
+			continue
+		}
+		if cur != "" {
+			chunks = append(chunks, cur)
+			cur = ""
+		}
+		pieces := breakLong(seg.text, limit)
+		chunks = append(chunks, pieces[:len(pieces)-1]...)
+		cur = pieces[len(pieces)-1]
+	}
+	if cur != "" {
+		chunks = append(chunks, cur)
+	}
+	return chunks
+}
+
+// joinSegment 将句子接到当前段落后面
+func joinSegment(cur string, seg threadSegment) string {
+	if cur == "" {
+		return seg.text
+	}
+	if seg.sep != "" {
+		return cur + seg.sep + seg.text
+	}
+	return joinText(cur, seg.text)
+}
+
+// breakLong 拆分超过 limit 的句子，优先在逗号、顿号或空格之后断开
+func breakLong(text string, limit int) []string {
+	var pieces []string
+	runes := []rune(text)
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if strings.ContainsRune("，、,：: ", runes[i-1]) {
+				cut = i
+				break
+			}
+		}
+		pieces = append(pieces, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return append(pieces, string(runes))
+}
@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestRenderText 列表使用项目符号，表格按显示宽度对齐，链接写成 "文字 (地址)"，代码缩进四格
+func TestRenderText(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{
+			"title and inline marks",
+			"---\ntitle: 标题\n---\n\n正文 **粗体** `a*b*` ![图](x.png)[^1]\n第二行\n\n[^1]: 注释",
+			"标题\n\n正文 粗体 a*b* [图][1]第二行\n\n[1] 注释\n",
+		},
+		{
+			"title same as heading",
+			"---\ntitle: 标题\n---\n\n# **标题**\n\n正文",
+			"标题\n\n正文\n",
+		},
+		{
+			"links",
+			`[链接](https://example.com "标题") <https://a.example> [https://b.example](https://b.example)`,
+			"链接 (https://example.com) https://a.example https://b.example\n",
+		},
+		{
+			"escapes and math",
+			`$E=mc^2$ 和 \*不是强调\* \[不是链接\](x)`,
+			"E=mc^2 和 *不是强调* [不是链接](x)\n",
+		},
+		{
+			"bullets",
+			"- 甲\n  - 嵌套\n* [x] 完成\n+ [ ] 未完\n\n1. 一\n2. **二**",
+			"• 甲\n  • 嵌套\n☑ 完成\n☐ 未完\n\n1. 一\n2. 二\n",
+		},
+		{
+			"table alignment",
+			"| 名称 | 数量 | 说明 |\n| :--- | ---: | :---: |\n| 苹果 | 3 | 红 |\n| **banana** | 12 | yellow fruit |",
+			"名称    数量      说明\n------  ----  ------------\n苹果       3       红\nbanana    12  yellow fruit\n",
+		},
+		{
+			"code indentation",
+			"```go\nfunc main() {\n\n\tx := 1\n}\n```\n\n> 引用 *强调*\n\n---",
+			"    func main() {\n\n    \tx := 1\n    }\n\n> 引用 强调\n\n————\n",
+		},
+	} {
+		if got := RenderText(tc.markdown); got != tc.want {
+			t.Errorf("%s: RenderText = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestSplitThread 在段落和句子之间断开，每段不超过限制；超长的句子在逗号或空格处断开
+func TestSplitThread(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		text string
+		opts ThreadOptions
+		want []string
+	}{
+		{"within limit", "短文。", ThreadOptions{Limit: 10}, []string{"短文。"}},
+		{"no limit", "第一句。第二句。", ThreadOptions{}, []string{"第一句。第二句。"}},
+		{"sentences", "第一句。第二句！第三句？第四句。", ThreadOptions{Limit: 8}, []string{"第一句。第二句！", "第三句？第四句。"}},
+		{"closing quote stays", "他说：“好。”然后走了。", ThreadOptions{Limit: 8}, []string{"他说：“好。”", "然后走了。"}},
+		{"english", "One. Two sentences here. Pi is 3.14 ok.", ThreadOptions{Limit: 20}, []string{"One.", "Two sentences here.", "Pi is 3.14 ok."}},
+		{"paragraphs", "甲。\n\n乙。\n\n丙。", ThreadOptions{Limit: 6}, []string{"甲。\n\n乙。", "丙。"}},
+		{"list lines", "• 第一项内容\n• 第二项内容", ThreadOptions{Limit: 8}, []string{"• 第一项内容", "• 第二项内容"}},
+		{"long sentence at comma", "这是一个很长的句子，中间有逗号，没有句号", ThreadOptions{Limit: 10}, []string{"这是一个很长的句子，", "中间有逗号，没有句号"}},
+		{"long sentence between others", "开头。这是一个很长的句子，中间有逗号，没有句号。结尾。", ThreadOptions{Limit: 10},
+			[]string{"开头。", "这是一个很长的句子，", "中间有逗号，", "没有句号。结尾。"}},
+		{"long sentence at space", "alpha beta gamma delta", ThreadOptions{Limit: 12}, []string{"alpha beta", "gamma delta"}},
+		{"no break point", "一二三四五六七八九十", ThreadOptions{Limit: 4}, []string{"一二三四", "五六七八", "九十"}},
+		{"numbered", "第一句。第二句。第三句。", ThreadOptions{Limit: 10, Numbered: true}, []string{"第一句。 (1/3)", "第二句。 (2/3)", "第三句。 (3/3)"}},
+	} {
+		got := SplitThread(tc.text, tc.opts)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: SplitThread = %q, want %q", tc.name, got, tc.want)
+		}
+		for _, chunk := range got {
+			if tc.opts.Limit > 0 && utf8.RuneCountInString(chunk) > tc.opts.Limit {
+				t.Errorf("%s: %q longer than %d", tc.name, chunk, tc.opts.Limit)
+			}
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package converter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LinkPolicy 链接处理方式
+type LinkPolicy string
+
+const (
+	// LinkFootnote 链接转为脚注（微信公众号不允许外链）
+	LinkFootnote LinkPolicy = "footnote"
+	// LinkInline 保留 <a> 链接
+	LinkInline LinkPolicy = "inline"
+	// LinkText 只保留链接文字
+	LinkText LinkPolicy = "text"
+)
+
+// MathMode 数学公式处理方式
+type MathMode string
+
+const (
+	// MathJax 输出 \( \) 和 \[ \] 包裹的 TeX，需要页面中的 MathJax 渲染，适合预览和导出的网页
+	MathJax MathMode = "mathjax"
+	// MathImage 输出公式图片，图片地址由 Profile.MathImageURL 生成
+	MathImage MathMode = "image"
+	// MathTeX 保留 TeX 源码
+	MathTeX MathMode = "tex"
+)
+
+// DefaultMathImageURL 默认的公式图片服务地址
+const DefaultMathImageURL = "https://latex.codecogs.com/png.image?"
+
+// CodeStyle 代码块输出方式
+type CodeStyle string
+
+const (
+	// CodeSection 使用带内联样式的 section，兼容微信编辑器
+	CodeSection CodeStyle = "section"
+	// CodePre 使用 <pre><code class="language-xx">，由平台自行高亮
+	CodePre CodeStyle = "pre"
+)
+
+// Profile 目标平台的输出规则
+type Profile struct {
+	Name string
+	// AllowedTags 允许的标签及其属性，不在列表中的标签被去掉（保留内容），为 nil 时不过滤
+	AllowedTags map[string][]string
+	LinkPolicy  LinkPolicy
+	MathMode    MathMode
+	// MathImageURL 公式图片地址前缀，后接 URL 编码的 TeX
+	MathImageURL string
+	CodeStyle    CodeStyle
+	// TableMode 默认表格输出方式，不支持表格的平台使用字符表格
+	TableMode    TableMode
+	DefaultTheme string
+}
+
+var htmlAttrRegex = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+
+// tagsWith 为一组标签设置相同的属性白名单
+func tagsWith(attrs []string, tags ...string) map[string][]string {
+	m := make(map[string][]string, len(tags))
+	for _, tag := range tags {
+		m[tag] = attrs
+	}
+	return m
+}
+
+// mergeTags 合并多个标签白名单
+func mergeTags(sets ...map[string][]string) map[string][]string {
+	m := make(map[string][]string)
+	for _, set := range sets {
+		for tag, attrs := range set {
+			if _, ok := m[tag]; !ok {
+				m[tag] = []string{}
+			}
+			for _, attr := range attrs {
+				if !containsString(m[tag], attr) {
+					m[tag] = append(m[tag], attr)
+				}
+			}
+		}
+	}
+	return m
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+var textTags = []string{"p", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "ul", "ol", "li", "strong", "em", "code", "pre", "sup", "span", "br", "hr"}
+var tableTags = []string{"table", "thead", "tbody", "tr", "th", "td"}
+
+// profiles 内置平台
+var profiles = map[string]Profile{
+	"wechat": {
+		Name: "wechat",
+		AllowedTags: mergeTags(
+			tagsWith([]string{"style"}, textTags...),
+			tagsWith([]string{"style"}, tableTags...),
+			tagsWith([]string{"colspan", "rowspan"}, "th", "td"),
+			tagsWith([]string{"style", "class"}, "span", "section"),
+			tagsWith([]string{"style", "src", "alt"}, "img"),
+		),
+		LinkPolicy:   LinkFootnote,
+		MathMode:     MathImage,
+		MathImageURL: DefaultMathImageURL,
+		CodeStyle:    CodeSection,
+		TableMode:    TableStandard,
+		DefaultTheme: DefaultTheme,
+	},
+	"zhihu": {
+		Name: "zhihu",
+		AllowedTags: mergeTags(
+			tagsWith(nil, textTags...),
+			tagsWith(nil, tableTags...),
+			tagsWith([]string{"colspan", "rowspan"}, "th", "td"),
+			tagsWith([]string{"class"}, "code"),
+			tagsWith([]string{"href"}, "a"),
+			tagsWith([]string{"src", "alt", "eeimg"}, "img"),
+		),
+		LinkPolicy:   LinkInline,
+		MathMode:     MathImage,
+		MathImageURL: "https://www.zhihu.com/equation?tex=",
+		CodeStyle:    CodePre,
+		TableMode:    TableStandard,
+		DefaultTheme: "zhihu_default",
+	},
+	"juejin": {
+		Name: "juejin",
+		AllowedTags: mergeTags(
+			tagsWith([]string{"style"}, textTags...),
+			tagsWith([]string{"style"}, tableTags...),
+			tagsWith([]string{"colspan", "rowspan"}, "th", "td"),
+			tagsWith([]string{"style", "class"}, "code", "section"),
+			tagsWith([]string{"style", "href"}, "a"),
+			tagsWith([]string{"style", "src", "alt"}, "img"),
+		),
+		LinkPolicy:   LinkInline,
+		MathMode:     MathTeX,
+		CodeStyle:    CodePre,
+		TableMode:    TableStandard,
+		DefaultTheme: "juejin_default",
+	},
+	"toutiao": {
+		Name: "toutiao",
+		AllowedTags: mergeTags(
+			tagsWith([]string{"style"}, textTags...),
+			tagsWith([]string{"style"}, "section"),
+			tagsWith([]string{"style", "src", "alt"}, "img"),
+		),
+		LinkPolicy:   LinkText,
+		MathMode:     MathImage,
+		MathImageURL: DefaultMathImageURL,
+		CodeStyle:    CodeSection,
+		TableMode:    TableASCII,
+		DefaultTheme: "toutiao_default",
+	},
+	"medium": {
+		Name: "medium",
+		AllowedTags: mergeTags(
+			tagsWith(nil, textTags...),
+			tagsWith([]string{"class"}, "code"),
+			tagsWith([]string{"href"}, "a"),
+			tagsWith([]string{"src", "alt"}, "img"),
+		),
+		LinkPolicy:   LinkInline,
+		MathMode:     MathImage,
+		MathImageURL: DefaultMathImageURL,
+		CodeStyle:    CodePre,
+		TableMode:    TableASCII,
+		DefaultTheme: "medium_default",
+	},
+}
+
+// DefaultProfile 默认平台
+const DefaultProfile = "wechat"
+
+// GetProfile 按名称获取平台规则，名称为空时返回微信公众号
+func GetProfile(name string) (Profile, bool) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p, ok := profiles[strings.ToLower(name)]
+	return p, ok
+}
+
+// Profiles 返回所有内置平台名称
+func Profiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetProfile 设置目标平台
+func (c *WechatConverter) SetProfile(p Profile) {
+	c.profile = p
+}
+
+// Profile 返回当前使用的目标平台规则
+func (c *WechatConverter) Profile() Profile {
+	return c.profile
+}
+
+// sanitizeHTML 按白名单过滤标签和属性，不允许的标签只去掉标签本身
+func sanitizeHTML(text string, allowed map[string][]string) string {
+	if allowed == nil {
+		return text
+	}
+
+	// 转换结果中同一个带样式的标签会重复出现很多次，过滤结果按标签原文缓存
+	seen := make(map[string]string)
+	return replaceHTMLTags(text, func(m []string) string {
+		if out, ok := seen[m[0]]; ok {
+			return out
+		}
+		out := sanitizeTag(m, allowed)
+		seen[m[0]] = out
+		return out
+	})
+}
+
+// sanitizeTag 过滤 replaceHTMLTags 找到的单个标签
+func sanitizeTag(m []string, allowed map[string][]string) string {
+	closing, name, attrs, selfClosing := m[1], strings.ToLower(m[2]), m[3], m[4]
+
+	allowedAttrs, ok := allowed[name]
+	if !ok {
+		return ""
+	}
+	if closing != "" {
+		return "</" + name + ">"
+	}
+
+	var kept []string
+	for i := 0; i < len(attrs); {
+		attr, attrName := attrAt(attrs[i:])
+		if attr == "" {
+			i++
+			continue
+		}
+		if containsString(allowedAttrs, strings.ToLower(attrName)) {
+			kept = append(kept, attr)
+		}
+		i += len(attr)
+	}
+
+	result := "<" + name
+	if len(kept) > 0 {
+		result += " " + strings.Join(kept, " ")
+	}
+	if selfClosing != "" {
+		result += " /"
+	}
+	return result + ">"
+}
+
+// replaceHTMLTags 把每个标签替换为 repl 的返回值，匹配规则与正则
+// <(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)> 相同，m 依次为整个标签和四个分组。
+// 转换结果中标签很密集，手工扫描比正则快一个数量级
+func replaceHTMLTags(text string, repl func(m []string) string) string {
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '<' {
+			continue
+		}
+		j := i + 1
+		closing := ""
+		if j < len(text) && text[j] == '/' && j+1 < len(text) && isASCIILetter(text[j+1]) {
+			closing = "/"
+			j++
+		}
+		if j >= len(text) || !isASCIILetter(text[j]) {
+			continue
+		}
+		nameStart := j
+		for j < len(text) && (isASCIILetter(text[j]) || text[j] >= '0' && text[j] <= '9') {
+			j++
+		}
+		end := strings.IndexByte(text[j:], '>')
+		if end < 0 {
+			break
+		}
+		end += j
+		attrsEnd, selfClosing := end, ""
+		if slash := strings.Index(text[j:end+1], "/>"); slash >= 0 {
+			attrsEnd, selfClosing = j+slash, "/"
+		}
+		if b.Cap() == 0 {
+			b.Grow(len(text))
+		}
+		b.WriteString(text[last:i])
+		b.WriteString(repl([]string{text[i : end+1], closing, text[nameStart:j], text[j:attrsEnd], selfClosing}))
+		last = end + 1
+		i = end
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// attrAt 返回 src 开头的属性及属性名，匹配规则与 htmlAttrRegex 相同；开头不是属性名时返回空串
+func attrAt(src string) (attr, name string) {
+	isNameStart := func(c byte) bool { return isASCIILetter(c) || c == '_' || c == ':' }
+	if src == "" || !isNameStart(src[0]) {
+		return "", ""
+	}
+	i := 1
+	for i < len(src) && (isNameStart(src[i]) || src[i] == '-' || src[i] == '.' || src[i] >= '0' && src[i] <= '9') {
+		i++
+	}
+	name = src[:i]
+
+	j := skipSpaces(src, i)
+	if j >= len(src) || src[j] != '=' {
+		return name, name
+	}
+	j = skipSpaces(src, j+1)
+	if j >= len(src) {
+		return name, name
+	}
+	switch c := src[j]; c {
+	case '"', '\'':
+		end := strings.IndexByte(src[j+1:], c)
+		if end < 0 {
+			return name, name
+		}
+		return src[:j+end+2], name
+	default:
+		end := j
+		for end < len(src) && strings.IndexByte("\t\n\f\r \"'>", src[end]) < 0 {
+			end++
+		}
+		if end == j {
+			return name, name
+		}
+		return src[:end], name
+	}
+}
+
+// skipSpaces 跳过正则 \s 匹配的空白
+func skipSpaces(s string, i int) int {
+	for i < len(s) && strings.IndexByte("\t\n\f\r ", s[i]) >= 0 {
+		i++
+	}
+	return i
+}
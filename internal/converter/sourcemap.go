@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SourceBlock 输出中带 data-line 的块对应的源码行范围（从 1 开始，包含 EndLine）
+type SourceBlock struct {
+	Line    int `json:"line"`
+	EndLine int `json:"end_line"`
+}
+
+// 源码行标记：转换前在每个块之前插入单独一行 lineMarkStart + 行号 + lineMarkEnd，
+// 转换后移到下一个标签的 data-line 属性上；使用私用区字符，不会与正文冲突
+const (
+	lineMarkStart = "\ue000"
+	lineMarkEnd   = "\ue001"
+)
+
+var (
+	lineMarkRegex = regexp.MustCompile(`(?:<p\b[^>]*>)?` + lineMarkStart + `(\d+)` + lineMarkEnd + `(?:</p>)?\n?`)
+	tagRegex      = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)[^>]*?(/?)>`)
+	dataLineRegex = regexp.MustCompile(`\s+data-line="\d+"`)
+)
+
+// SetSourceLines 设置是否在输出的块级元素上添加 data-line 源码行号，用于编辑器和预览同步滚动
+func (c *WechatConverter) SetSourceLines(enabled bool) {
+	c.sourceLines = enabled
+}
+
+// SourceMap 返回最近一次转换的块与源码行的对应关系，第 i 项对应输出中第 i 个带 data-line 的元素；
+// 未启用 SetSourceLines 时为空
+func (c *WechatConverter) SourceMap() []SourceBlock {
+	return c.sourceMap
+}
+
+// StripSourceLines 去掉 HTML 中的 data-line 属性，用于复制和发布
+func StripSourceLines(html string) string {
+	return dataLineRegex.ReplaceAllString(html, "")
+}
+
+// escapeLineMarks 启用源码行号时把正文中的行号标记字符替换为 U+FFFD，避免被当作行号
+func (c *WechatConverter) escapeLineMarks(text string) string {
+	if !c.sourceLines || !strings.ContainsAny(text, lineMarkStart+lineMarkEnd) {
+		return text
+	}
+	return strings.NewReplacer(lineMarkStart, "\uFFFD", lineMarkEnd, "\uFFFD").Replace(text)
+}
+
+// markSourceLines 在每个顶层块之前插入行号标记，需在 preprocessText 之前调用
+func (c *WechatConverter) markSourceLines(text string) string {
+	c.sourceMap = nil
+	c.sourceEnds = nil
+	if !c.sourceLines {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	merged := numberedContents(lines)
+	starts := make(map[int]int)
+	for _, block := range Parse(text).Blocks {
+		// 脚注定义会被整行删除，全是定义的段落没有对应的输出
+		if block.Type == BlockParagraph && onlyFootnoteDefs(lines[block.StartLine-1:block.EndLine]) {
+			continue
+		}
+		// "1." 与下一段内容由 preprocessText 合并，标记不能插在两者之间
+		if merged[block.StartLine-1] {
+			continue
+		}
+		starts[block.StartLine] = block.EndLine
+	}
+
+	c.sourceEnds = make(map[int]int, len(starts))
+	var b strings.Builder
+	for i, line := range lines {
+		if end, ok := starts[i+1]; ok {
+			b.WriteString(lineMarkStart + strconv.Itoa(i+1) + lineMarkEnd + "\n")
+			c.sourceEnds[i+1] = end
+		}
+		b.WriteString(line)
+		if i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// applySourceLines 把行号标记移到其后每个顶层元素的 data-line 属性上，生成 sourceMap；
+// 一个块可能输出多个顶层元素（如逐行输出的段落、有序和无序混排的列表），都标记为该块的行号；
+// 标记和下一个标记之间没有标签时丢弃该标记
+func (c *WechatConverter) applySourceLines(text string) string {
+	if !c.sourceLines {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	matches := lineMarkRegex.FindAllStringSubmatchIndex(text, -1)
+	for i, m := range matches {
+		b.WriteString(text[last:m[0]])
+		last = m[1]
+
+		limit := len(text)
+		if i+1 < len(matches) {
+			limit = matches[i+1][0]
+		}
+		line, _ := strconv.Atoi(text[m[2]:m[3]])
+		marked, n := addDataLines(text[last:limit], line)
+		if n == 0 {
+			// 文末的块没有输出时一并去掉它之前的换行，与不加行号时相同
+			if limit == len(text) && strings.TrimSpace(text[last:]) == "" {
+				return strings.TrimSuffix(b.String(), "\n")
+			}
+			continue
+		}
+		b.WriteString(marked)
+		last = limit
+		for ; n > 0; n-- {
+			c.sourceMap = append(c.sourceMap, SourceBlock{Line: line, EndLine: c.sourceEnds[line]})
+		}
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// addDataLines 在 html 的每个顶层元素的开始标签上添加 data-line 属性，返回添加的个数
+func addDataLines(html string, line int) (string, int) {
+	var b strings.Builder
+	attr := ` data-line="` + strconv.Itoa(line) + `"`
+	depth, n, last := 0, 0, 0
+	for _, m := range tagRegex.FindAllStringSubmatchIndex(html, -1) {
+		name := strings.ToLower(html[m[4]:m[5]])
+		switch {
+		case m[3] > m[2]:
+			// 片段从元素中间开始时忽略多出的结束标签
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				b.WriteString(html[last:m[5]])
+				b.WriteString(attr)
+				last = m[5]
+				n++
+			}
+			if !voidTags[name] && m[7] == m[6] {
+				depth++
+			}
+		}
+	}
+	if n == 0 {
+		return html, 0
+	}
+	b.WriteString(html[last:])
+	return b.String(), n
+}
+
+// onlyFootnoteDefs 判断各行是否都是脚注定义；与 extractFootnoteDefs 一样按原始行匹配，缩进的定义不算
+func onlyFootnoteDefs(lines []string) bool {
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "[^") || !footnoteDefRegex.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
+
+// numberedContents 返回 preprocessText 中与上方单独的 "1." 行合并的内容行下标
+func numberedContents(lines []string) map[int]bool {
+	merged := make(map[int]bool)
+	for i := 0; i < len(lines); i++ {
+		if !orderedItemOnlyRegex.MatchString(strings.TrimSpace(lines[i])) {
+			continue
+		}
+		// 编号与下一个非空行合并，中间的行不再参与合并
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != "" {
+				merged[j] = true
+				i = j
+				break
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,86 @@
+package converter
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// topLevelRegex 输出中的顶层元素（每个元素占一行）及其 data-line
+var topLevelRegex = regexp.MustCompile(`(?m)^<([a-z0-9]+)\b(?:[^>]*?\sdata-line="(\d+)")?`)
+
+// TestSourceLinesEveryTopLevelElement 每个顶层元素都带 data-line，块的范围与渲染结果一致
+func TestSourceLinesEveryTopLevelElement(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		markdown string
+		// want 按顺序列出顶层元素的标签和行号
+		want []string
+		// blocks 对应的 sourceMap
+		blocks []SourceBlock
+	}{
+		{
+			"unclosed fence",
+			"a\n\n```go\nx\n\n# Title\n\ntext\n",
+			[]string{"p:1", "p:3", "p:3", "h1:6", "p:8"},
+			[]SourceBlock{{1, 1}, {3, 4}, {3, 4}, {6, 6}, {8, 8}},
+		},
+		{
+			"fence closed mid-line",
+			"a\n\n```go\nx\n\ntail ``` here\n\n# T\n",
+			// 结束标记之后的文字单独成段
+			[]string{"p:1", "section:3", "p:3", "h1:8"},
+			[]SourceBlock{{1, 1}, {3, 6}, {3, 6}, {8, 8}},
+		},
+		{
+			"mixed list",
+			"1. a\n- b\n\nz\n",
+			[]string{"ol:1", "ul:1", "p:4"},
+			[]SourceBlock{{1, 2}, {1, 2}, {4, 4}},
+		},
+		{
+			"multi-line paragraph",
+			"a\nb\n\nc\n",
+			[]string{"p:1", "p:1", "p:4"},
+			[]SourceBlock{{1, 2}, {1, 2}, {4, 4}},
+		},
+	} {
+		for _, cached := range []bool{false, true} {
+			conv := NewWechatConverterFixed()
+			conv.SetSourceLines(true)
+			if cached {
+				conv.SetBlockCache(NewBlockCache(0))
+			}
+			html := conv.ConvertMarkdownToWechat(tc.markdown)
+
+			var got []string
+			for _, m := range topLevelRegex.FindAllStringSubmatch(html, -1) {
+				got = append(got, m[1]+":"+m[2])
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s (cached %v): elements = %q, want %q\n%s", tc.name, cached, got, tc.want, html)
+			}
+			if !reflect.DeepEqual(conv.SourceMap(), tc.blocks) {
+				t.Errorf("%s (cached %v): source map = %v, want %v", tc.name, cached, conv.SourceMap(), tc.blocks)
+			}
+		}
+	}
+}
+
+// TestAddDataLines 只标记顶层元素，跳过嵌套和自闭合元素内部，忽略片段开头多出的结束标签
+func TestAddDataLines(t *testing.T) {
+	for _, tc := range []struct {
+		html, want string
+		n          int
+	}{
+		{`<ul><li>a</li></ul><ul><li>b</li></ul>`, `<ul data-line="7"><li>a</li></ul><ul data-line="7"><li>b</li></ul>`, 2},
+		{`<p>a<br>b<img src="x.png"/></p><hr>`, `<p data-line="7">a<br>b<img src="x.png"/></p><hr data-line="7">`, 2},
+		{`a</strong></p><p>b</p>`, `a</strong></p><p data-line="7">b</p>`, 1},
+		{`text only`, `text only`, 0},
+	} {
+		got, n := addDataLines(tc.html, 7)
+		if got != tc.want || n != tc.n {
+			t.Errorf("addDataLines(%q) = %q, %d; want %q, %d", tc.html, got, n, tc.want, tc.n)
+		}
+	}
+}
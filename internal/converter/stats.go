@@ -0,0 +1,253 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxDigestLength 微信图文摘要的最大字数
+const MaxDigestLength = 120
+
+// 阅读速度：中文按每分钟 300 字，英文按每分钟 200 词，每张图片 12 秒
+const (
+	cjkCharsPerMinute = 300
+	wordsPerMinute    = 200
+	secondsPerImage   = 12
+)
+
+var (
+	inlineLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	inlineCodeRegex = regexp.MustCompile("`([^`]+)`")
+	emphasisRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`\*\*([^*]+)\*\*`),
+		regexp.MustCompile(`~~([^~]+)~~`),
+		regexp.MustCompile(`\*([^*\s][^*]*)\*`),
+	}
+	inlineTagRegex    = regexp.MustCompile(`<[^>]+>`)
+	listMarkerRegex   = regexp.MustCompile(`^(?:[-*+] (?:\[[ xX]\] )?|•|\d+\.\s*)`)
+	statsPlaceholders = regexp.MustCompile(`\{\{\s*(reading_time|reading_minutes|words|characters|images|links|code_blocks)\s*\}\}`)
+	// inlineMarkReplacer 去掉强调标记，按参数顺序优先匹配双字符标记
+	inlineMarkReplacer = strings.NewReplacer("**", "", "__", "", "~~", "", "*", "")
+)
+
+// Stats 文章统计信息
+type Stats struct {
+	// Digest 自动摘要，不超过 120 字
+	Digest string `json:"digest"`
+	// Characters 非空白字符数（不含代码块）
+	Characters int `json:"characters"`
+	// CJKCharacters 中日韩字符数
+	CJKCharacters int `json:"cjk_characters"`
+	// Words 字数：中日韩字符每字计一词，其他文字按空白分词
+	Words int `json:"words"`
+	// ReadingMinutes 预计阅读分钟数
+	ReadingMinutes int `json:"reading_minutes"`
+	Images         int `json:"images"`
+	Links          int `json:"links"`
+	CodeBlocks     int `json:"code_blocks"`
+}
+
+// ReadingTime 返回“阅读约 N 分钟”文案，供模板使用
+func (s Stats) ReadingTime() string {
+	return fmt.Sprintf("阅读约 %d 分钟", s.ReadingMinutes)
+}
+
+// ComputeStats 从解析后的文档计算统计信息
+func ComputeStats(doc *Document) Stats {
+	var stats Stats
+	var paragraphs []string
+	digestFull := false
+	latinWords := 0
+
+	for _, block := range doc.Blocks {
+		if block.Type == BlockCode {
+			stats.CodeBlocks++
+			continue
+		}
+
+		for _, line := range block.Lines {
+			if block.Type == BlockTable && isTableSeparator(line) {
+				continue
+			}
+			if strings.Contains(line, "](") {
+				stats.Images += len(imageRefRegex.FindAllStringIndex(line, -1))
+				stats.Links += len(inlineLinkRegex.FindAllStringIndex(imageRefRegex.ReplaceAllString(line, ""), -1))
+			}
+
+			// 空白不影响计数，不必像 inlineText 那样合并空白
+			text := stripMarks(line)
+			if block.Type == BlockTable {
+				text = strings.ReplaceAll(text, "|", " ")
+			}
+			chars, cjk, words := countText(text)
+			stats.Characters += chars
+			stats.CJKCharacters += cjk
+			latinWords += words
+		}
+
+		// 摘要只取开头的若干字，够长后不再收集段落
+		if block.Type == BlockParagraph && !digestFull {
+			paragraphs = append(paragraphs, inlineText(block.Text()))
+			digestFull = utf8.RuneCountInString(strings.TrimSpace(strings.Join(paragraphs, " "))) > MaxDigestLength
+		}
+	}
+
+	stats.Words = stats.CJKCharacters + latinWords
+	seconds := float64(stats.CJKCharacters)/cjkCharsPerMinute*60 +
+		float64(latinWords)/wordsPerMinute*60 +
+		float64(stats.Images*secondsPerImage)
+	if seconds > 0 {
+		stats.ReadingMinutes = int(math.Ceil(seconds / 60))
+	}
+
+	if digest := doc.FrontMatter.Get("digest", "description", "summary"); digest != "" {
+		stats.Digest = truncateDigest(digest)
+	} else {
+		stats.Digest = truncateDigest(strings.Join(paragraphs, " "))
+	}
+
+	return stats
+}
+
+// Stats 返回最近一次转换的统计信息
+func (c *WechatConverter) Stats() Stats {
+	return c.stats
+}
+
+// keepInner 只保留标记之间的文字
+func keepInner(inner string) string { return inner }
+
+// inlineText 去掉行内 Markdown 标记，保留可读文本
+func inlineText(text string) string {
+	return strings.Join(strings.Fields(stripMarks(text)), " ")
+}
+
+// stripMarks 去掉行内 Markdown 标记，保留原有空白
+func stripMarks(text string) string {
+	text = listMarkerRegex.ReplaceAllString(text, "")
+	// 正则替换前先确认文本中有对应的标记，大多数行不含任何标记
+	if strings.Contains(text, "{{") {
+		text = statsPlaceholders.ReplaceAllString(text, "")
+	}
+	if strings.Contains(text, "](") {
+		text = imageRefRegex.ReplaceAllString(text, "")
+		text = inlineLinkRegex.ReplaceAllString(text, "$1")
+	}
+	if strings.Contains(text, "`") {
+		text = replaceDelimited(text, "`", keepInner)
+	}
+	if strings.Contains(text, "<") {
+		text = inlineTagRegex.ReplaceAllString(text, "")
+	}
+	if strings.ContainsAny(text, "*_~") {
+		text = inlineMarkReplacer.Replace(text)
+	}
+	return text
+}
+
+// stripInline 去掉单元格等短文本中的行内标记，不处理列表符号
+func stripInline(text string) string {
+	text = imageRefRegex.ReplaceAllString(text, "$1")
+	text = inlineLinkRegex.ReplaceAllString(text, "$1")
+	text = replaceDelimited(text, "`", keepInner)
+	text = inlineTagRegex.ReplaceAllString(text, "")
+	// 只去掉成对的强调标记，保留 "1 * 2" 中的星号
+	for _, re := range emphasisRegexes {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// countText 统计非空白字符数、中日韩字符数和其他文字的词数
+func countText(text string) (chars, cjk, words int) {
+	inWord := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+		chars++
+		if isCJK(r) {
+			cjk++
+			inWord = false
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if !inWord {
+				words++
+			}
+			inWord = true
+		} else {
+			inWord = false
+		}
+	}
+	return chars, cjk, words
+}
+
+// isCJK 判断是否为中日韩文字
+func isCJK(r rune) bool {
+	// 四种文字的码位都不小于 U+1100，先排除最常见的拉丁字符
+	if r < 0x1100 {
+		return false
+	}
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// truncateDigest 截取摘要，尽量在句末断开
+func truncateDigest(text string) string {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= MaxDigestLength {
+		return text
+	}
+
+	runes := []rune(text)[:MaxDigestLength-1]
+	// 在后半段寻找句末标点
+	for i := len(runes) - 1; i >= len(runes)/2; i-- {
+		if strings.ContainsRune("。！？!?；;", runes[i]) {
+			return string(runes[:i+1])
+		}
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// expandStatsPlaceholders 替换正文中的 {{reading_time}} 等统计占位符（代码块和行内代码除外）
+func expandStatsPlaceholders(markdown string, stats Stats) string {
+	if !strings.Contains(markdown, "{{") {
+		return markdown
+	}
+
+	values := map[string]string{
+		"reading_time":    stats.ReadingTime(),
+		"reading_minutes": fmt.Sprint(stats.ReadingMinutes),
+		"words":           fmt.Sprint(stats.Words),
+		"characters":      fmt.Sprint(stats.Characters),
+		"images":          fmt.Sprint(stats.Images),
+		"links":           fmt.Sprint(stats.Links),
+		"code_blocks":     fmt.Sprint(stats.CodeBlocks),
+	}
+
+	return outsideCodeFences(markdown, func(part string, _ int) string {
+		lines := strings.Split(part, "\n")
+		for i, line := range lines {
+			if !strings.Contains(line, "{{") {
+				continue
+			}
+			// 只替换行内代码之外的部分
+			parts := strings.Split(line, "`")
+			for j := 0; j < len(parts); j += 2 {
+				parts[j] = statsPlaceholders.ReplaceAllStringFunc(parts[j], func(match string) string {
+					return values[statsPlaceholders.FindStringSubmatch(match)[1]]
+				})
+			}
+			lines[i] = strings.Join(parts, "`")
+		}
+		return strings.Join(lines, "\n")
+	})
+}
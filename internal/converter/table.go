@@ -0,0 +1,397 @@
+package converter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TableMode 表格输出方式
+type TableMode string
+
+const (
+	// TableStandard 带样式的普通表格
+	TableStandard TableMode = "table"
+	// TableScroll 外层可横向滚动的表格，适合列数较多的表格
+	TableScroll TableMode = "scroll"
+	// TableASCII 放在代码块中的字符表格，用于不支持表格的平台
+	TableASCII TableMode = "ascii"
+	// TableCard 每行转换为一组“列名：值”的卡片，适合手机阅读
+	TableCard TableMode = "card"
+)
+
+// CellRule 表格单元格样式规则，满足全部条件的单元格追加 Style
+type CellRule struct {
+	// Column 列号（从 1 开始），0 表示所有列
+	Column int `json:"column,omitempty"`
+	// Prefix 单元格内容以此开头
+	Prefix string `json:"prefix,omitempty"`
+	// Contains 单元格内容包含此文本
+	Contains string `json:"contains,omitempty"`
+	// Pattern 单元格内容匹配此正则表达式
+	Pattern string `json:"pattern,omitempty"`
+	// Style 追加的 CSS 声明，如 "color: #d63384; font-weight: bold;"
+	Style string `json:"style"`
+
+	pattern *regexp.Regexp
+}
+
+// TableOptions 表格渲染选项
+type TableOptions struct {
+	// Mode 表格输出方式，为空时使用平台默认值
+	Mode  TableMode
+	Rules []CellRule
+	// Spans 启用合并单元格语法：内容为 "<" 的单元格并入左侧单元格，内容为 "^" 的并入上方单元格
+	Spans bool
+}
+
+// 合并单元格标记
+const (
+	colspanMarker = "<"
+	rowspanMarker = "^"
+)
+
+// tableCell 表格单元格
+type tableCell struct {
+	text    string
+	colspan int
+	rowspan int
+	// merged 已并入其他单元格，不单独输出
+	merged bool
+}
+
+// table 整理后的表格，第一行为表头，每行的列数与表头一致
+type table struct {
+	aligns []string
+	rows   [][]tableCell
+}
+
+// buildTable 统一列数并计算合并单元格
+func (c *WechatConverter) buildTable(header []string, aligns []string, rows [][]string) *table {
+	columns := len(header)
+	t := &table{aligns: make([]string, columns)}
+	copy(t.aligns, aligns)
+
+	for _, cells := range append([][]string{header}, rows...) {
+		row := make([]tableCell, columns)
+		for j := range row {
+			// 列数不足的行补空单元格，多出的单元格丢弃
+			if j < len(cells) {
+				row[j].text = cells[j]
+			}
+			row[j].colspan, row[j].rowspan = 1, 1
+		}
+		t.rows = append(t.rows, row)
+	}
+
+	if c.tableOptions.Spans {
+		t.mergeSpans()
+	}
+	return t
+}
+
+// mergeSpans 处理合并单元格标记，表头和数据行之间不纵向合并
+func (t *table) mergeSpans() {
+	for i, row := range t.rows {
+		for j := range row {
+			switch row[j].text {
+			case colspanMarker:
+				k := j - 1
+				for k >= 0 && row[k].merged {
+					k--
+				}
+				if k < 0 || row[k].rowspan > 1 {
+					continue
+				}
+				row[k].colspan++
+				row[j].merged = true
+			case rowspanMarker:
+				if i <= 1 {
+					continue
+				}
+				k := i - 1
+				for k > 0 && t.rows[k][j].merged {
+					k--
+				}
+				if k < 1 || t.rows[k][j].merged || t.rows[k][j].colspan > 1 {
+					continue
+				}
+				t.rows[k][j].rowspan++
+				row[j].merged = true
+				// 纵向合并后保留内容，供卡片模式使用
+				row[j].text = t.rows[k][j].text
+			}
+		}
+	}
+}
+
+// SetTableOptions 设置表格渲染选项
+func (c *WechatConverter) SetTableOptions(opts TableOptions) error {
+	switch opts.Mode {
+	case "", TableStandard, TableScroll, TableASCII, TableCard:
+	default:
+		return fmt.Errorf("unknown table mode: %s", opts.Mode)
+	}
+
+	rules := make([]CellRule, len(opts.Rules))
+	for i, rule := range opts.Rules {
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("table rule %d: %w", i+1, err)
+			}
+			rule.pattern = re
+		}
+		rules[i] = rule
+	}
+	opts.Rules = rules
+
+	c.tableOptions = opts
+	return nil
+}
+
+// tableMode 当前使用的表格输出方式
+func (c *WechatConverter) tableMode() TableMode {
+	if c.tableOptions.Mode != "" {
+		return c.tableOptions.Mode
+	}
+	if c.profile.TableMode != "" {
+		return c.profile.TableMode
+	}
+	return TableStandard
+}
+
+// matches 判断单元格是否满足规则
+func (r CellRule) matches(column int, cell string) bool {
+	if r.Column > 0 && r.Column != column+1 {
+		return false
+	}
+	if r.Prefix != "" && !strings.HasPrefix(cell, r.Prefix) {
+		return false
+	}
+	if r.Contains != "" && !strings.Contains(cell, r.Contains) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(cell) {
+		return false
+	}
+	return true
+}
+
+// cellStyle 在 base 样式后依次追加匹配规则的样式
+func (c *WechatConverter) cellStyle(base string, column int, cell string) string {
+	style := base
+	for _, rule := range c.tableOptions.Rules {
+		if rule.matches(column, cell) {
+			style = appendStyle(style, rule.Style)
+		}
+	}
+	return style
+}
+
+// appendStyle 向 style="..." 属性追加 CSS 声明
+func appendStyle(attr, decls string) string {
+	decls = strings.TrimSpace(decls)
+	if decls == "" {
+		return attr
+	}
+	if !strings.HasSuffix(decls, ";") {
+		decls += ";"
+	}
+	decls = strings.ReplaceAll(decls, `"`, "'")
+
+	if !strings.HasPrefix(attr, `style="`) || !strings.HasSuffix(attr, `"`) || len(attr) < len(`style=""`) {
+		return `style="` + decls + `"`
+	}
+	existing := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(attr, `style="`), `"`))
+	if existing != "" && !strings.HasSuffix(existing, ";") {
+		existing += ";"
+	}
+	return `style="` + strings.TrimSpace(existing+" "+decls) + `"`
+}
+
+// splitTableRow 拆分表格行的单元格，单元格中的 \| 表示竖线本身
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+var cellInlineRegex = regexp.MustCompile("!\\[[^\\]]*\\]\\([^)]+\\)|\\[[^\\]]+\\]\\([^)]+\\)|`[^`]+`")
+
+// renderCellInline 渲染单元格中的行内元素：图片、链接、行内代码、粗体和斜体，其余文本转义
+func (c *WechatConverter) renderCellInline(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range cellInlineRegex.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:m[0]]))
+		match := html.EscapeString(text[m[0]:m[1]])
+		switch match[0] {
+		case '!':
+			b.WriteString(c.processImages(match))
+		case '[':
+			b.WriteString(c.tableLink(match))
+		default:
+			b.WriteString(c.processInlineCode(match))
+		}
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	out := c.processBoldItalic(b.String())
+	// 剩余的 * 和 ` 转为实体，避免后续整行处理时跨单元格匹配
+	out = strings.NewReplacer("*", "&#42;", "`", "&#96;").Replace(out)
+	return c.restoreInlineTokens(out)
+}
+
+// tableLinkRegex 匹配正文中的链接或表格单元格中的链接占位符（tableLinkPrefix + 序号 + "\x00"）
+var tableLinkRegex = regexp.MustCompile(`\x00l(\d+)\x00|` + linkRegex.String())
+
+// tableLinkPrefix 表格单元格中链接的占位符前缀
+const tableLinkPrefix = "\x00l"
+
+// tableLink 渲染单元格中已转义的链接；转为脚注的链接先用占位符代替，
+// 由 processLinks 与正文链接一起按出现顺序编号
+func (c *WechatConverter) tableLink(link string) string {
+	switch c.profile.LinkPolicy {
+	case LinkInline, LinkText:
+		return c.processLinks(link)
+	}
+	c.tableLinks = append(c.tableLinks, link)
+	return tableLinkPrefix + strconv.Itoa(len(c.tableLinks)-1) + "\x00"
+}
+
+// parseTableAligns 从分隔符行解析每列的对齐方式：left、center、right 或空
+func parseTableAligns(separator string) []string {
+	cells := splitTableRow(separator)
+	aligns := make([]string, len(cells))
+	for i, cell := range cells {
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[i] = "center"
+		case right:
+			aligns[i] = "right"
+		case left:
+			aligns[i] = "left"
+		}
+	}
+	return aligns
+}
+
+// renderCardTable 将每行数据转换为“列名：值”卡片
+func (c *WechatConverter) renderCardTable(t *table) string {
+	header := t.rows[0]
+	var b strings.Builder
+	for _, row := range t.rows[1:] {
+		b.WriteString(fmt.Sprintf(`<section %s>`, c.styles.TableCardStyle))
+		for j, cell := range row {
+			// 横向合并的单元格并入左侧，纵向合并的单元格重复显示上方内容
+			if cell.merged && cell.text == colspanMarker {
+				continue
+			}
+			label := j
+			for label > 0 && header[label].merged {
+				label--
+			}
+			b.WriteString(fmt.Sprintf(`<p style="margin: 0;"><span %s>%s</span><span %s>%s</span></p>`,
+				c.styles.TableCardLabelStyle, c.renderCellInline(header[label].text),
+				c.cellStyle(`style=""`, j, cell.text), c.renderCellInline(cell.text)))
+		}
+		b.WriteString("</section>")
+	}
+	return b.String()
+}
+
+// renderASCIITable 生成制表符绘制的字符表格，合并的单元格留空
+func renderASCIITable(t *table) string {
+	columns := len(t.aligns)
+	texts := make([][]string, len(t.rows))
+	widths := make([]int, columns)
+	for i, row := range t.rows {
+		texts[i] = make([]string, columns)
+		for j, cell := range row {
+			if !cell.merged {
+				texts[i][j] = stripInline(cell.text)
+			}
+			if w := displayWidth(texts[i][j]); w > widths[j] {
+				widths[j] = w
+			}
+		}
+	}
+
+	border := func(left, mid, right string) string {
+		parts := make([]string, columns)
+		for j, w := range widths {
+			parts[j] = strings.Repeat("─", w+2)
+		}
+		return left + strings.Join(parts, mid) + right + "\n"
+	}
+	line := func(cells []string) string {
+		parts := make([]string, columns)
+		for j, cell := range cells {
+			parts[j] = " " + padCell(cell, widths[j], t.aligns[j]) + " "
+		}
+		return "│" + strings.Join(parts, "│") + "│\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(border("┌", "┬", "┐"))
+	b.WriteString(line(texts[0]))
+	b.WriteString(border("├", "┼", "┤"))
+	for _, row := range texts[1:] {
+		b.WriteString(line(row))
+	}
+	b.WriteString(border("└", "┴", "┘"))
+	return b.String()
+}
+
+// padCell 按显示宽度补齐单元格
+func padCell(text string, width int, align string) string {
+	gap := width - displayWidth(text)
+	switch align {
+	case "right":
+		return strings.Repeat(" ", gap) + text
+	case "center":
+		left := gap / 2
+		return strings.Repeat(" ", left) + text + strings.Repeat(" ", gap-left)
+	default:
+		return text + strings.Repeat(" ", gap)
+	}
+}
+
+// displayWidth 计算文本在等宽字体下的显示宽度，全角字符计 2
+func displayWidth(text string) int {
+	width := 0
+	for _, r := range text {
+		switch {
+		case r < 0x20 || unicode.Is(unicode.Mn, r):
+		case isCJK(r) || (r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xff60) || (r >= 0xffe0 && r <= 0xffe6):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
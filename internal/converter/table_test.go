@@ -0,0 +1,157 @@
+package converter
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// footnoteMarkRegex 输出中的脚注编号
+var footnoteMarkRegex = regexp.MustCompile(`<sup>\[(\d+)\]</sup>`)
+
+// TestTableLinkFootnoteOrder 表格中的链接与正文链接按出现顺序编号，[^id] 引用仍先于链接编号
+func TestTableLinkFootnoteOrder(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		markdown  string
+		mode      TableMode
+		marks     []string
+		footnotes []string
+	}{
+		{
+			"link before table",
+			"[甲](https://a.example)\n\n| 名称 | 地址 |\n| --- | --- |\n| 乙 | [乙](https://b.example) |\n\n[丙](https://c.example)\n",
+			TableStandard,
+			[]string{"1", "2", "3"},
+			[]string{"https://a.example", "https://b.example", "https://c.example"},
+		},
+		{
+			"card table",
+			"[甲](https://a.example)\n\n| 名称 | 地址 |\n| --- | --- |\n| [乙](https://b.example) | [丙](https://c.example) |\n",
+			TableCard,
+			[]string{"1", "2", "3"},
+			[]string{"https://a.example", "https://b.example", "https://c.example"},
+		},
+		{
+			"same link in body and table",
+			"[甲](https://a.example) 见[^n]\n\n| 地址 |\n| --- |\n| [甲](https://a.example) |\n\n[^n]: 注释\n",
+			TableStandard,
+			[]string{"2", "1", "3"},
+			[]string{"注释", "https://a.example", "https://a.example"},
+		},
+	} {
+		for _, cached := range []bool{false, true} {
+			conv := NewWechatConverterFixed()
+			conv.SetTableOptions(TableOptions{Mode: tc.mode})
+			if cached {
+				conv.SetBlockCache(NewBlockCache(0))
+			}
+			html := conv.ConvertMarkdownToWechat(tc.markdown)
+			var marks []string
+			for _, m := range footnoteMarkRegex.FindAllStringSubmatch(html, -1) {
+				marks = append(marks, m[1])
+			}
+			if !reflect.DeepEqual(marks, tc.marks) {
+				t.Errorf("%s (cached %v): footnote marks = %v, want %v\n%s", tc.name, cached, marks, tc.marks, html)
+			}
+			if !reflect.DeepEqual(conv.Footnotes(), tc.footnotes) {
+				t.Errorf("%s (cached %v): footnotes = %q, want %q", tc.name, cached, conv.Footnotes(), tc.footnotes)
+			}
+		}
+	}
+}
+
+// tableModeSample 带对齐方式和横向合并单元格的表格
+const tableModeSample = "| 名称 | 数量 | 说明 |\n| :--- | ---: | :---: |\n| 苹果 | 3 | 红 |\n| banana | -12 | < |\n"
+
+// TestTableModes 每种输出方式的结构、对齐方式和合并单元格
+func TestTableModes(t *testing.T) {
+	for _, tc := range []struct {
+		mode TableMode
+		want []string
+		not  []string
+	}{
+		{
+			TableStandard,
+			[]string{`<table `, `text-align: left;">名称</th>`, `text-align: right;">3</td>`, `text-align: center;">红</td>`, `text-align: right;" colspan="2">-12</td>`},
+			[]string{`white-space: nowrap`, `&lt;`},
+		},
+		{
+			TableScroll,
+			[]string{`overflow-x: auto;`, `width: auto; min-width: 100%; white-space: nowrap;">`, `text-align: right;" colspan="2">-12</td>`},
+			nil,
+		},
+		{
+			TableASCII,
+			[]string{"┌────────┬──────┬──────┐\n│ 名称   │ 数量 │ 说明 │\n├────────┼──────┼──────┤\n│ 苹果   │    3 │  红  │\n│ banana │  -12 │      │\n└────────┴──────┴──────┘\n"},
+			[]string{`<table`},
+		},
+		{
+			TableCard,
+			[]string{`名称</span><span style="">苹果</span>`, `数量</span><span style="">3</span>`, `说明</span><span style="">红</span>`, `数量</span><span style="">-12</span></p></section>`},
+			[]string{`<table`, `说明</span><span style="">&lt;`},
+		},
+	} {
+		conv := NewWechatConverterFixed()
+		if err := conv.SetTableOptions(TableOptions{Mode: tc.mode, Spans: true}); err != nil {
+			t.Fatal(err)
+		}
+		out := conv.ConvertMarkdownToWechat(tableModeSample)
+		for _, s := range tc.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: output lacks %q:\n%s", tc.mode, s, out)
+			}
+		}
+		for _, s := range tc.not {
+			if strings.Contains(out, s) {
+				t.Errorf("%s: output contains %q:\n%s", tc.mode, s, out)
+			}
+		}
+	}
+
+	if err := NewWechatConverterFixed().SetTableOptions(TableOptions{Mode: "grid"}); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+// TestCellRules 规则按顺序追加，后面的规则覆盖前面的同名声明，对齐方式最后追加；表头不应用规则
+func TestCellRules(t *testing.T) {
+	conv := NewWechatConverterFixed()
+	err := conv.SetTableOptions(TableOptions{Rules: []CellRule{
+		{Column: 2, Style: "color: red"},
+		{Prefix: "-", Style: `color: green; font-family: "Mono";`},
+		{Contains: "1", Pattern: `^-?\d+$`, Style: "font-weight: bold;"},
+		{Column: 1, Contains: "1", Style: "color: blue;"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		column int
+		cell   string
+		want   string
+	}{
+		{1, "3", `style="base; color: red;"`},
+		{1, "-12", `style="base; color: red; color: green; font-family: 'Mono'; font-weight: bold;"`},
+		{0, "-1x", `style="base; color: green; font-family: 'Mono'; color: blue;"`},
+		{2, "red", `style="base"`},
+	} {
+		if got := conv.cellStyle(`style="base"`, tc.column, tc.cell); got != tc.want {
+			t.Errorf("cellStyle(%d, %q) = %s, want %s", tc.column, tc.cell, got, tc.want)
+		}
+	}
+
+	out := conv.ConvertMarkdownToWechat("| 数量 | 数量 |\n| --- | ---: |\n| a | -1 |\n")
+	if !strings.Contains(out, `color: red; color: green; font-family: 'Mono'; font-weight: bold; text-align: right;">-1</td>`) {
+		t.Errorf("rule styles not applied before alignment:\n%s", out)
+	}
+	if strings.Contains(out, `color: red;">数量</th>`) || strings.Count(out, "color: red") != 1 {
+		t.Errorf("rules applied to header:\n%s", out)
+	}
+
+	if err := conv.SetTableOptions(TableOptions{Rules: []CellRule{{Pattern: "("}}}); err == nil || !strings.Contains(err.Error(), "table rule 1") {
+		t.Errorf("invalid pattern: err = %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTheme 内置默认主题名
+const DefaultTheme = "default"
+
+var (
+	cssCommentRegex = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssVarRegex     = regexp.MustCompile(`var\(\s*(--[\w-]+)\s*(?:,\s*([^)]*))?\)`)
+)
+
+// themeTargets CSS 选择器（去掉 #wenyan 前缀后）到样式字段的映射
+var themeTargets = map[string]string{
+	"h1":           "H1Style",
+	"h2":           "H2Style",
+	"h3":           "H3Style",
+	"p":            "ParagraphStyle",
+	"blockquote":   "QuoteStyle",
+	"blockquote p": "QuoteStyle",
+	"pre":          "CodeBlockStyle",
+	"pre code":     "CodeBlockStyle",
+	"p code":       "InlineCodeStyle",
+	"li code":      "InlineCodeStyle",
+	"ul":           "ListStyle",
+	"ol":           "ListStyle",
+	"a":            "LinkStyle",
+	"img":          "ImageStyle",
+	"table":        "TableStyle",
+	"table th":     "TableHeaderStyle",
+	"table td":     "TableCellStyle",
+}
+
+// cssDecls 保持声明顺序的 CSS 属性集合
+type cssDecls struct {
+	order  []string
+	values map[string]string
+}
+
+func (d *cssDecls) set(prop, value string) {
+	if d.values == nil {
+		d.values = make(map[string]string)
+	}
+	if _, ok := d.values[prop]; !ok {
+		d.order = append(d.order, prop)
+	}
+	d.values[prop] = value
+}
+
+// attr 生成 style="..." 属性
+func (d *cssDecls) attr() string {
+	return `style="` + d.String() + `"`
+}
+
+// String 生成 CSS 声明列表
+func (d *cssDecls) String() string {
+	parts := make([]string, 0, len(d.order))
+	for _, prop := range d.order {
+		parts = append(parts, prop+": "+strings.ReplaceAll(d.values[prop], `"`, "'")+";")
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseThemeCSS 将 wenyan 主题 CSS 转换为内联样式，主题未覆盖的元素沿用默认样式
+func ParseThemeCSS(css string) WechatStyles {
+	styles := getDefaultStyles()
+	for field, decls := range parseThemeTargets(css) {
+		if field == "CodeBlockStyle" {
+			// 代码块使用 section 渲染，必须保留换行
+			if _, ok := decls.values["display"]; !ok {
+				decls.set("display", "block")
+			}
+			decls.set("white-space", "pre")
+		}
+		setStyleField(&styles, field, decls.attr())
+	}
+	return styles
+}
+
+// ApplyCustomCSS 把 wenyan 格式的样式表追加到当前样式上，同名属性覆盖主题中的值，需在 SetStyles 之后调用
+func (c *WechatConverter) ApplyCustomCSS(css string) {
+	for field, decls := range parseThemeTargets(css) {
+		if p := styleField(&c.styles, field); p != nil {
+			*p = appendStyle(*p, decls.String())
+		}
+	}
+}
+
+// parseThemeTargets 按样式字段收集 #wenyan 选择器下的 CSS 声明，并替换其中的 var()
+func parseThemeTargets(css string) map[string]*cssDecls {
+	css = cssCommentRegex.ReplaceAllString(css, "")
+	vars := make(map[string]string)
+	targets := make(map[string]*cssDecls)
+
+	for _, rule := range splitCSSRules(css) {
+		decls := parseCSSDecls(rule.body)
+		for _, raw := range strings.Split(rule.selector, ",") {
+			selector := strings.Join(strings.Fields(raw), " ")
+			if selector == ":root" {
+				for _, prop := range decls.order {
+					if strings.HasPrefix(prop, "--") {
+						vars[prop] = decls.values[prop]
+					}
+				}
+				continue
+			}
+			if !strings.HasPrefix(selector, "#wenyan") {
+				continue
+			}
+			selector = strings.TrimSpace(strings.TrimPrefix(selector, "#wenyan"))
+			field, ok := themeTargets[selector]
+			if !ok {
+				continue
+			}
+			if targets[field] == nil {
+				targets[field] = &cssDecls{}
+			}
+			for _, prop := range decls.order {
+				targets[field].set(prop, decls.values[prop])
+			}
+		}
+	}
+
+	for _, decls := range targets {
+		for _, prop := range decls.order {
+			decls.values[prop] = resolveCSSVars(decls.values[prop], vars)
+		}
+	}
+	return targets
+}
+
+// LoadTheme 从主题目录加载主题，name 为空或 default 时返回内置样式
+func LoadTheme(dir, name string) (WechatStyles, error) {
+	css, err := ReadThemeCSS(dir, name)
+	if err != nil {
+		return WechatStyles{}, err
+	}
+	if css == "" {
+		return getDefaultStyles(), nil
+	}
+	return ParseThemeCSS(css), nil
+}
+
+// ReadThemeCSS 读取主题样式表原文，name 为空或 default 时返回空字符串
+func ReadThemeCSS(dir, name string) (string, error) {
+	if name == "" || name == DefaultTheme {
+		return "", nil
+	}
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return "", fmt.Errorf("invalid theme name: %s", name)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".css"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("unknown theme: %s", name)
+		}
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ListThemes 列出主题目录中可用的主题
+func ListThemes(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	themes := []string{DefaultTheme}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".css") {
+			themes = append(themes, strings.TrimSuffix(entry.Name(), ".css"))
+		}
+	}
+	sort.Strings(themes[1:])
+	return themes, nil
+}
+
+// codeThemes 内置代码块配色：文字颜色和背景色
+var codeThemes = map[string][2]string{
+	"default":         {"rgb(51, 51, 51)", "rgb(248, 248, 248)"},
+	"github":          {"#24292e", "#f6f8fa"},
+	"github-dark":     {"#c9d1d9", "#0d1117"},
+	"monokai":         {"#f8f8f2", "#272822"},
+	"one-dark":        {"#abb2bf", "#282c34"},
+	"solarized-dark":  {"#839496", "#002b36"},
+	"solarized-light": {"#657b83", "#fdf6e3"},
+}
+
+// CodeThemes 列出内置代码块配色
+func CodeThemes() []string {
+	names := make([]string, 0, len(codeThemes))
+	for name := range codeThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetCodeTheme 覆盖代码块的文字和背景颜色，需在 SetStyles 之后调用；name 为空时沿用主题配色
+func (c *WechatConverter) SetCodeTheme(name string) error {
+	if name == "" {
+		return nil
+	}
+	colors, ok := codeThemes[name]
+	if !ok {
+		return fmt.Errorf("unknown code theme: %s", name)
+	}
+	c.styles.CodeBlockStyle = appendStyle(c.styles.CodeBlockStyle, fmt.Sprintf("color: %s; background: %s;", colors[0], colors[1]))
+	return nil
+}
+
+// SetStyles 设置转换使用的样式
+func (c *WechatConverter) SetStyles(styles WechatStyles) {
+	c.styles = styles
+}
+
+// setStyleField 按字段名设置样式
+func setStyleField(s *WechatStyles, field, value string) {
+	if p := styleField(s, field); p != nil {
+		*p = value
+	}
+}
+
+// styleField 按字段名返回样式字段，未知字段返回 nil
+func styleField(s *WechatStyles, field string) *string {
+	switch field {
+	case "H1Style":
+		return &s.H1Style
+	case "H2Style":
+		return &s.H2Style
+	case "H3Style":
+		return &s.H3Style
+	case "ParagraphStyle":
+		return &s.ParagraphStyle
+	case "QuoteStyle":
+		return &s.QuoteStyle
+	case "CodeBlockStyle":
+		return &s.CodeBlockStyle
+	case "InlineCodeStyle":
+		return &s.InlineCodeStyle
+	case "ListStyle":
+		return &s.ListStyle
+	case "LinkStyle":
+		return &s.LinkStyle
+	case "ImageStyle":
+		return &s.ImageStyle
+	case "TableStyle":
+		return &s.TableStyle
+	case "TableHeaderStyle":
+		return &s.TableHeaderStyle
+	case "TableCellStyle":
+		return &s.TableCellStyle
+	}
+	return nil
+}
+
+type cssRule struct {
+	selector string
+	body     string
+}
+
+// splitCSSRules 拆分顶层 CSS 规则，跳过 @media 等嵌套规则
+func splitCSSRules(css string) []cssRule {
+	var rules []cssRule
+	for len(css) > 0 {
+		open := strings.Index(css, "{")
+		if open < 0 {
+			break
+		}
+		selector := strings.TrimSpace(css[:open])
+
+		// 找到匹配的右括号
+		depth, end := 0, -1
+		for i := open; i < len(css); i++ {
+			if css[i] == '{' {
+				depth++
+			} else if css[i] == '}' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			break
+		}
+
+		if !strings.HasPrefix(selector, "@") {
+			rules = append(rules, cssRule{selector: selector, body: css[open+1 : end]})
+		}
+		css = css[end+1:]
+	}
+	return rules
+}
+
+// parseCSSDecls 解析声明块
+func parseCSSDecls(body string) *cssDecls {
+	decls := &cssDecls{}
+	for _, decl := range strings.Split(body, ";") {
+		colon := strings.Index(decl, ":")
+		if colon < 0 {
+			continue
+		}
+		prop := strings.ToLower(strings.TrimSpace(decl[:colon]))
+		value := strings.TrimSpace(decl[colon+1:])
+		if prop == "" || value == "" {
+			continue
+		}
+		decls.set(prop, value)
+	}
+	return decls
+}
+
+// resolveCSSVars 替换 var(--name) 引用，最多展开几层嵌套变量
+func resolveCSSVars(value string, vars map[string]string) string {
+	for i := 0; i < 5 && strings.Contains(value, "var("); i++ {
+		value = cssVarRegex.ReplaceAllStringFunc(value, func(match string) string {
+			m := cssVarRegex.FindStringSubmatch(match)
+			if v, ok := vars[m[1]]; ok {
+				return v
+			}
+			return strings.TrimSpace(m[2])
+		})
+	}
+	return value
+}
@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HeadingNumbering 标题编号方式
+type HeadingNumbering string
+
+const (
+	// NumberingNone 不编号
+	NumberingNone HeadingNumbering = ""
+	// NumberingH1 从一级标题开始编号：1、1.1、1.1.1
+	NumberingH1 HeadingNumbering = "h1"
+	// NumberingH2 一级标题不编号，从二级标题开始：1、1.1
+	NumberingH2 HeadingNumbering = "h2"
+)
+
+// TOCEntry 目录项，只包含转换器支持的一到三级标题
+type TOCEntry struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	// Number 标题编号，不编号时为空
+	Number string `json:"number,omitempty"`
+	Line   int    `json:"line"`
+}
+
+var numberedHeadingRegex = regexp.MustCompile(`(?m)^(#{1,3}) (.+)$`)
+
+// headingNumberer 按标题级别生成 1、1.1、1.1.1 形式的编号
+type headingNumberer struct {
+	first    int
+	counters [3]int
+}
+
+func newHeadingNumberer(n HeadingNumbering) *headingNumberer {
+	switch n {
+	case NumberingH1:
+		return &headingNumberer{first: 1}
+	case NumberingH2:
+		return &headingNumberer{first: 2}
+	}
+	return &headingNumberer{}
+}
+
+// next 返回下一个 level 级标题的编号，不编号的级别返回空字符串
+func (h *headingNumberer) next(level int) string {
+	if h.first == 0 || level < h.first || level > 3 {
+		return ""
+	}
+	i := level - h.first
+	h.counters[i]++
+	for j := i + 1; j < len(h.counters); j++ {
+		h.counters[j] = 0
+	}
+	parts := make([]string, i+1)
+	for j := range parts {
+		parts[j] = strconv.Itoa(h.counters[j])
+	}
+	return strings.Join(parts, ".")
+}
+
+// SetHeadingNumbering 设置标题编号方式
+func (c *WechatConverter) SetHeadingNumbering(n HeadingNumbering) error {
+	switch n {
+	case NumberingNone, NumberingH1, NumberingH2:
+		c.headingNumbering = n
+		return nil
+	}
+	return fmt.Errorf("unknown heading numbering: %s", n)
+}
+
+// numberHeadings 在标题文字前加上编号，编号接着 c.numberer 的计数；需在 processHeaders 之前调用
+func (c *WechatConverter) numberHeadings(text string) string {
+	if c.headingNumbering == NumberingNone {
+		return text
+	}
+	h := c.numberer
+	return replaceSubmatches(numberedHeadingRegex, text, func(m []string) string {
+		match := m[0]
+		title := strings.TrimSpace(m[2])
+		if title == "" {
+			return match
+		}
+		if num := h.next(len(m[1])); num != "" {
+			return m[1] + " " + num + " " + title
+		}
+		return match
+	})
+}
+
+// BuildTOC 生成文章目录，编号与转换时使用同一编号方式的结果一致
+func BuildTOC(doc *Document, n HeadingNumbering) []TOCEntry {
+	h := newHeadingNumberer(n)
+	toc := []TOCEntry{}
+	for _, block := range doc.Blocks {
+		if block.Type != BlockHeading || block.Level > 3 || strings.TrimSpace(block.Text()) == "" {
+			continue
+		}
+		toc = append(toc, TOCEntry{
+			Level:  block.Level,
+			Text:   inlineText(block.Text()),
+			Number: h.next(block.Level),
+			Line:   block.StartLine,
+		})
+	}
+	return toc
+}
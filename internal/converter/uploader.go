@@ -0,0 +1,136 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ImageUploader 图片上传接口，渲染时用于改写图片地址
+type ImageUploader interface {
+	// Upload 上传图片内容，返回可公开访问的地址
+	Upload(name string, data []byte) (string, error)
+}
+
+// hostChecker 可选接口：判断图片是否已托管在目标服务上，无需重新上传
+type hostChecker interface {
+	IsHosted(imageURL string) bool
+}
+
+// SetImageUploader 设置图片上传器，为 nil 时不上传
+func (c *WechatConverter) SetImageUploader(u ImageUploader) {
+	c.uploader = u
+}
+
+// contentHash 计算图片内容的 sha256
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CachingUploader 按内容哈希缓存上传结果，避免重复上传同一张图片
+type CachingUploader struct {
+	uploader  ImageUploader
+	cachePath string
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewCachingUploader 创建带缓存的上传器，cachePath 不为空时缓存持久化到该 JSON 文件
+func NewCachingUploader(u ImageUploader, cachePath string) (*CachingUploader, error) {
+	cu := &CachingUploader{
+		uploader:  u,
+		cachePath: cachePath,
+		cache:     make(map[string]string),
+	}
+
+	if cachePath != "" {
+		data, err := os.ReadFile(cachePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("read upload cache: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &cu.cache); err != nil {
+				return nil, fmt.Errorf("parse upload cache: %w", err)
+			}
+		}
+	}
+
+	return cu, nil
+}
+
+// Upload 内容已上传过时直接返回缓存地址
+func (cu *CachingUploader) Upload(name string, data []byte) (string, error) {
+	key := contentHash(data)
+
+	cu.mu.Lock()
+	if cached, ok := cu.cache[key]; ok {
+		cu.mu.Unlock()
+		return cached, nil
+	}
+	cu.mu.Unlock()
+
+	uploaded, err := cu.uploader.Upload(name, data)
+	if err != nil {
+		return "", err
+	}
+
+	cu.mu.Lock()
+	defer cu.mu.Unlock()
+	cu.cache[key] = uploaded
+	if err := cu.save(); err != nil {
+		return "", err
+	}
+	return uploaded, nil
+}
+
+// IsHosted 转发给被包装的上传器
+func (cu *CachingUploader) IsHosted(imageURL string) bool {
+	if h, ok := cu.uploader.(hostChecker); ok {
+		return h.IsHosted(imageURL)
+	}
+	return false
+}
+
+// save 将缓存写回文件
+func (cu *CachingUploader) save() error {
+	if cu.cachePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(cu.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := cu.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write upload cache: %w", err)
+	}
+	return os.Rename(tmp, cu.cachePath)
+}
+
+// LocalUploader 将图片保存到本地目录，按内容哈希命名
+type LocalUploader struct {
+	Dir     string
+	BaseURL string
+}
+
+// Upload 写入图片文件，返回 BaseURL 下的访问地址
+func (u *LocalUploader) Upload(name string, data []byte) (string, error) {
+	if err := os.MkdirAll(u.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("create upload dir: %w", err)
+	}
+
+	fileName := contentHash(data)[:16] + strings.ToLower(path.Ext(name))
+	if err := os.WriteFile(filepath.Join(u.Dir, fileName), data, 0o644); err != nil {
+		return "", fmt.Errorf("write image: %w", err)
+	}
+
+	return strings.TrimSuffix(u.BaseURL, "/") + "/" + fileName, nil
+}
@@ -0,0 +1,130 @@
+package converter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// S3Uploader 上传图片到 S3 兼容的对象存储（AWS S3、MinIO、R2、COS 等）
+type S3Uploader struct {
+	// Endpoint 服务地址，如 https://s3.us-east-1.amazonaws.com
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix 对象键前缀，如 images/
+	Prefix string
+	// PublicURL 对外访问地址前缀，为空时使用 Endpoint/Bucket
+	PublicURL string
+	// VirtualHost 使用 bucket.endpoint 形式的虚拟主机地址
+	VirtualHost bool
+	HTTPClient  *http.Client
+}
+
+// Upload 以内容哈希作为对象键上传图片
+func (u *S3Uploader) Upload(name string, data []byte) (string, error) {
+	ext := strings.ToLower(path.Ext(name))
+	key := strings.TrimPrefix(u.Prefix+contentHash(data)[:16]+ext, "/")
+
+	endpoint, err := url.Parse(u.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	objectURL := *endpoint
+	if u.VirtualHost {
+		objectURL.Host = u.Bucket + "." + endpoint.Host
+		objectURL.Path = "/" + key
+	} else {
+		objectURL.Path = "/" + u.Bucket + "/" + key
+	}
+
+	req, err := http.NewRequest(http.MethodPut, objectURL.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	req.Header.Set("Content-Type", contentType)
+	u.sign(req, data, time.Now().UTC())
+
+	client := u.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("put object: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("put object: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if u.PublicURL != "" {
+		return strings.TrimSuffix(u.PublicURL, "/") + "/" + key, nil
+	}
+	return objectURL.String(), nil
+}
+
+// sign 使用 AWS Signature Version 4 签名请求
+func (u *S3Uploader) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := contentHash(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + u.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		contentHash([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+u.SecretKey), date)
+	key = hmacSHA256(key, u.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		u.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,235 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+	"bilibili-uploader/internal/wechat"
+)
+
+var (
+	firstImageRegex = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]+)[^)]*\)`)
+	firstH1Regex    = regexp.MustCompile(`(?m)^# (.+)$`)
+)
+
+// ErrInvalidDocument 文章本身不满足发布要求（缺少标题、封面或主题不存在）
+var ErrInvalidDocument = errors.New("invalid document")
+
+// Publisher 将 Markdown 渲染后发布到微信公众号草稿箱
+type Publisher struct {
+	Client *wechat.Client
+	// Images 正文图片上传器，通常是包装了 Client 的 CachingUploader
+	Images converter.ImageUploader
+	// Thumbs 封面上传器，Upload 返回素材 media_id
+	Thumbs converter.ImageUploader
+	// ThemeDir 主题 CSS 所在目录
+	ThemeDir string
+}
+
+// Document 待发布的一篇文章
+type Document struct {
+	Markdown string
+	// Theme 主题名，为空时使用元信息中的 theme，再为空时使用默认主题
+	Theme string
+	// BaseDir 正文相对路径图片和封面的根目录
+	BaseDir string
+	// Cover 封面图片地址或相对于 BaseDir 的路径，覆盖元信息中的 cover
+	Cover string
+	// CoverData 封面图片内容，设置后不再读取 Cover，Cover 只用作文件名；用于命令行指定的任意本地文件
+	CoverData []byte
+	// Fetcher 正文和封面远程图片的下载限制，为 nil 时只限制单张图片大小；处理不可信的请求时必须设置，
+	// 同一请求的多篇文章共用一个以共享额度
+	Fetcher *converter.ImageFetcher
+}
+
+// Result 发布结果
+type Result struct {
+	// MediaID 草稿 media_id，只生成草稿内容（dry run）时为空
+	MediaID  string           `json:"media_id,omitempty"`
+	Articles []wechat.Article `json:"articles"`
+	Warnings []ArticleWarning `json:"warnings,omitempty"`
+}
+
+// ArticleWarning 带文章序号（从 0 开始）的转换警告
+type ArticleWarning struct {
+	Article int `json:"article"`
+	converter.Warning
+}
+
+// New 创建发布器，cacheDir 不为空时图片和封面的上传记录持久化到该目录
+func New(client *wechat.Client, themeDir, cacheDir string) (*Publisher, error) {
+	imageCache, thumbCache := "", ""
+	if cacheDir != "" {
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			return nil, fmt.Errorf("create cache dir: %w", err)
+		}
+		imageCache = filepath.Join(cacheDir, "images.json")
+		thumbCache = filepath.Join(cacheDir, "thumbs.json")
+	}
+
+	images, err := converter.NewCachingUploader(client, imageCache)
+	if err != nil {
+		return nil, err
+	}
+	thumbs, err := converter.NewCachingUploader(wechat.ThumbUploader{Client: client}, thumbCache)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{
+		Client:   client,
+		Images:   images,
+		Thumbs:   thumbs,
+		ThemeDir: themeDir,
+	}, nil
+}
+
+// BuildArticle 渲染文章、上传图片和封面，生成草稿图文
+func (p *Publisher) BuildArticle(doc Document) (wechat.Article, []converter.Warning, error) {
+	fm, body := converter.ParseFrontMatter(doc.Markdown)
+
+	theme := doc.Theme
+	if theme == "" {
+		theme = fm.Get("theme")
+	}
+	styles, err := converter.LoadTheme(p.ThemeDir, theme)
+	if err != nil {
+		return wechat.Article{}, nil, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+
+	title := fm.Get("title")
+	if title == "" {
+		if m := firstH1Regex.FindStringSubmatch(body); m != nil {
+			title = strings.TrimSpace(m[1])
+		}
+	}
+	if title == "" {
+		return wechat.Article{}, nil, fmt.Errorf("%w: title is required (front matter \"title\" or a level-1 heading)", ErrInvalidDocument)
+	}
+
+	conv := converter.NewWechatConverterFixed()
+	conv.SetStyles(styles)
+	conv.SetImageOptions(converter.ImageOptions{BaseDir: doc.BaseDir})
+	conv.SetImageUploader(p.Images)
+	conv.SetImageFetcher(doc.Fetcher)
+	content := conv.ConvertMarkdownToWechat(doc.Markdown)
+	warnings := conv.Warnings()
+
+	cover := doc.Cover
+	if cover == "" {
+		cover = fm.Get("cover", "thumb", "image")
+	}
+	if cover == "" {
+		// 没有指定封面时使用正文第一张图片
+		if m := firstImageRegex.FindStringSubmatch(body); m != nil {
+			cover = m[1]
+		}
+	}
+	if cover == "" {
+		return wechat.Article{}, warnings, fmt.Errorf("%w: cover image is required (front matter \"cover\" or an image in the article)", ErrInvalidDocument)
+	}
+
+	data := doc.CoverData
+	if data == nil {
+		if data, err = readImage(cover, doc.BaseDir, doc.Fetcher); err != nil {
+			return wechat.Article{}, warnings, fmt.Errorf("read cover: %w", err)
+		}
+	}
+	thumbID, err := p.Thumbs.Upload(coverName(cover), data)
+	if err != nil {
+		return wechat.Article{}, warnings, fmt.Errorf("upload cover: %w", err)
+	}
+
+	article := wechat.Article{
+		Title:            title,
+		Author:           fm.Get("author"),
+		Digest:           conv.Stats().Digest,
+		Content:          content,
+		ContentSourceURL: fm.Get("source_url", "content_source_url", "source"),
+		ThumbMediaID:     thumbID,
+	}
+	if fm.Get("comment", "open_comment") == "true" {
+		article.NeedOpenComment = 1
+	}
+	return article, warnings, nil
+}
+
+// PublishDraft 渲染并发布一篇文章到草稿箱
+func (p *Publisher) PublishDraft(doc Document) (*Result, error) {
+	return p.PublishBatch([]Document{doc}, false)
+}
+
+// PublishBatch 将多篇文章打包成一个草稿（多图文），dryRun 为 true 时只生成草稿内容不调用 draft/add
+func (p *Publisher) PublishBatch(docs []Document, dryRun bool) (*Result, error) {
+	result := &Result{}
+	if len(docs) > wechat.MaxArticles {
+		return result, fmt.Errorf("%w: %d articles, at most %d allowed", ErrInvalidDocument, len(docs), wechat.MaxArticles)
+	}
+
+	for i, doc := range docs {
+		article, warnings, err := p.BuildArticle(doc)
+		for _, w := range warnings {
+			result.Warnings = append(result.Warnings, ArticleWarning{Article: i, Warning: w})
+		}
+		if err != nil {
+			if len(docs) > 1 {
+				err = fmt.Errorf("article %d: %w", i+1, err)
+			}
+			return result, err
+		}
+		result.Articles = append(result.Articles, article)
+	}
+
+	draft := wechat.Draft{Articles: result.Articles}
+	if err := draft.Validate(); err != nil {
+		return result, fmt.Errorf("%w: %v", ErrInvalidDocument, err)
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	mediaID, err := p.Client.AddDraft(draft)
+	if err != nil {
+		return result, fmt.Errorf("add draft: %w", err)
+	}
+	result.MediaID = mediaID
+	return result, nil
+}
+
+// readImage 读取远程图片或 baseDir 下的本地图片；远程图片按 fetcher 的限制下载，与正文图片相同，
+// 以 / 开头的路径相对于 baseDir，不能读取 baseDir 之外的文件
+func readImage(src, baseDir string, fetcher *converter.ImageFetcher) ([]byte, error) {
+	lower := strings.ToLower(src)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		if fetcher != nil {
+			return fetcher.Fetch(src)
+		}
+		return converter.FetchImage(src)
+	}
+
+	if baseDir == "" {
+		return nil, fmt.Errorf("%w: cannot resolve local image without base directory: %s", ErrInvalidDocument, src)
+	}
+	full := filepath.Join(baseDir, src)
+	rel, err := filepath.Rel(baseDir, full)
+	if err != nil || !fs.ValidPath(filepath.ToSlash(rel)) {
+		return nil, fmt.Errorf("%w: image path escapes base directory: %s", ErrInvalidDocument, src)
+	}
+	return os.ReadFile(full)
+}
+
+// coverName 从封面路径中提取文件名
+func coverName(src string) string {
+	name := path.Base(strings.SplitN(src, "?", 2)[0])
+	if name == "." || name == "/" {
+		return "cover.jpg"
+	}
+	return name
+}
@@ -0,0 +1,182 @@
+package publisher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"bilibili-uploader/internal/wechat"
+)
+
+// fakeWechat 模拟微信接口，按顺序记录调用；内容为 BAD 的封面返回 errcode
+type fakeWechat struct {
+	mu     sync.Mutex
+	calls  []string
+	drafts []wechat.Draft
+}
+
+func (f *fakeWechat) record(call string) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+}
+
+func (f *fakeWechat) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/cgi-bin/token" && r.URL.Query().Get("access_token") != "TOKEN" {
+		fmt.Fprint(w, `{"errcode":40001,"errmsg":"invalid credential"}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/cgi-bin/token":
+		f.record("token")
+		fmt.Fprint(w, `{"access_token":"TOKEN","expires_in":7200}`)
+	case "/cgi-bin/media/uploadimg", "/cgi-bin/material/add_material":
+		file, header, err := r.FormFile("media")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		name := filepath.Base(r.URL.Path) + " " + header.Filename
+		f.record(name)
+		switch {
+		case string(data) == "BAD":
+			fmt.Fprint(w, `{"errcode":40113,"errmsg":"unsupported file type"}`)
+		case strings.HasSuffix(r.URL.Path, "uploadimg"):
+			fmt.Fprintf(w, `{"url":"http://mmbiz.qpic.cn/%s"}`, header.Filename)
+		default:
+			fmt.Fprintf(w, `{"media_id":"thumb-%s","url":"http://mmbiz.qpic.cn/thumb"}`, header.Filename)
+		}
+	case "/cgi-bin/draft/add":
+		var draft wechat.Draft
+		if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.record("draft/add")
+		f.mu.Lock()
+		f.drafts = append(f.drafts, draft)
+		f.mu.Unlock()
+		fmt.Fprint(w, `{"media_id":"DRAFT"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newTestPublisher 创建连接到模拟微信接口的发布器
+func newTestPublisher(t *testing.T) (*Publisher, *fakeWechat) {
+	t.Helper()
+	fake := &fakeWechat{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := wechat.NewClient("appid", "secret")
+	client.BaseURL = server.URL
+	pub, err := New(client, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, fake
+}
+
+// writeFiles 在临时目录中写入文件，返回目录
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// TestPublishBatch 依次获取 token、上传正文图片并改写地址、上传封面、提交草稿
+func TestPublishBatch(t *testing.T) {
+	pub, fake := newTestPublisher(t)
+	dir := writeFiles(t, map[string]string{"a.png": "PNG-A", "b.png": "PNG-B", "cover.png": "COVER"})
+
+	docs := []Document{
+		{Markdown: "---\ntitle: 第一篇\ncover: cover.png\n---\n\n正文第一段。\n\n![图](a.png)\n", BaseDir: dir},
+		// 没有封面时使用第一张图片，同一内容的图片只上传一次
+		{Markdown: "# 第二篇\n\n第二篇正文。\n\n![图](b.png)\n\n![重复](b.png)\n", BaseDir: dir},
+	}
+	result, err := pub.PublishBatch(docs, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantCalls := []string{
+		"token",
+		"uploadimg a.png",
+		"add_material cover.png",
+		"uploadimg b.png",
+		"add_material b.png",
+		"draft/add",
+	}
+	if !reflect.DeepEqual(fake.calls, wantCalls) {
+		t.Fatalf("calls = %q, want %q", fake.calls, wantCalls)
+	}
+	if result.MediaID != "DRAFT" {
+		t.Errorf("media id = %q, want DRAFT", result.MediaID)
+	}
+
+	articles := fake.drafts[0].Articles
+	for i, want := range []struct {
+		title, digest, thumb, image string
+	}{
+		{"第一篇", "正文第一段。", "thumb-cover.png", "http://mmbiz.qpic.cn/a.png"},
+		{"第二篇", "第二篇正文。", "thumb-b.png", "http://mmbiz.qpic.cn/b.png"},
+	} {
+		a := articles[i]
+		if a.Title != want.title || a.ThumbMediaID != want.thumb || !strings.Contains(a.Digest, want.digest) {
+			t.Errorf("article %d = {title %q, digest %q, thumb %q}, want {%q, containing %q, %q}",
+				i+1, a.Title, a.Digest, a.ThumbMediaID, want.title, want.digest, want.thumb)
+		}
+		if !strings.Contains(a.Content, `src="`+want.image+`"`) {
+			t.Errorf("article %d: content lacks uploaded image %s:\n%s", i+1, want.image, a.Content)
+		}
+	}
+}
+
+// TestPublishBatchErrors 出错时报告是第几篇文章，并且不提交草稿
+func TestPublishBatchErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.png": "PNG", "bad.png": "BAD"})
+	ok := Document{Markdown: "# 好文章\n\n![图](a.png)\n", BaseDir: dir}
+
+	for _, tc := range []struct {
+		name    string
+		doc     Document
+		want    string
+		invalid bool
+	}{
+		{"missing title", Document{Markdown: "没有标题\n\n![图](a.png)\n", BaseDir: dir}, "article 2: invalid document: title is required", true},
+		{"missing cover", Document{Markdown: "# 标题\n\n没有图片\n", BaseDir: dir}, "article 2: invalid document: cover image is required", true},
+		{"cover outside base dir", Document{Markdown: "# 标题\n", BaseDir: dir, Cover: "../secret.png"}, "article 2: read cover: invalid document: image path escapes base directory", true},
+		{"cover without base dir", Document{Markdown: "# 标题\n", Cover: "/etc/passwd"}, "article 2: read cover: invalid document: cannot resolve local image without base directory", true},
+		{"cover rejected", Document{Markdown: "# 标题\n", BaseDir: dir, Cover: "bad.png"}, "article 2: upload cover: wechat api error 40113", false},
+	} {
+		pub, fake := newTestPublisher(t)
+		_, err := pub.PublishBatch([]Document{ok, tc.doc}, false)
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want prefix %q", tc.name, err, tc.want)
+			continue
+		}
+		if errors.Is(err, ErrInvalidDocument) != tc.invalid {
+			t.Errorf("%s: errors.Is(err, ErrInvalidDocument) = %v, want %v", tc.name, !tc.invalid, tc.invalid)
+		}
+		if len(fake.drafts) > 0 {
+			t.Errorf("%s: draft was submitted despite the error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package wechat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL 微信公众平台接口地址
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+// tokenRefreshMargin access_token 提前刷新的时间
+const tokenRefreshMargin = 5 * time.Minute
+
+// Client 微信公众平台接口客户端，负责 access_token 的获取与缓存
+type Client struct {
+	AppID      string
+	AppSecret  string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// APIError 微信接口返回的错误
+type APIError struct {
+	Code    int    `json:"errcode"`
+	Message string `json:"errmsg"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wechat api error %d: %s", e.Code, e.Message)
+}
+
+// tokenExpired 判断错误是否由 access_token 失效引起
+func tokenExpired(err error) bool {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	switch apiErr.Code {
+	case 40001, 40014, 42001:
+		return true
+	}
+	return false
+}
+
+// NewClient 创建微信接口客户端
+func NewClient(appID, appSecret string) *Client {
+	return &Client{
+		AppID:      appID,
+		AppSecret:  appSecret,
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// AccessToken 返回缓存的 access_token，过期前自动刷新
+func (c *Client) AccessToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	query := url.Values{}
+	query.Set("grant_type", "client_credential")
+	query.Set("appid", c.AppID)
+	query.Set("secret", c.AppSecret)
+
+	resp, err := c.httpClient().Get(c.endpoint("/cgi-bin/token") + "?" + query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("request access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		APIError
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := decodeResponse(resp, &result); err != nil {
+		return "", err
+	}
+	if result.Code != 0 {
+		return "", &result.APIError
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("empty access token in response")
+	}
+
+	c.token = result.AccessToken
+	c.expiresAt = time.Now().Add(time.Duration(result.ExpiresIn)*time.Second - tokenRefreshMargin)
+	return c.token, nil
+}
+
+// invalidateToken 清除缓存的 access_token
+func (c *Client) invalidateToken() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
+
+// Upload 通过 media/uploadimg 上传图文消息内的图片，返回 mmbiz.qpic.cn 地址
+func (c *Client) Upload(name string, data []byte) (string, error) {
+	var result struct {
+		APIError
+		URL string `json:"url"`
+	}
+	if err := c.postFile("/cgi-bin/media/uploadimg", nil, name, data, &result); err != nil {
+		return "", err
+	}
+	if result.URL == "" {
+		return "", fmt.Errorf("uploadimg returned empty url")
+	}
+	return result.URL, nil
+}
+
+// IsHosted 判断图片是否已托管在微信服务器上
+func (c *Client) IsHosted(imageURL string) bool {
+	u, err := url.Parse(imageURL)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(u.Hostname(), "qpic.cn")
+}
+
+// withToken 携带 access_token 调用接口，token 失效时刷新后重试一次
+func (c *Client) withToken(call func(token string) error) error {
+	token, err := c.AccessToken()
+	if err != nil {
+		return err
+	}
+	err = call(token)
+	if tokenExpired(err) {
+		c.invalidateToken()
+		if token, err = c.AccessToken(); err != nil {
+			return err
+		}
+		err = call(token)
+	}
+	return err
+}
+
+// postFile 以 multipart 方式上传文件
+func (c *Client) postFile(path string, query url.Values, name string, data []byte, out apiResult) error {
+	return c.withToken(func(token string) error {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("media", name)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		return c.post(path, query, token, writer.FormDataContentType(), &body, out)
+	})
+}
+
+// postJSON 以 JSON 请求体调用接口
+func (c *Client) postJSON(path string, payload interface{}, out apiResult) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	// 正文是 HTML，避免被转义成 \u003c
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return err
+	}
+	data := body.Bytes()
+
+	return c.withToken(func(token string) error {
+		return c.post(path, nil, token, "application/json", bytes.NewReader(data), out)
+	})
+}
+
+// post 发送请求并解析结果
+func (c *Client) post(path string, query url.Values, token, contentType string, body io.Reader, out apiResult) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("access_token", token)
+
+	resp, err := c.httpClient().Post(c.endpoint(path)+"?"+q.Encode(), contentType, body)
+	if err != nil {
+		return fmt.Errorf("post %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	// 重试时复用同一个结果对象，先清除上一次的错误码
+	*out.apiError() = APIError{}
+	if err := decodeResponse(resp, out); err != nil {
+		return err
+	}
+	if apiErr := out.apiError(); apiErr.Code != 0 {
+		return apiErr
+	}
+	return nil
+}
+
+// apiResult 内嵌 APIError 的接口返回结构
+type apiResult interface {
+	apiError() *APIError
+}
+
+func (e *APIError) apiError() *APIError {
+	return e
+}
+
+// endpoint 拼接接口地址
+func (c *Client) endpoint(path string) string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// decodeResponse 解析接口返回的 JSON
+func decodeResponse(resp *http.Response, out interface{}) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
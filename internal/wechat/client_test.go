@@ -0,0 +1,257 @@
+package wechat
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// fakeServer 模拟微信接口：每次获取 token 返回新的 TOKEN-n，handlers 按路径返回上传接口的结果
+type fakeServer struct {
+	t         *testing.T
+	expiresIn int
+	handlers  map[string]func(token, name string, data []byte) string
+
+	mu     sync.Mutex
+	tokens int
+	calls  []string
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/cgi-bin/token" {
+		q := r.URL.Query()
+		if q.Get("grant_type") != "client_credential" || q.Get("appid") != "appid" || q.Get("secret") != "secret" {
+			fmt.Fprint(w, `{"errcode":40013,"errmsg":"invalid appid"}`)
+			return
+		}
+		f.tokens++
+		fmt.Fprintf(w, `{"access_token":"TOKEN-%d","expires_in":%d}`, f.tokens, f.expiresIn)
+		return
+	}
+
+	handler, ok := f.handlers[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	file, header, err := r.FormFile("media")
+	if err != nil {
+		f.t.Errorf("%s: %v", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, _ := io.ReadAll(file)
+	token := r.URL.Query().Get("access_token")
+	if r.URL.Path == "/cgi-bin/material/add_material" && r.URL.Query().Get("type") != "image" {
+		f.t.Errorf("add_material: type = %q, want image", r.URL.Query().Get("type"))
+	}
+	f.calls = append(f.calls, r.URL.Path+" "+token)
+	fmt.Fprint(w, handler(token, header.Filename, data))
+}
+
+// newFakeClient 创建连接到模拟接口的客户端
+func newFakeClient(t *testing.T, f *fakeServer) *Client {
+	t.Helper()
+	f.t = t
+	if f.expiresIn == 0 {
+		f.expiresIn = 7200
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	client := NewClient("appid", "secret")
+	client.BaseURL = server.URL
+	return client
+}
+
+// TestAccessTokenCache access_token 在有效期内复用，过期后重新获取
+func TestAccessTokenCache(t *testing.T) {
+	f := &fakeServer{}
+	client := newFakeClient(t, f)
+
+	for i := 0; i < 3; i++ {
+		token, err := client.AccessToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "TOKEN-1" {
+			t.Fatalf("call %d: token = %q, want cached TOKEN-1", i+1, token)
+		}
+	}
+	if want := time.Now().Add(7200*time.Second - tokenRefreshMargin); client.expiresAt.After(want) || client.expiresAt.Before(want.Add(-time.Minute)) {
+		t.Errorf("expiresAt = %v, want about %v", client.expiresAt, want)
+	}
+
+	client.expiresAt = time.Now().Add(-time.Second)
+	if token, err := client.AccessToken(); err != nil || token != "TOKEN-2" {
+		t.Errorf("after expiry: token = %q, %v, want TOKEN-2", token, err)
+	}
+
+	// 有效期不超过提前刷新的时间时每次都重新获取
+	f = &fakeServer{expiresIn: int(tokenRefreshMargin / time.Second)}
+	client = newFakeClient(t, f)
+	client.AccessToken()
+	if token, _ := client.AccessToken(); token != "TOKEN-2" {
+		t.Errorf("short-lived token: token = %q, want TOKEN-2", token)
+	}
+
+	// 凭据错误时返回接口错误
+	client.AppSecret = "wrong"
+	client.expiresAt = time.Time{}
+	var apiErr *APIError
+	if _, err := client.AccessToken(); !errors.As(err, &apiErr) || apiErr.Code != 40013 {
+		t.Errorf("wrong secret: error = %v, want errcode 40013", err)
+	}
+}
+
+// TestTokenRetry 上传返回 40001、42001 时刷新 token 重试一次，其他错误码和再次失效不重试
+func TestTokenRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// codes 依次返回的错误码，0 表示成功
+		codes    []int
+		wantErr  int
+		wantCall []string
+	}{
+		{"invalid credential", []int{40001, 0}, 0, []string{"TOKEN-1", "TOKEN-2"}},
+		{"token expired", []int{42001, 0}, 0, []string{"TOKEN-1", "TOKEN-2"}},
+		{"still invalid", []int{40001, 40001}, 40001, []string{"TOKEN-1", "TOKEN-2"}},
+		{"other error", []int{45009, 0}, 45009, []string{"TOKEN-1"}},
+	} {
+		n := 0
+		f := &fakeServer{handlers: map[string]func(string, string, []byte) string{
+			"/cgi-bin/media/uploadimg": func(token, name string, data []byte) string {
+				code := tc.codes[n]
+				n++
+				if code != 0 {
+					return fmt.Sprintf(`{"errcode":%d,"errmsg":"error"}`, code)
+				}
+				return `{"url":"http://mmbiz.qpic.cn/a"}`
+			},
+		}}
+		client := newFakeClient(t, f)
+
+		_, err := client.Upload("a.png", []byte("PNG"))
+		var apiErr *APIError
+		switch {
+		case tc.wantErr == 0 && err != nil:
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		case tc.wantErr != 0 && (!errors.As(err, &apiErr) || apiErr.Code != tc.wantErr):
+			t.Errorf("%s: error = %v, want errcode %d", tc.name, err, tc.wantErr)
+		}
+
+		var tokens []string
+		for _, call := range f.calls {
+			tokens = append(tokens, strings.Fields(call)[1])
+		}
+		if strings.Join(tokens, ",") != strings.Join(tc.wantCall, ",") {
+			t.Errorf("%s: uploads with tokens %v, want %v", tc.name, tokens, tc.wantCall)
+		}
+	}
+}
+
+// TestUploadResponses uploadimg 返回图片地址，add_material 返回 media_id，错误码和空结果都报错
+func TestUploadResponses(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		response string
+		material bool
+		want     string
+		wantErr  string
+	}{
+		{"uploadimg", `{"url":"http://mmbiz.qpic.cn/mmbiz_png/a/0"}`, false, "http://mmbiz.qpic.cn/mmbiz_png/a/0", ""},
+		{"uploadimg errcode", `{"errcode":40005,"errmsg":"invalid file type"}`, false, "", "wechat api error 40005: invalid file type"},
+		{"uploadimg empty url", `{}`, false, "", "uploadimg returned empty url"},
+		{"add_material", `{"media_id":"MEDIA","url":"http://mmbiz.qpic.cn/thumb"}`, true, "MEDIA", ""},
+		{"add_material errcode", `{"errcode":40009,"errmsg":"invalid image size"}`, true, "", "wechat api error 40009: invalid image size"},
+		{"add_material empty media_id", `{"url":"http://mmbiz.qpic.cn/thumb"}`, true, "", "add_material returned empty media_id"},
+	} {
+		var gotName, gotData string
+		respond := func(token, name string, data []byte) string {
+			gotName, gotData = name, string(data)
+			return tc.response
+		}
+		f := &fakeServer{handlers: map[string]func(string, string, []byte) string{
+			"/cgi-bin/media/uploadimg":       respond,
+			"/cgi-bin/material/add_material": respond,
+		}}
+		client := newFakeClient(t, f)
+
+		var got string
+		var err error
+		if tc.material {
+			got, err = ThumbUploader{Client: client}.Upload("cover.png", []byte("PNG"))
+		} else {
+			got, err = client.Upload("cover.png", []byte("PNG"))
+		}
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("%s: error = %v, want %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+		if gotName != "cover.png" || gotData != "PNG" {
+			t.Errorf("%s: uploaded %q with %q, want cover.png with PNG", tc.name, gotName, gotData)
+		}
+	}
+}
+
+// TestCachingUploader 相同内容只上传一次，缓存文件在重新创建后仍然生效，微信图片地址不再上传
+func TestCachingUploader(t *testing.T) {
+	n := 0
+	f := &fakeServer{handlers: map[string]func(string, string, []byte) string{
+		"/cgi-bin/media/uploadimg": func(token, name string, data []byte) string {
+			n++
+			return fmt.Sprintf(`{"url":"http://mmbiz.qpic.cn/%d"}`, n)
+		},
+	}}
+	client := newFakeClient(t, f)
+	cachePath := filepath.Join(t.TempDir(), "images.json")
+
+	uploader, err := converter.NewCachingUploader(client, cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, data, want string
+	}{
+		{"a.png", "A", "http://mmbiz.qpic.cn/1"},
+		{"a.png", "A", "http://mmbiz.qpic.cn/1"},
+		// 按内容哈希缓存，文件名不同也命中
+		{"copy.png", "A", "http://mmbiz.qpic.cn/1"},
+		{"b.png", "B", "http://mmbiz.qpic.cn/2"},
+	} {
+		if got, err := uploader.Upload(tc.name, []byte(tc.data)); err != nil || got != tc.want {
+			t.Errorf("upload %s: got %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("uploadimg called %d times, want 2", len(f.calls))
+	}
+
+	reloaded, err := converter.NewCachingUploader(client, cachePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reloaded.Upload("b.png", []byte("B")); got != "http://mmbiz.qpic.cn/2" || len(f.calls) != 2 {
+		t.Errorf("reloaded cache: got %q after %d uploads, want cached http://mmbiz.qpic.cn/2", got, len(f.calls))
+	}
+
+	if !reloaded.IsHosted("http://mmbiz.qpic.cn/mmbiz_png/x/0") || reloaded.IsHosted("https://example.com/a.png") {
+		t.Error("IsHosted should only accept qpic.cn images")
+	}
+}
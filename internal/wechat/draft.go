@@ -0,0 +1,133 @@
+package wechat
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Article 草稿箱中的一篇图文
+type Article struct {
+	Title              string `json:"title"`
+	Author             string `json:"author,omitempty"`
+	Digest             string `json:"digest,omitempty"`
+	Content            string `json:"content"`
+	ContentSourceURL   string `json:"content_source_url,omitempty"`
+	ThumbMediaID       string `json:"thumb_media_id"`
+	NeedOpenComment    int    `json:"need_open_comment,omitempty"`
+	OnlyFansCanComment int    `json:"only_fans_can_comment,omitempty"`
+}
+
+// Draft draft/add 请求体
+type Draft struct {
+	Articles []Article `json:"articles"`
+}
+
+// AddDraft 新建草稿，返回草稿的 media_id
+func (c *Client) AddDraft(draft Draft) (string, error) {
+	var result struct {
+		APIError
+		MediaID string `json:"media_id"`
+	}
+	if err := c.postJSON("/cgi-bin/draft/add", draft, &result); err != nil {
+		return "", err
+	}
+	if result.MediaID == "" {
+		return "", fmt.Errorf("draft/add returned empty media_id")
+	}
+	return result.MediaID, nil
+}
+
+// UploadMaterial 上传永久图片素材（用作封面），返回 media_id 和图片地址
+func (c *Client) UploadMaterial(name string, data []byte) (string, string, error) {
+	var result struct {
+		APIError
+		MediaID string `json:"media_id"`
+		URL     string `json:"url"`
+	}
+	query := url.Values{}
+	query.Set("type", "image")
+	if err := c.postFile("/cgi-bin/material/add_material", query, name, data, &result); err != nil {
+		return "", "", err
+	}
+	if result.MediaID == "" {
+		return "", "", fmt.Errorf("add_material returned empty media_id")
+	}
+	return result.MediaID, result.URL, nil
+}
+
+// ThumbUploader 将封面上传为永久素材，Upload 返回 media_id，可用 CachingUploader 包装避免重复上传
+type ThumbUploader struct {
+	Client *Client
+}
+
+// Upload 上传封面图片，返回 media_id
+func (u ThumbUploader) Upload(name string, data []byte) (string, error) {
+	mediaID, _, err := u.Client.UploadMaterial(name, data)
+	return mediaID, err
+}
+
+// 草稿图文的限制，正文字数按去掉 HTML 标签后的文本计算
+const (
+	MaxArticles      = 8
+	MaxTitleLength   = 64
+	MaxAuthorLength  = 16
+	MaxDigestLength  = 120
+	MaxContentLength = 20000
+	MaxContentBytes  = 1 << 20
+)
+
+// htmlTagRegex 统计正文字数时去掉标签
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// ValidationError 草稿不满足微信限制时返回，列出所有问题
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid draft: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate 检查图文数量以及标题、作者、摘要和正文长度
+func (d Draft) Validate() error {
+	var problems []string
+	if len(d.Articles) == 0 {
+		problems = append(problems, "draft has no articles")
+	}
+	if len(d.Articles) > MaxArticles {
+		problems = append(problems, fmt.Sprintf("draft has %d articles, at most %d allowed", len(d.Articles), MaxArticles))
+	}
+
+	for i, a := range d.Articles {
+		prefix := fmt.Sprintf("article %d", i+1)
+		if a.Title == "" {
+			problems = append(problems, prefix+": title is empty")
+		}
+		if n := utf8.RuneCountInString(a.Title); n > MaxTitleLength {
+			problems = append(problems, fmt.Sprintf("%s: title has %d characters, at most %d allowed", prefix, n, MaxTitleLength))
+		}
+		if n := utf8.RuneCountInString(a.Author); n > MaxAuthorLength {
+			problems = append(problems, fmt.Sprintf("%s: author has %d characters, at most %d allowed", prefix, n, MaxAuthorLength))
+		}
+		if n := utf8.RuneCountInString(a.Digest); n > MaxDigestLength {
+			problems = append(problems, fmt.Sprintf("%s: digest has %d characters, at most %d allowed", prefix, n, MaxDigestLength))
+		}
+		if n := utf8.RuneCountInString(htmlTagRegex.ReplaceAllString(a.Content, "")); n >= MaxContentLength {
+			problems = append(problems, fmt.Sprintf("%s: content has %d characters of text, must be fewer than %d", prefix, n, MaxContentLength))
+		}
+		if n := len(a.Content); n >= MaxContentBytes {
+			problems = append(problems, fmt.Sprintf("%s: content is %d bytes, must be smaller than %d", prefix, n, MaxContentBytes))
+		}
+		if a.ThumbMediaID == "" {
+			problems = append(problems, prefix+": cover (thumb_media_id) is missing")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
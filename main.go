@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
@@ -13,20 +14,64 @@ import (
 
 type ConvertRequest struct {
 	Markdown string `json:"markdown"`
+	// Platform 目标平台：wechat（默认）、zhihu、juejin、toutiao、medium
+	Platform string `json:"platform,omitempty"`
+	// TableMode 表格输出方式：table、scroll、ascii、card，为空时使用平台默认值
+	TableMode converter.TableMode `json:"table_mode,omitempty"`
+	// TableRules 单元格样式规则
+	TableRules []converter.CellRule `json:"table_rules,omitempty"`
+	// TableSpans 启用合并单元格语法
+	TableSpans bool `json:"table_spans,omitempty"`
+	// Theme 主题名称，为空时使用平台默认主题
+	Theme string `json:"theme,omitempty"`
+	// Links 链接处理方式：footnote、inline、text，为空时使用平台默认方式
+	Links string `json:"links,omitempty"`
+	// Format 输出格式：html（默认）、text、ast
+	Format string `json:"format,omitempty"`
+	// Thread format 为 text 时按字数拆分为多段
+	Thread *converter.ThreadOptions `json:"thread,omitempty"`
 }
 
 type ConvertResponse struct {
-	HTML    string `json:"html"`
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"`
+	HTML    string                 `json:"html"`
+	Text    string                 `json:"text,omitempty"`
+	Thread  []string               `json:"thread,omitempty"`
+	AST     *converter.ASTDocument `json:"ast,omitempty"`
+	Stats   *converter.Stats       `json:"stats,omitempty"`
+	Success bool                   `json:"success"`
+	Error   string                 `json:"error,omitempty"`
 }
 
 func main() {
-	// 创建转换器
-	conv := converter.NewWechatConverterFixed()
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 子命令，没有参数时启动服务
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(cfg, os.Args[1:]))
+	}
+	os.Exit(runServe(cfg, nil))
+}
+
+// runServe 启动 HTTP 服务
+func runServe(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.String("port", cfg.Port, "port to listen on")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: serve [-port 8080]")
+		return 2
+	}
+
+	// 草稿发布器，未配置凭据时接口返回错误
+	pub, pubErr := newPublisher(cfg)
 
 	// 静态文件服务
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static/"))))
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.StaticDir))))
 
 	// 主页
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -50,26 +95,109 @@ func main() {
 			return
 		}
 
-		// 转换Markdown
-		html := conv.ConvertMarkdownToWechat(req.Markdown)
-
-		response := ConvertResponse{
-			HTML:    html,
-			Success: true,
+		response, status, err := convertRequest(cfg, req)
+		if err != nil {
+			sendErrorResponse(w, err.Error(), status)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// 带选项校验和结构化结果的转换接口
+	http.HandleFunc("/api/v1/convert", handleConvertV1(cfg))
+
+	// 流式转换：提交编辑，通过 SSE 接收变化的块
+	stream := handleStream(cfg)
+	http.HandleFunc("/api/v1/stream", stream)
+	http.HandleFunc("/api/v1/stream/", stream)
+
+	// 发布到草稿箱
+	http.HandleFunc("/api/publish/draft", handlePublishDraft(cfg, pub, pubErr))
+	http.HandleFunc("/api/publish/batch", handlePublishBatch(cfg, pub, pubErr))
+
+	// 导入已发布文章的 HTML
+	http.HandleFunc("/api/import", handleImport)
+	http.HandleFunc("/api/import/docx", handleImportDOCX(cfg))
+
+	// 导出独立 HTML 文档
+	http.HandleFunc("/api/export/html", handleExportHTML(cfg))
+	http.HandleFunc("/api/export/epub", handleExportEPUB(cfg))
+
+	// Markdown 格式化
+	http.HandleFunc("/api/format", handleFormat)
+
+	fmt.Printf("Server starting on http://localhost:%s\n", *port)
+	fmt.Printf("Open your browser and visit: http://localhost:%s\n", *port)
+	if err := http.ListenAndServe(":"+*port, nil); err != nil {
+		log.Print(err)
+		return 1
 	}
+	return 0
+}
 
-	fmt.Printf("Server starting on http://localhost:%s\n", port)
-	fmt.Printf("Open your browser and visit: http://localhost:%s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+// convertRequest 按请求的输出格式转换 Markdown，出错时返回对应的 HTTP 状态码
+func convertRequest(cfg *Config, req ConvertRequest) (ConvertResponse, int, error) {
+	switch req.Format {
+	case "", "html":
+		// 转换器保存单次转换的状态，每个请求单独创建
+		conv, status, err := newRequestConverter(cfg, req)
+		if err != nil {
+			return ConvertResponse{}, status, err
+		}
+		html := conv.ConvertMarkdownToWechat(req.Markdown)
+		stats := conv.Stats()
+		return ConvertResponse{HTML: html, Stats: &stats, Success: true}, http.StatusOK, nil
+	case "text":
+		text := converter.RenderText(req.Markdown)
+		stats := converter.ComputeStats(converter.Parse(req.Markdown))
+		response := ConvertResponse{Text: text, Stats: &stats, Success: true}
+		if req.Thread != nil && req.Thread.Limit > 0 {
+			response.Thread = converter.SplitThread(text, *req.Thread)
+		}
+		return response, http.StatusOK, nil
+	case "ast":
+		stats := converter.ComputeStats(converter.Parse(req.Markdown))
+		return ConvertResponse{AST: converter.BuildAST(req.Markdown), Stats: &stats, Success: true}, http.StatusOK, nil
+	default:
+		return ConvertResponse{}, http.StatusBadRequest, fmt.Errorf("Unknown format: %s", req.Format)
+	}
+}
+
+// newRequestConverter 按请求中的平台和表格选项创建转换器，出错时返回对应的 HTTP 状态码
+func newRequestConverter(cfg *Config, req ConvertRequest) (*converter.WechatConverter, int, error) {
+	profile, ok := converter.GetProfile(req.Platform)
+	if !ok {
+		return nil, http.StatusBadRequest, fmt.Errorf("Unknown platform: %s", req.Platform)
+	}
+	switch policy := converter.LinkPolicy(req.Links); policy {
+	case "":
+	case converter.LinkFootnote, converter.LinkInline, converter.LinkText:
+		profile.LinkPolicy = policy
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("Unknown link mode: %s", req.Links)
+	}
+	theme := req.Theme
+	if theme == "" {
+		theme = profile.DefaultTheme
+	}
+	styles, err := converter.LoadTheme(cfg.ThemeDir, theme)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if req.Theme != "" {
+			status = http.StatusBadRequest
+		}
+		return nil, status, err
+	}
+
+	conv := converter.NewWechatConverterFixed()
+	conv.SetProfile(profile)
+	conv.SetStyles(styles)
+	if err := conv.SetTableOptions(converter.TableOptions{Mode: req.TableMode, Rules: req.TableRules, Spans: req.TableSpans}); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return conv, http.StatusOK, nil
 }
 
 func serveHomePage(w http.ResponseWriter, r *http.Request) {
@@ -434,6 +562,7 @@ $$\\int_{-\\infty}^{\\infty} e^{-x^2} dx = \\sqrt{\\pi}$$
     
     <!-- WenYan 转换引擎脚本 - 确保在所有依赖库之后加载 -->
     <script src="static/main.js"></script>
+    <script src="static/stream.js"></script>
     
     <script>
         const markdownInput = document.getElementById('markdown-input');
@@ -507,12 +636,36 @@ $$\\int_{-\\infty}^{\\infty} e^{-x^2} dx = \\sqrt{\\pi}$$
                 const response = await fetch('static/themes/' + themeName + '.css');
                 const css = await response.text();
                 setCustomTheme(css);
+                await openStream();
                 convertMarkdown();
             } catch (error) {
                 console.error('Failed to load theme:', error);
             }
         }
         
+        // 流式转换会话：只提交编辑的差异，只替换变化的块；无法创建会话时整篇请求 /api/v1/convert
+        let stream = null;
+        async function openStream() {
+            stream?.close();
+            stream = null;
+            const container = document.createElement('section');
+            const session = new StreamSession(container, { theme: currentTheme });
+            session.onrender = () => {
+                if (stream === session && markdownInput.value.trim()) {
+                    previewOutput.replaceChildren(container);
+                    syncPreview();
+                }
+            };
+            try {
+                await session.open(markdownInput.value);
+                stream = session;
+            } catch (error) {
+                console.warn('Stream unavailable:', error);
+                session.close();
+            }
+        }
+        window.addEventListener('pagehide', () => stream?.close());
+        
         // 切换主题
         function changeTheme() {
             const select = document.getElementById('theme-select');
@@ -520,8 +673,10 @@ $$\\int_{-\\infty}^{\\infty} e^{-x^2} dx = \\sqrt{\\pi}$$
             loadTheme(currentTheme);
         }
         
-        // 转换 Markdown
-        function convertMarkdown() {
+        // 转换 Markdown：优先通过流式会话增量转换，否则由 /api/v1/convert 按所选主题转换；
+        // 块级元素都带 data-line 源码行号，预览跟随光标所在行
+        let convertSeq = 0;
+        async function convertMarkdown() {
             const markdown = markdownInput.value;
             
             if (!markdown.trim()) {
@@ -529,36 +684,51 @@ $$\\int_{-\\infty}^{\\infty} e^{-x^2} dx = \\sqrt{\\pi}$$
                 return;
             }
             
+            const seq = ++convertSeq;
+            if (stream) {
+                stream.update(markdown).catch(error => {
+                    // 会话已过期或被删除时改为整篇转换
+                    console.warn('Stream update failed:', error);
+                    stream?.close();
+                    stream = null;
+                    convertMarkdown();
+                });
+                return;
+            }
             try {
-                // 使用 WenYan 引擎转换内容
-                if (typeof setContent === 'function') {
-                    setContent(markdown);
-                    
-                    // 等待 MathJax 渲染完成后显示内容
-                    setTimeout(() => {
-                        const wenyanElement = document.getElementById('wenyan');
-                        if (wenyanElement) {
-                            // 克隆内容到预览区域，而不是移动原始元素
-                            previewOutput.innerHTML = wenyanElement.innerHTML;
-                        } else {
-                            // 如果 wenyan 元素不存在，直接获取转换后的HTML
-                            const convertedHtml = getContentForGzh();
-                            if (convertedHtml) {
-                                previewOutput.innerHTML = convertedHtml;
-                            } else {
-                                previewOutput.innerHTML = '<p style="color: #dc3545;">转换失败: 无法获取转换后的内容</p>';
-                            }
-                        }
-                    }, 200);
-                } else {
-                    previewOutput.innerHTML = '<p style="color: #dc3545;">转换引擎未加载</p>';
+                const response = await fetch('/api/v1/convert', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ markdown: markdown, theme: currentTheme, source_lines: true })
+                });
+                const data = await response.json();
+                // 较早的请求晚于新请求返回时丢弃
+                if (seq !== convertSeq) {
+                    return;
                 }
+                if (!data.success) {
+                    throw new Error(data.error);
+                }
+                setHTMLContent(data.html);
+                // 克隆内容到预览区域，而不是移动原始元素
+                previewOutput.innerHTML = document.getElementById('wenyan').innerHTML;
+                syncPreview();
             } catch (error) {
                 console.error('Conversion error:', error);
-                previewOutput.innerHTML = '<p style="color: #dc3545;">转换失败: ' + error.message + '</p>';
+                const message = document.createElement('p');
+                message.style.color = '#dc3545';
+                message.textContent = '转换失败: ' + error.message;
+                previewOutput.replaceChildren(message);
             }
         }
         
+        // 预览滚动到光标所在源码行对应的块
+        function syncPreview() {
+            const line = markdownInput.value.slice(0, markdownInput.selectionStart).split('\n').length;
+            scrollToLine(line, previewOutput);
+        }
+        ['click', 'keyup', 'select'].forEach(type => markdownInput.addEventListener(type, syncPreview));
+        
         // 复制到剪贴板
         function copyToClipboard() {
             try {
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"bilibili-uploader/internal/converter"
+	"bilibili-uploader/internal/publisher"
+	"bilibili-uploader/internal/wechat"
+)
+
+// PublishRequest 发布草稿请求
+type PublishRequest struct {
+	Markdown string `json:"markdown"`
+	Theme    string `json:"theme,omitempty"`
+	Cover    string `json:"cover,omitempty"`
+}
+
+// BatchPublishRequest 多图文草稿请求，最多 8 篇
+type BatchPublishRequest struct {
+	Articles []PublishRequest `json:"articles"`
+	// DryRun 只返回 draft/add 的请求内容，不创建草稿
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// PublishResponse 发布草稿响应
+type PublishResponse struct {
+	MediaID  string                     `json:"media_id,omitempty"`
+	Draft    *wechat.Draft              `json:"draft,omitempty"`
+	Warnings []publisher.ArticleWarning `json:"warnings,omitempty"`
+	Success  bool                       `json:"success"`
+	Error    string                     `json:"error,omitempty"`
+}
+
+// newPublisher 根据配置创建发布器
+func newPublisher(cfg *Config) (*publisher.Publisher, error) {
+	if cfg.Wechat.AppID == "" || cfg.Wechat.AppSecret == "" {
+		return nil, fmt.Errorf("wechat credentials not configured (set wechat.app_id/app_secret in config.json or WECHAT_APP_ID/WECHAT_APP_SECRET)")
+	}
+	client := wechat.NewClient(cfg.Wechat.AppID, cfg.Wechat.AppSecret)
+	if cfg.Wechat.BaseURL != "" {
+		client.BaseURL = cfg.Wechat.BaseURL
+	}
+	return publisher.New(client, cfg.ThemeDir, cfg.Wechat.CacheDir)
+}
+
+// handlePublishDraft 处理 /api/publish/draft，远程图片和封面按 requestFetcher 的限制下载
+func handlePublishDraft(cfg *Config, pub *publisher.Publisher, pubErr error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req PublishRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendPublishResponse(w, PublishResponse{Error: "Invalid JSON"}, http.StatusBadRequest)
+			return
+		}
+
+		publishDocuments(w, pub, pubErr, []PublishRequest{req}, false, requestFetcher(cfg))
+	}
+}
+
+// handlePublishBatch 处理 /api/publish/batch，全部文章的远程图片和封面共用一个请求的下载额度
+func handlePublishBatch(cfg *Config, pub *publisher.Publisher, pubErr error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req BatchPublishRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendPublishResponse(w, PublishResponse{Error: "Invalid JSON"}, http.StatusBadRequest)
+			return
+		}
+
+		publishDocuments(w, pub, pubErr, req.Articles, req.DryRun, requestFetcher(cfg))
+	}
+}
+
+// publishDocuments 发布一篇或多篇文章并写回响应，各篇文章共用 fetcher 的下载额度
+func publishDocuments(w http.ResponseWriter, pub *publisher.Publisher, pubErr error, reqs []PublishRequest, dryRun bool, fetcher *converter.ImageFetcher) {
+	if pub == nil {
+		sendPublishResponse(w, PublishResponse{Error: pubErr.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+
+	docs := make([]publisher.Document, 0, len(reqs))
+	for _, req := range reqs {
+		docs = append(docs, publisher.Document{
+			Markdown: req.Markdown,
+			Theme:    req.Theme,
+			Cover:    req.Cover,
+			Fetcher:  fetcher,
+		})
+	}
+
+	result, err := pub.PublishBatch(docs, dryRun)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, publisher.ErrInvalidDocument) || errors.Is(err, converter.ErrFetchBlocked) {
+			status = http.StatusBadRequest
+		}
+		sendPublishResponse(w, PublishResponse{Warnings: result.Warnings, Error: err.Error()}, status)
+		return
+	}
+
+	resp := PublishResponse{
+		MediaID:  result.MediaID,
+		Warnings: result.Warnings,
+		Success:  true,
+	}
+	if dryRun {
+		resp.Draft = &wechat.Draft{Articles: result.Articles}
+	}
+	sendPublishResponse(w, resp, http.StatusOK)
+}
+
+func sendPublishResponse(w http.ResponseWriter, resp PublishResponse, statusCode int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// runPublish 命令行发布：publish [-theme name] [-cover path] [-dry-run] article.md [more.md ...]
+func runPublish(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	theme := fs.String("theme", "", "theme name for every article (default: front matter theme or built-in default)")
+	cover := fs.String("cover", "", "cover image path or URL, single article only (default: front matter cover or first image)")
+	dryRun := fs.Bool("dry-run", false, "print the draft/add payload instead of creating the draft")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 || (*cover != "" && fs.NArg() > 1) {
+		fmt.Fprintln(os.Stderr, "usage: publish [-theme name] [-cover path] [-dry-run] article.md [more.md ...]")
+		return 2
+	}
+
+	// 命令行指定的本地封面可以在文章目录之外，直接读取内容
+	var coverData []byte
+	if *cover != "" && !strings.HasPrefix(*cover, "http://") && !strings.HasPrefix(*cover, "https://") {
+		data, err := os.ReadFile(*cover)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		coverData = data
+	}
+
+	files := fs.Args()
+	docs := make([]publisher.Document, 0, len(files))
+	for _, file := range files {
+		markdown, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		docs = append(docs, publisher.Document{
+			Markdown:  string(markdown),
+			Theme:     *theme,
+			BaseDir:   filepath.Dir(file),
+			Cover:     *cover,
+			CoverData: coverData,
+		})
+	}
+
+	pub, err := newPublisher(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	result, err := pub.PublishBatch(docs, *dryRun)
+	for _, w := range result.Warnings {
+		fmt.Fprintf(os.Stderr, "%s:%d: warning: %s\n", files[w.Article], w.Line, w.Message)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *dryRun {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(wechat.Draft{Articles: result.Articles})
+		return 0
+	}
+	fmt.Println(result.MediaID)
+	return 0
+}
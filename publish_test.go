@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bilibili-uploader/internal/publisher"
+	"bilibili-uploader/internal/wechat"
+)
+
+// publishServer 模拟的微信接口和图片服务：calls 按顺序记录微信接口调用，files 为图片下载次数
+type publishServer struct {
+	*httptest.Server
+	pub   *publisher.Publisher
+	calls []string
+	files int
+	draft wechat.Draft
+}
+
+// newPublishServer 启动模拟的微信接口和图片服务，/files/ 下的图片内容为 "IMAGE 文件名"
+func newPublishServer(t *testing.T) *publishServer {
+	t.Helper()
+	s := &publishServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		s.files++
+		fmt.Fprint(w, "IMAGE "+path.Base(r.URL.Path))
+	})
+	mux.HandleFunc("/cgi-bin/token", func(w http.ResponseWriter, r *http.Request) {
+		s.calls = append(s.calls, "token")
+		fmt.Fprint(w, `{"access_token":"TOKEN","expires_in":7200}`)
+	})
+	mux.HandleFunc("/cgi-bin/media/uploadimg", func(w http.ResponseWriter, r *http.Request) {
+		_, header, _ := r.FormFile("media")
+		s.calls = append(s.calls, "uploadimg "+header.Filename)
+		fmt.Fprintf(w, `{"url":"http://mmbiz.qpic.cn/%s"}`, header.Filename)
+	})
+	mux.HandleFunc("/cgi-bin/material/add_material", func(w http.ResponseWriter, r *http.Request) {
+		_, header, _ := r.FormFile("media")
+		s.calls = append(s.calls, "add_material "+header.Filename)
+		fmt.Fprint(w, `{"media_id":"THUMB"}`)
+	})
+	mux.HandleFunc("/cgi-bin/draft/add", func(w http.ResponseWriter, r *http.Request) {
+		s.calls = append(s.calls, "draft/add")
+		json.NewDecoder(r.Body).Decode(&s.draft)
+		fmt.Fprint(w, `{"media_id":"DRAFT"}`)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	client := wechat.NewClient("appid", "secret")
+	client.BaseURL = s.URL
+	pub, err := publisher.New(client, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.pub = pub
+	return s
+}
+
+// TestHandlePublishDraft /api/publish/draft 通过模拟的微信接口上传远程图片和封面并创建草稿
+func TestHandlePublishDraft(t *testing.T) {
+	server := newPublishServer(t)
+	pub := server.pub
+	// 测试服务在回环地址上，只能通过 image_hosts 允许
+	handler := handlePublishDraft(&Config{ImageHosts: []string{"127.0.0.1"}}, pub, nil)
+
+	post := func(req PublishRequest) (int, PublishResponse) {
+		return postPublish(t, handler, "/api/publish/draft", req)
+	}
+
+	code, resp := post(PublishRequest{
+		Markdown: "# 标题\n\n正文。\n\n![图](" + server.URL + "/files/a.png)\n",
+		Cover:    server.URL + "/files/cover.png",
+	})
+	if code != http.StatusOK || !resp.Success || resp.MediaID != "DRAFT" {
+		t.Fatalf("status %d, response %+v", code, resp)
+	}
+	wantCalls := []string{"token", "uploadimg a.png", "add_material cover.png", "draft/add"}
+	if !reflect.DeepEqual(server.calls, wantCalls) {
+		t.Errorf("calls = %q, want %q", server.calls, wantCalls)
+	}
+	a := server.draft.Articles[0]
+	if a.Title != "标题" || a.Digest != "正文。" || a.ThumbMediaID != "THUMB" {
+		t.Errorf("draft article = {title %q, digest %q, thumb %q}", a.Title, a.Digest, a.ThumbMediaID)
+	}
+	if !strings.Contains(a.Content, `src="http://mmbiz.qpic.cn/a.png"`) {
+		t.Errorf("content lacks uploaded image:\n%s", a.Content)
+	}
+
+	// 接口请求没有文章目录，本地封面一律拒绝
+	server.calls = nil
+	code, resp = post(PublishRequest{Markdown: "# 标题\n", Cover: "/etc/passwd"})
+	if code != http.StatusBadRequest || !strings.Contains(resp.Error, "without base directory") {
+		t.Errorf("local cover: status %d, error %q", code, resp.Error)
+	}
+	for _, call := range server.calls {
+		if strings.HasPrefix(call, "add_material") || call == "draft/add" {
+			t.Errorf("local cover: unexpected call %s", call)
+		}
+	}
+
+	// 没有配置 image_hosts 时只能下载公网图片，回环地址上的正文图片和封面都不下载
+	server.calls = nil
+	public := handlePublishDraft(&Config{}, pub, nil)
+	code, resp = postPublish(t, public, "/api/publish/draft", PublishRequest{
+		Markdown: "# 标题\n\n![图](" + server.URL + "/files/b.png)\n",
+		Cover:    "http://169.254.169.254/latest/meta-data/cover.png",
+	})
+	if code != http.StatusBadRequest || !strings.Contains(resp.Error, "remote image blocked") {
+		t.Errorf("private cover: status %d, error %q", code, resp.Error)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0].Message, "remote image blocked") {
+		t.Errorf("private body image: warnings %+v", resp.Warnings)
+	}
+	for _, call := range server.calls {
+		if call != "token" {
+			t.Errorf("private images: unexpected call %s", call)
+		}
+	}
+}
+
+// postPublish 向发布接口提交请求并解析响应
+func postPublish(t *testing.T, handler http.HandlerFunc, path string, req interface{}) (int, PublishResponse) {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(body))))
+	var resp PublishResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, resp
+}
+
+// TestHandlePublishBatchImageBudget /api/publish/batch 的全部文章共用一个请求的图片下载额度
+func TestHandlePublishBatchImageBudget(t *testing.T) {
+	server := newPublishServer(t)
+	handler := handlePublishBatch(&Config{ImageHosts: []string{"127.0.0.1"}}, server.pub, nil)
+
+	// 8 篇文章各有 6 张正文图片和 1 张封面，共 56 张，超出每个请求 50 张的限制
+	var req BatchPublishRequest
+	req.DryRun = true
+	for i := 0; i < 8; i++ {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# 文章 %d\n\n", i+1)
+		for j := 0; j < 6; j++ {
+			fmt.Fprintf(&b, "![图](%s/files/%d-%d.png)\n\n", server.URL, i, j)
+		}
+		req.Articles = append(req.Articles, PublishRequest{Markdown: b.String(), Cover: fmt.Sprintf("%s/files/cover-%d.png", server.URL, i)})
+	}
+
+	code, resp := postPublish(t, handler, "/api/publish/batch", req)
+	if code != http.StatusBadRequest || !strings.Contains(resp.Error, "article 8") || !strings.Contains(resp.Error, "more than 50 remote images") {
+		t.Errorf("status %d, error %q", code, resp.Error)
+	}
+	if server.files != requestMaxImages {
+		t.Errorf("downloaded %d images, want %d", server.files, requestMaxImages)
+	}
+}
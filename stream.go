@@ -0,0 +1,382 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"bilibili-uploader/internal/converter"
+)
+
+const (
+	// maxStreamSessions 同时存在的流式转换会话上限
+	maxStreamSessions = 64
+	// maxStreamDocument 会话中文章的最大字节数
+	maxStreamDocument = 4 << 20
+	// streamCacheBytes 所有会话共用的块缓存的内存上限
+	streamCacheBytes = 64 << 20
+	// streamIdleTimeout 没有订阅者的会话在最后一次使用后保留的时间
+	streamIdleTimeout = 30 * time.Minute
+)
+
+var errStreamTooLarge = fmt.Errorf("document is larger than %d bytes", maxStreamDocument)
+
+// StreamEdit 一次编辑：把文章中 [From, To) 替换为 Text，偏移为 UTF-16 码元（与浏览器字符串下标一致）
+type StreamEdit struct {
+	From int    `json:"from"`
+	To   int    `json:"to"`
+	Text string `json:"text"`
+}
+
+// StreamEditRequest POST /api/v1/stream/{id}/edits 请求：Version 为客户端当前的版本，
+// Edits 依次应用，每项的偏移基于应用前一项之后的文章；Markdown 不为 null 时整篇替换，忽略 Edits
+type StreamEditRequest struct {
+	Version  int          `json:"version"`
+	Edits    []StreamEdit `json:"edits,omitempty"`
+	Markdown *string      `json:"markdown,omitempty"`
+}
+
+// StreamResponse 创建会话和提交编辑的响应
+type StreamResponse struct {
+	Success bool   `json:"success"`
+	Session string `json:"session,omitempty"`
+	Version int    `json:"version"`
+	Error   string `json:"error,omitempty"`
+}
+
+// StreamRender SSE render 事件：Blocks 为全部块的顺序，HTML 只包含订阅者还没有收到过的块，
+// Footnotes 为文末脚注的 HTML，只在变化时发送
+type StreamRender struct {
+	Version   int                       `json:"version"`
+	Blocks    []converter.RenderedBlock `json:"blocks"`
+	HTML      map[string]string         `json:"html"`
+	Footnotes *string                   `json:"footnotes,omitempty"`
+}
+
+// streamSession 一篇文章的流式转换状态
+type streamSession struct {
+	mu        sync.Mutex
+	renderer  *converter.BlockRenderer
+	text      string
+	version   int
+	blocks    []converter.RenderedBlock
+	footnotes string
+	subs      map[chan struct{}]bool
+	lastUsed  time.Time
+}
+
+// streamServer 管理流式转换会话：客户端只提交编辑，服务端保存文章并按块增量渲染，
+// 通过 SSE 推送变化的块
+type streamServer struct {
+	cfg *Config
+	// cache 所有会话共用的块缓存，缓存键包含渲染选项
+	cache    *converter.BlockCache
+	mu       sync.Mutex
+	sessions map[string]*streamSession
+}
+
+// handleStream 处理 /api/v1/stream 下的请求：
+//
+//	POST   /api/v1/stream              创建会话，请求与 /api/v1/convert 相同
+//	GET    /api/v1/stream/{id}/events  SSE 推送 render 事件
+//	POST   /api/v1/stream/{id}/edits   提交编辑
+//	DELETE /api/v1/stream/{id}         关闭会话
+func handleStream(cfg *Config) http.HandlerFunc {
+	s := newStreamServer(cfg)
+	go s.expire()
+	return s.serveHTTP
+}
+
+func newStreamServer(cfg *Config) *streamServer {
+	return &streamServer{cfg: cfg, cache: converter.NewBlockCache(streamCacheBytes), sessions: make(map[string]*streamSession)}
+}
+
+func (s *streamServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/stream"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.handleCreate(w, r)
+	case id == "":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case action == "" && r.Method == http.MethodDelete:
+		s.handleDelete(w, id)
+	case action == "events" && r.Method == http.MethodGet:
+		s.handleEvents(w, r, id)
+	case action == "edits" && r.Method == http.MethodPost:
+		s.handleEdits(w, r, id)
+	case action == "" || action == "events" || action == "edits":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// errTooManySessions 会话数达到 maxStreamSessions
+var errTooManySessions = errors.New("too many stream sessions")
+
+// handleCreate 校验选项并创建会话，渲染初始文章；选项错误的响应与 /api/v1/convert 相同。
+// 会话数达到上限时在解析和渲染之前拒绝
+func (s *streamServer) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if s.full() {
+		sendConvertV1Response(w, StreamResponse{Error: errTooManySessions.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+	req, err := decodeConvertV1(http.MaxBytesReader(w, r.Body, 2*maxStreamDocument))
+	var conv *converter.WechatConverter
+	if err == nil {
+		conv, _, err = newV1Converter(s.cfg, req)
+	}
+	if err == nil && len(req.Markdown) > maxStreamDocument {
+		sendConvertV1Response(w, ConvertV1Error{Error: errStreamTooLarge.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		sendConvertV1Error(w, err)
+		return
+	}
+
+	conv.SetBlockCache(s.cache)
+	sess := &streamSession{
+		renderer: converter.NewBlockRenderer(conv),
+		version:  1,
+		subs:     make(map[chan struct{}]bool),
+		lastUsed: time.Now(),
+	}
+	sess.render(req.Markdown)
+
+	id, err := newSessionID()
+	if err != nil {
+		sendConvertV1Response(w, StreamResponse{Error: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	// 渲染期间可能有其他会话创建，加入前再检查一次
+	s.mu.Lock()
+	if len(s.sessions) >= maxStreamSessions {
+		s.mu.Unlock()
+		sendConvertV1Response(w, StreamResponse{Error: errTooManySessions.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+	s.sessions[id] = sess
+	s.mu.Unlock()
+
+	sendConvertV1Response(w, StreamResponse{Success: true, Session: id, Version: sess.version}, http.StatusCreated)
+}
+
+// handleEdits 应用编辑并重新渲染；版本与服务端不一致时返回 409 和服务端的版本，客户端应整篇重新提交
+func (s *streamServer) handleEdits(w http.ResponseWriter, r *http.Request, id string) {
+	sess := s.session(id)
+	if sess == nil {
+		sendConvertV1Response(w, StreamResponse{Error: "unknown stream session"}, http.StatusNotFound)
+		return
+	}
+	var req StreamEditRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 2*maxStreamDocument)).Decode(&req); err != nil {
+		sendConvertV1Response(w, StreamResponse{Error: "Invalid JSON"}, http.StatusBadRequest)
+		return
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.lastUsed = time.Now()
+	if req.Version != sess.version {
+		sendConvertV1Response(w, StreamResponse{Session: id, Version: sess.version, Error: "version mismatch"}, http.StatusConflict)
+		return
+	}
+
+	text := sess.text
+	if req.Markdown != nil {
+		text = *req.Markdown
+	} else {
+		for _, edit := range req.Edits {
+			var err error
+			if text, err = applyEdit(text, edit); err != nil {
+				sendConvertV1Response(w, StreamResponse{Session: id, Version: sess.version, Error: err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if len(text) > maxStreamDocument {
+		sendConvertV1Response(w, StreamResponse{Session: id, Version: sess.version, Error: errStreamTooLarge.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	sess.version++
+	if text != sess.text {
+		sess.render(text)
+	}
+	for ch := range sess.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	sendConvertV1Response(w, StreamResponse{Success: true, Session: id, Version: sess.version}, http.StatusOK)
+}
+
+// handleEvents 以 SSE 推送会话的渲染结果：连接后先发送完整结果，之后每次编辑发送一次 render 事件
+func (s *streamServer) handleEvents(w http.ResponseWriter, r *http.Request, id string) {
+	sess := s.session(id)
+	if sess == nil {
+		http.NotFound(w, r)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+	ch <- struct{}{}
+	sess.mu.Lock()
+	sess.subs[ch] = true
+	sess.mu.Unlock()
+	defer func() {
+		sess.mu.Lock()
+		delete(sess.subs, ch)
+		sess.lastUsed = time.Now()
+		sess.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	// sent 订阅者已有的块，footnotes 为 nil 表示还没有发送过脚注
+	sent := make(map[string]bool)
+	var footnotes *string
+	ticker := time.NewTicker(watchKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			sess.mu.Lock()
+			event := StreamRender{Version: sess.version, Blocks: append([]converter.RenderedBlock{}, sess.blocks...), HTML: make(map[string]string)}
+			if footnotes == nil || *footnotes != sess.footnotes {
+				f := sess.footnotes
+				footnotes, event.Footnotes = &f, &f
+			}
+			sess.mu.Unlock()
+
+			// 客户端只保留当前的块，之前发送过但已删除的块再次出现时需要重新发送
+			current := make(map[string]bool, len(event.Blocks))
+			for _, block := range event.Blocks {
+				if !sent[block.ID] {
+					event.HTML[block.ID] = block.HTML
+				}
+				current[block.ID] = true
+			}
+			sent = current
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: render\ndata: %s\n\n", data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// handleDelete 关闭会话
+func (s *streamServer) handleDelete(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	_, ok := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	if !ok {
+		sendConvertV1Response(w, StreamResponse{Error: "unknown stream session"}, http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// full 判断会话数是否已达到上限
+func (s *streamServer) full() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions) >= maxStreamSessions
+}
+
+func (s *streamServer) session(id string) *streamSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// expire 定期删除没有订阅者且长时间未使用的会话
+func (s *streamServer) expire() {
+	for now := range time.Tick(time.Minute) {
+		s.expireIdle(now)
+	}
+}
+
+// expireIdle 删除到 now 为止没有订阅者且超过 streamIdleTimeout 未使用的会话
+func (s *streamServer) expireIdle(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, sess := range s.sessions {
+		sess.mu.Lock()
+		if len(sess.subs) == 0 && now.Sub(sess.lastUsed) > streamIdleTimeout {
+			delete(s.sessions, id)
+		}
+		sess.mu.Unlock()
+	}
+}
+
+// render 重新渲染文章，未变化的块使用缓存；调用时需持有 sess.mu
+func (sess *streamSession) render(text string) {
+	sess.text = text
+	sess.blocks, sess.footnotes = sess.renderer.Render(text)
+}
+
+// applyEdit 按 UTF-16 偏移替换文本
+func applyEdit(text string, edit StreamEdit) (string, error) {
+	from, ok1 := utf16Offset(text, edit.From)
+	to, ok2 := utf16Offset(text, edit.To)
+	if !ok1 || !ok2 || from > to {
+		return "", fmt.Errorf("invalid edit range [%d, %d)", edit.From, edit.To)
+	}
+	return text[:from] + edit.Text + text[to:], nil
+}
+
+// utf16Offset 把 UTF-16 偏移转换为字节偏移，偏移超出文本或落在代理对中间时返回 false
+func utf16Offset(text string, n int) (int, bool) {
+	if n < 0 {
+		return 0, false
+	}
+	units := 0
+	for i, r := range text {
+		if units == n {
+			return i, true
+		}
+		if units > n {
+			return 0, false
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(text), units == n
+}
+
+// newSessionID 生成随机的会话 ID
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
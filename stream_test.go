@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestStream 返回不定期清理会话的流式转换服务
+func newTestStream(t *testing.T) *streamServer {
+	return newStreamServer(&Config{ThemeDir: filepath.Join(t.TempDir(), "missing")})
+}
+
+// streamRequest 向 s 发送请求，返回状态码和解析后的响应
+func streamRequest(t *testing.T, s *streamServer, method, path, body string) (int, StreamResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.serveHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	var resp StreamResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+// createStream 创建会话并返回会话 ID
+func createStream(t *testing.T, s *streamServer, markdown string) string {
+	t.Helper()
+	body, _ := json.Marshal(ConvertV1Request{Markdown: markdown})
+	code, resp := streamRequest(t, s, http.MethodPost, "/api/v1/stream", string(body))
+	if code != http.StatusCreated || resp.Session == "" || resp.Version != 1 {
+		t.Fatalf("create: %d %+v", code, resp)
+	}
+	return resp.Session
+}
+
+// TestStreamEdits 编辑按 UTF-16 偏移应用，版本不一致返回 409 和服务端的版本，偏移无效时文章不变
+func TestStreamEdits(t *testing.T) {
+	s := newTestStream(t)
+	// 😀 在 UTF-16 中占两个码元，b 的偏移为 7
+	const initial = "# A\n\n😀b\n"
+	id := createStream(t, s, initial)
+	path := "/api/v1/stream/" + id + "/edits"
+
+	for _, tc := range []struct {
+		name    string
+		body    string
+		status  int
+		version int
+		text    string
+	}{
+		{"replace after surrogate pair", `{"version":1,"edits":[{"from":7,"to":8,"text":"c"}]}`, http.StatusOK, 2, "# A\n\n😀c\n"},
+		{"edits applied in order", `{"version":2,"edits":[{"from":0,"to":3,"text":"## B"},{"from":9,"to":9,"text":"d"}]}`, http.StatusOK, 3, "## B\n\n😀cd\n"},
+		{"stale version", `{"version":1,"edits":[{"from":0,"to":0,"text":"x"}]}`, http.StatusConflict, 3, "## B\n\n😀cd\n"},
+		{"inside surrogate pair", `{"version":3,"edits":[{"from":7,"to":7,"text":"x"}]}`, http.StatusBadRequest, 3, "## B\n\n😀cd\n"},
+		{"past the end", `{"version":3,"edits":[{"from":12,"to":13,"text":"x"}]}`, http.StatusBadRequest, 3, "## B\n\n😀cd\n"},
+		{"reversed range", `{"version":3,"edits":[{"from":2,"to":1,"text":"x"}]}`, http.StatusBadRequest, 3, "## B\n\n😀cd\n"},
+		{"later edit invalid", `{"version":3,"edits":[{"from":0,"to":0,"text":"x"},{"from":-1,"to":0,"text":""}]}`, http.StatusBadRequest, 3, "## B\n\n😀cd\n"},
+		{"whole document", `{"version":3,"markdown":"new"}`, http.StatusOK, 4, "new"},
+		{"invalid JSON", `{"version":`, http.StatusBadRequest, 0, "new"},
+	} {
+		code, resp := streamRequest(t, s, http.MethodPost, path, tc.body)
+		sess := s.session(id)
+		sess.mu.Lock()
+		text := sess.text
+		sess.mu.Unlock()
+		if code != tc.status || resp.Version != tc.version || text != tc.text {
+			t.Errorf("%s: %d version %d text %q, want %d version %d text %q", tc.name, code, resp.Version, text, tc.status, tc.version, tc.text)
+		}
+	}
+
+	if code, _ := streamRequest(t, s, http.MethodPost, "/api/v1/stream/unknown/edits", `{"version":1}`); code != http.StatusNotFound {
+		t.Errorf("unknown session: %d, want 404", code)
+	}
+}
+
+// TestStreamEvents 连接后先收到全部块，编辑后只收到新增块的 HTML
+func TestStreamEvents(t *testing.T) {
+	s := newTestStream(t)
+	server := httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	defer server.Close()
+	id := createStream(t, s, "# A\n\nb\n")
+
+	resp, err := http.Get(server.URL + "/api/v1/stream/" + id + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	next := func() StreamRender {
+		t.Helper()
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var event StreamRender
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatal(err)
+				}
+				return event
+			}
+		}
+	}
+
+	first := next()
+	if first.Version != 1 || len(first.Blocks) != 2 || len(first.HTML) != 2 || first.Blocks[1].Line != 3 {
+		t.Fatalf("first event: %+v", first)
+	}
+	if code, r := streamRequest(t, s, http.MethodPost, "/api/v1/stream/"+id+"/edits", `{"version":1,"edits":[{"from":7,"to":7,"text":"\n\nc"}]}`); code != http.StatusOK {
+		t.Fatalf("edit: %d %+v", code, r)
+	}
+	second := next()
+	if second.Version != 2 || len(second.Blocks) != 3 || len(second.HTML) != 1 || !strings.Contains(second.HTML[second.Blocks[2].ID], "c") {
+		t.Errorf("second event: %+v", second)
+	}
+}
+
+// TestStreamExpire 没有订阅者的会话超过 streamIdleTimeout 未使用后删除，有订阅者的会话保留
+func TestStreamExpire(t *testing.T) {
+	s := newTestStream(t)
+	idle := createStream(t, s, "a")
+	watched := createStream(t, s, "b")
+	sess := s.session(watched)
+	sess.mu.Lock()
+	sess.subs[make(chan struct{}, 1)] = true
+	sess.mu.Unlock()
+
+	s.expireIdle(time.Now())
+	if s.session(idle) == nil {
+		t.Fatal("session expired before the idle timeout")
+	}
+	s.expireIdle(time.Now().Add(streamIdleTimeout + time.Minute))
+	if s.session(watched) == nil {
+		t.Error("session with a subscriber expired")
+	}
+	if code, _ := streamRequest(t, s, http.MethodPost, "/api/v1/stream/"+idle+"/edits", `{"version":1,"markdown":"x"}`); code != http.StatusNotFound {
+		t.Errorf("edit after expiry: %d, want 404", code)
+	}
+	if code, _ := streamRequest(t, s, http.MethodDelete, "/api/v1/stream/"+idle, ""); code != http.StatusNotFound {
+		t.Errorf("delete after expiry: %d, want 404", code)
+	}
+}
+
+// TestStreamSessionLimit 会话数达到上限时在解析请求之前返回 503，删除会话后可以再创建
+func TestStreamSessionLimit(t *testing.T) {
+	s := newTestStream(t)
+	for i := 0; i < maxStreamSessions; i++ {
+		createStream(t, s, "a")
+	}
+	if code, resp := streamRequest(t, s, http.MethodPost, "/api/v1/stream", `not json`); code != http.StatusServiceUnavailable || resp.Error != errTooManySessions.Error() {
+		t.Errorf("create over the limit: %d %+v, want 503", code, resp)
+	}
+
+	var id string
+	s.mu.Lock()
+	for id = range s.sessions {
+		break
+	}
+	s.mu.Unlock()
+	if code, _ := streamRequest(t, s, http.MethodDelete, "/api/v1/stream/"+id, ""); code != http.StatusNoContent {
+		t.Fatalf("delete: %d", code)
+	}
+	createStream(t, s, "a")
+}
@@ -5,7 +5,7 @@
 微信公众号 Markdown 转换 API 测试脚本
 
 使用方法:
-1. 确保服务器正在运行: go run main.go
+1. 确保服务器正在运行: go run .
 2. 运行此脚本: python test_api.py
 3. 查看生成的 output.html 文件
 """
@@ -16,7 +16,7 @@ import sys
 from pathlib import Path
 
 # API 配置
-API_URL = "http://localhost:8080/api/convert"
+API_URL = "http://localhost:8080/api/export/html"
 
 # 测试用的 Markdown 内容
 SAMPLE_MARKDOWN = """# 微信公众号文章标题
@@ -141,38 +141,35 @@ $$P(A|B) = \\frac{P(B|A) \\cdot P(A)}{P(B)}$$
 
 *本文使用微信公众号 Markdown 编辑器创建*"""
 
-def convert_markdown(markdown_content):
+def export_document(markdown_content):
     """
-    调用 API 转换 Markdown 内容
+    调用导出 API，返回带主题和手机预览外框的完整 HTML 文档
     """
     headers = {
         "Content-Type": "application/json"
     }
     
     payload = {
-        "markdown": markdown_content
+        "markdown": markdown_content,
+        "preview": True
     }
     
     try:
-        print("🔄 正在调用转换 API...")
+        print("🔄 正在调用导出 API...")
         response = requests.post(API_URL, 
                                data=json.dumps(payload), 
                                headers=headers,
                                timeout=30)
-        response.raise_for_status()
-        
-        result = response.json()
-        
-        if result['success']:
-            print("✅ 转换成功！")
-            return result['html']
-        else:
-            print(f"❌ 转换失败: {result['error']}")
+        if response.status_code != 200:
+            print(f"❌ 导出失败: {response.json().get('error')}")
             return None
+        
+        print("✅ 导出成功！")
+        return response.text
             
     except requests.exceptions.ConnectionError:
         print("🔌 连接失败: 请确保服务器正在运行 (http://localhost:8080)")
-        print("   运行命令: go run main.go")
+        print("   运行命令: go run .")
         return None
     except requests.exceptions.Timeout:
         print("⏰ 请求超时: 服务器响应时间过长")
@@ -184,61 +181,13 @@ def convert_markdown(markdown_content):
         print("📄 响应格式错误: 服务器返回的不是有效的 JSON")
         return None
 
-def save_html_file(html_content, filename="output.html"):
+def save_html_file(document, filename="output.html"):
     """
-    保存 HTML 内容到文件
+    保存 HTML 文档到文件
     """
     try:
-        # 创建完整的 HTML 文档
-        full_html = f"""<!DOCTYPE html>
-<html lang="zh-CN">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>微信公众号文章预览</title>
-    <style>
-        body {{
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif;
-            max-width: 800px;
-            margin: 0 auto;
-            padding: 20px;
-            background: #f5f5f5;
-        }}
-        .container {{
-            background: white;
-            padding: 30px;
-            border-radius: 8px;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
-        }}
-        .header {{
-            text-align: center;
-            margin-bottom: 30px;
-            padding-bottom: 20px;
-            border-bottom: 1px solid #eee;
-        }}
-        .header h1 {{
-            color: #333;
-            margin: 0;
-        }}
-        .header p {{
-            color: #666;
-            margin: 10px 0 0 0;
-        }}
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>📱 微信公众号预览</h1>
-            <p>以下是转换后的文章效果</p>
-        </div>
-        {html_content}
-    </div>
-</body>
-</html>"""
-        
         with open(filename, 'w', encoding='utf-8') as f:
-            f.write(full_html)
+            f.write(document)
         
         file_path = Path(filename).resolve()
         print(f"💾 HTML 文件已保存: {file_path}")
@@ -276,8 +225,8 @@ def main():
     print(f"📏 Markdown 内容长度: {len(markdown_content)} 字符")
     print()
     
-    # 调用转换 API
-    html_content = convert_markdown(markdown_content)
+    # 调用导出 API
+    html_content = export_document(markdown_content)
     
     if html_content:
         print(f"📄 转换后 HTML 长度: {len(html_content)} 字符")
@@ -288,7 +237,7 @@ def main():
             print("🎉 转换完成！")
             print("📋 接下来你可以:")
             print("   1. 打开生成的 HTML 文件查看效果")
-            print("   2. 复制 HTML 内容到微信公众号编辑器")
+            print("   2. 调用 /api/convert 获取可粘贴到公众号编辑器的 HTML 片段")
             print("   3. 或者使用浏览器测试页面: test_api.html")
         
         # 显示部分 HTML 内容预览
@@ -301,7 +250,7 @@ def main():
     else:
         print()
         print("💡 故障排除:")
-        print("   1. 检查服务器是否启动: go run main.go")
+        print("   1. 检查服务器是否启动: go run .")
         print("   2. 确认端口 8080 未被占用")
         print("   3. 检查防火墙设置")
 
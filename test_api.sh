@@ -3,7 +3,7 @@
 # 微信公众号 Markdown 转换 API 测试脚本
 # 使用方法: ./test_api.sh
 
-API_URL="http://localhost:8080/api/convert"
+API_URL="http://localhost:8080/api/export/html"
 OUTPUT_FILE="curl_output.html"
 
 echo "🚀 微信公众号 Markdown 转换 API 测试"
@@ -15,7 +15,7 @@ if curl -s --connect-timeout 3 "$API_URL" > /dev/null 2>&1; then
     echo "✅ 服务器运行正常"
 else
     echo "❌ 无法连接到服务器 ($API_URL)"
-    echo "💡 请先启动服务器: go run main.go"
+    echo "💡 请先启动服务器: go run ."
     exit 1
 fi
 
@@ -128,135 +128,44 @@ EOF
 echo "📏 内容长度: $(echo "$MARKDOWN_CONTENT" | wc -c) 字符"
 echo ""
 
-# 创建 JSON 请求体
+# 创建 JSON 请求体，preview 使用手机宽度的预览外框
 JSON_PAYLOAD=$(cat << EOF
 {
-  "markdown": $(echo "$MARKDOWN_CONTENT" | jq -Rs .)
+  "markdown": $(echo "$MARKDOWN_CONTENT" | jq -Rs .),
+  "preview": true
 }
 EOF
 )
 
-echo "🔄 正在调用转换 API..."
+echo "🔄 正在调用导出 API..."
 
-# 发送请求并获取响应
-RESPONSE=$(curl -s -w "\n%{http_code}" \
+# 发送请求，完整的 HTML 文档直接写入输出文件
+HTTP_CODE=$(curl -s -o "$OUTPUT_FILE" -w "%{http_code}" \
   -X POST \
   -H "Content-Type: application/json" \
   -d "$JSON_PAYLOAD" \
   "$API_URL")
 
-# 分离响应体和状态码
-HTTP_CODE=$(echo "$RESPONSE" | tail -n1)
-RESPONSE_BODY=$(echo "$RESPONSE" | head -n -1)
-
 echo "📡 HTTP 状态码: $HTTP_CODE"
 
 if [ "$HTTP_CODE" -eq 200 ]; then
-    echo "✅ 请求成功！"
-    
-    # 解析 JSON 响应
-    SUCCESS=$(echo "$RESPONSE_BODY" | jq -r '.success')
-    
-    if [ "$SUCCESS" = "true" ]; then
-        echo "🎉 转换成功！"
-        
-        # 提取 HTML 内容
-        HTML_CONTENT=$(echo "$RESPONSE_BODY" | jq -r '.html')
-        HTML_LENGTH=$(echo "$HTML_CONTENT" | wc -c)
-        
-        echo "📄 生成的 HTML 长度: $HTML_LENGTH 字符"
-        
-        # 创建完整的 HTML 文件
-        cat > "$OUTPUT_FILE" << EOF
-<!DOCTYPE html>
-<html lang="zh-CN">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>微信公众号文章预览 - curl 测试</title>
-    <style>
-        body {
-            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', 'Roboto', sans-serif;
-            max-width: 800px;
-            margin: 0 auto;
-            padding: 20px;
-            background: #f5f5f5;
-        }
-        .container {
-            background: white;
-            padding: 30px;
-            border-radius: 8px;
-            box-shadow: 0 2px 10px rgba(0,0,0,0.1);
-        }
-        .header {
-            text-align: center;
-            margin-bottom: 30px;
-            padding-bottom: 20px;
-            border-bottom: 1px solid #eee;
-        }
-        .header h1 {
-            color: #333;
-            margin: 0;
-        }
-        .header p {
-            color: #666;
-            margin: 10px 0 0 0;
-        }
-        .footer {
-            text-align: center;
-            margin-top: 30px;
-            padding-top: 20px;
-            border-top: 1px solid #eee;
-            color: #999;
-            font-size: 14px;
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>📱 微信公众号预览</h1>
-            <p>通过 curl 命令调用 API 生成</p>
-        </div>
-        
-        $HTML_CONTENT
-        
-        <div class="footer">
-            <p>生成时间: $(date '+%Y-%m-%d %H:%M:%S')</p>
-            <p>测试工具: curl + bash</p>
-        </div>
-    </div>
-</body>
-</html>
-EOF
-        
-        ABS_PATH=$(realpath "$OUTPUT_FILE")
-        echo "💾 HTML 文件已保存: $ABS_PATH"
-        echo "🌐 在浏览器中打开: file://$ABS_PATH"
-        
-        # 显示 HTML 内容预览
-        echo ""
-        echo "📋 HTML 内容预览:"
-        echo "----------------------------------------"
-        echo "$HTML_CONTENT" | head -c 300
-        echo "..."
-        echo "----------------------------------------"
-        
-        echo ""
-        echo "🎯 测试完成！可以进行以下操作:"
-        echo "   1. 打开 $OUTPUT_FILE 查看效果"
-        echo "   2. 复制 HTML 内容到微信公众号编辑器"
-        echo "   3. 使用不同的 Markdown 内容继续测试"
-        
-    else
-        ERROR_MSG=$(echo "$RESPONSE_BODY" | jq -r '.error')
-        echo "❌ 转换失败: $ERROR_MSG"
-    fi
-    
+    echo "✅ 导出成功！"
+    echo "📄 生成的 HTML 长度: $(wc -c < "$OUTPUT_FILE") 字符"
+
+    ABS_PATH=$(realpath "$OUTPUT_FILE")
+    echo "💾 HTML 文件已保存: $ABS_PATH"
+    echo "🌐 在浏览器中打开: file://$ABS_PATH"
+
+    echo ""
+    echo "🎯 测试完成！可以进行以下操作:"
+    echo "   1. 打开 $OUTPUT_FILE 查看效果"
+    echo "   2. 调用 /api/convert 获取可粘贴到公众号编辑器的 HTML 片段"
+    echo "   3. 使用不同的 Markdown 内容继续测试"
 else
-    echo "❌ 请求失败 (HTTP $HTTP_CODE)"
-    echo "响应内容: $RESPONSE_BODY"
-    
+    ERROR_MSG=$(jq -r '.error' "$OUTPUT_FILE" 2>/dev/null)
+    rm -f "$OUTPUT_FILE"
+    echo "❌ 请求失败 (HTTP $HTTP_CODE): $ERROR_MSG"
+
     echo ""
     echo "💡 故障排除建议:"
     echo "   1. 检查服务器是否正常启动"
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bilibili-uploader/internal/converter"
+)
+
+// watchInterval 检查文件变化的间隔
+const watchInterval = 300 * time.Millisecond
+
+// watchCacheBytes 预览时块缓存的内存上限，文件变化后只重新渲染改动的块
+const watchCacheBytes = 32 << 20
+
+// watchKeepAlive SSE 连接的心跳间隔，避免代理断开空闲连接
+const watchKeepAlive = 15 * time.Second
+
+// liveReloadScript 预览页面的实时刷新脚本：收到 change 事件后重新获取页面，
+// 替换样式和正文，再用 main.js 的 scrollToLine 回到刷新前顶部的源码行；没有行号时按比例 scroll(scrollFactor)
+const liveReloadScript = `<script src="/static/marked/marked.min.js"></script>
+<script src="/static/highlight/highlight.min.js"></script>
+<script src="/static/marked/marked_hljs.umd.min.js"></script>
+<script src="/static/main.js"></script>
+<script>
+(function () {
+  var source = new EventSource("/events?file=" + encodeURIComponent(%s));
+  source.addEventListener("change", function () {
+    var height = document.body.scrollHeight;
+    var factor = height > 0 ? window.scrollY / height : 0;
+    var line = lineAtTop();
+    fetch(location.href, { cache: "no-store" }).then(function (resp) {
+      return resp.ok ? resp.text() : null;
+    }).then(function (text) {
+      if (text === null) {
+        return;
+      }
+      var next = new DOMParser().parseFromString(text, "text/html");
+      // 新内容第一次出现公式时需要加载 MathJax，直接刷新页面
+      if (!window.MathJax && next.querySelector("script[src*=mathjax]")) {
+        location.reload();
+        return;
+      }
+      document.title = next.title;
+      document.querySelector("style").textContent = next.querySelector("style").textContent;
+      var wenyan = document.getElementById("wenyan");
+      wenyan.innerHTML = next.getElementById("wenyan").innerHTML;
+      var restore = function () {
+        if (!line || !scrollToLine(line)) {
+          scroll(factor);
+        }
+      };
+      if (window.MathJax && MathJax.typesetPromise) {
+        MathJax.typesetPromise([wenyan]).then(restore, restore);
+      } else {
+        restore();
+      }
+    });
+  });
+})();
+</script>
+`
+
+// watchServer 监视本地 Markdown 文件，提供预览页面并通过 SSE 通知变化
+type watchServer struct {
+	cfg *Config
+	req ExportRequest
+	// root 监视的目录，single 为监视单个文件时的文件名（相对于 root）
+	root   string
+	single string
+
+	mu   sync.Mutex
+	subs map[chan struct{}]string
+}
+
+// runWatch 监视文件或目录：watch [-port 8080] [-platform name] [-theme name] [-preview=false] file|dir
+func runWatch(cfg *Config, args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	port := flags.String("port", cfg.Port, "port to listen on")
+	platform := flags.String("platform", "", "target platform profile (default: wechat)")
+	theme := flags.String("theme", "", "theme name (default: front matter theme or platform default)")
+	preview := flags.Bool("preview", true, "wrap the article in a phone-width preview frame")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: watch [-port 8080] [-platform name] [-theme name] [-preview=false] file|dir")
+		return 2
+	}
+	if _, ok := converter.GetProfile(*platform); !ok {
+		fmt.Fprintf(os.Stderr, "Unknown platform: %s\n", *platform)
+		return 2
+	}
+
+	target := flags.Arg(0)
+	info, err := os.Stat(target)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	s := &watchServer{
+		cfg:  cfg,
+		req:  ExportRequest{ConvertRequest: ConvertRequest{Platform: *platform, Theme: *theme}, Preview: *preview, blockCache: converter.NewBlockCache(watchCacheBytes), sourceLines: true},
+		root: target,
+		subs: make(map[chan struct{}]string),
+	}
+	if !info.IsDir() {
+		s.root, s.single = filepath.Split(target)
+		if s.root == "" {
+			s.root = "."
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StaticDir, "mathjax", "tex-svg-full.min.js")); err == nil {
+		s.req.mathJaxURL = "/static/mathjax/tex-svg-full.min.js"
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.StaticDir))))
+	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(s.root))))
+	mux.HandleFunc("/view/", s.handleView)
+	mux.HandleFunc("/events", s.handleEvents)
+	mux.HandleFunc("/", s.handleIndex)
+
+	go s.poll()
+
+	addr := "localhost:" + *port
+	fmt.Printf("Watching %s, preview at http://%s/\n", target, addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+// handleIndex 监视单个文件时跳转到预览页，监视目录时列出其中的文章
+func (s *watchServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.single != "" {
+		http.Redirect(w, r, "/view/"+filepath.ToSlash(s.single), http.StatusFound)
+		return
+	}
+
+	files, err := markdownFiles([]string{s.root})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"zh-CN\">\n<head>\n<meta charset=\"UTF-8\">\n<title>Preview</title>\n</head>\n<body>\n<ul>\n")
+	for _, file := range files {
+		rel, err := filepath.Rel(s.root, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		fmt.Fprintf(&b, "<li><a href=\"/view/%s\">%s</a></li>\n", html.EscapeString(rel), html.EscapeString(rel))
+	}
+	b.WriteString("</ul>\n</body>\n</html>\n")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// handleView 渲染 /view/ 后的文章，本地图片通过 /files/ 提供，转换警告输出到终端
+func (s *watchServer) handleView(w http.ResponseWriter, r *http.Request) {
+	rel := path.Clean(strings.TrimPrefix(r.URL.Path, "/view/"))
+	if !fs.ValidPath(rel) || !strings.EqualFold(path.Ext(rel), ".md") || (s.single != "" && rel != filepath.ToSlash(s.single)) {
+		http.NotFound(w, r)
+		return
+	}
+	file := filepath.Join(s.root, filepath.FromSlash(rel))
+	markdown, err := os.ReadFile(file)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	req := s.req
+	req.Markdown = string(markdown)
+	if req.Theme == "" {
+		req.Theme = converter.Parse(req.Markdown).FrontMatter.Get("theme")
+	}
+	dir := path.Dir(rel)
+	imageOpts := converter.ImageOptions{BaseDir: filepath.Dir(file), BaseURL: "/files/" + dir}
+	if dir == "." {
+		imageOpts.BaseURL = "/files"
+	}
+
+	doc, warnings, status, err := exportDocument(s.cfg, req, imageOpts)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "%s warning: %s\n", warningPrefix(file, warning), warning.Message)
+	}
+
+	// 实时刷新脚本插在 </body> 之前
+	script := fmt.Sprintf(liveReloadScript, strconv.Quote(rel))
+	if i := strings.LastIndex(doc, "</body>"); i >= 0 {
+		doc = doc[:i] + script + doc[i:]
+	} else {
+		doc += script
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write([]byte(doc))
+}
+
+// handleEvents 以 Server-Sent Events 推送 file 参数对应文章的变化
+func (s *watchServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	file := r.URL.Query().Get("file")
+
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subs[ch] = file
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(watchKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", file)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// notify 通知订阅了 file 的页面，file 为空时通知全部页面
+func (s *watchServer) notify(file string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch, sub := range s.subs {
+		if file != "" && sub != file {
+			continue
+		}
+		// 页面还没处理上一次通知时不重复发送
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// poll 定期比较文件的修改时间和大小：文章变化时通知对应页面，
+// 图片等其他文件或主题样式变化时通知全部页面
+func (s *watchServer) poll() {
+	prev := s.snapshot()
+	for range time.Tick(watchInterval) {
+		cur := s.snapshot()
+		var changed []string
+		for name, stamp := range cur {
+			if prev[name] != stamp {
+				changed = append(changed, name)
+			}
+		}
+		for name := range prev {
+			if _, ok := cur[name]; !ok {
+				changed = append(changed, name)
+			}
+		}
+		prev = cur
+		sort.Strings(changed)
+
+		for _, name := range changed {
+			if rel, ok := strings.CutPrefix(name, "file:"); ok && strings.EqualFold(path.Ext(rel), ".md") {
+				fmt.Printf("changed: %s\n", rel)
+				s.notify(rel)
+				continue
+			}
+			s.notify("")
+		}
+	}
+}
+
+// snapshot 返回监视的文件和主题样式表的修改时间和大小：监视目录时包括目录中除隐藏目录外的全部文件，
+// 监视单个文件时只有该文件
+func (s *watchServer) snapshot() map[string]string {
+	files := make(map[string]string)
+	stamp := func(info fs.FileInfo) string {
+		return info.ModTime().String() + "/" + strconv.FormatInt(info.Size(), 10)
+	}
+
+	if s.single != "" {
+		if info, err := os.Stat(filepath.Join(s.root, s.single)); err == nil {
+			files["file:"+filepath.ToSlash(s.single)] = stamp(info)
+		}
+	} else {
+		filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if p != s.root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, err := filepath.Rel(s.root, p)
+			if err != nil {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				files["file:"+filepath.ToSlash(rel)] = stamp(info)
+			}
+			return nil
+		})
+	}
+
+	if entries, err := os.ReadDir(s.cfg.ThemeDir); err == nil {
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && !entry.IsDir() {
+				files["theme:"+entry.Name()] = stamp(info)
+			}
+		}
+	}
+	return files
+}
@@ -89,6 +89,88 @@ function setContent(content) {
     document.body.appendChild(container);
     MathJax.typeset();
 }
+// 显示服务端转换的 HTML，/api/v1/convert 请求 source_lines 时块级元素带有 data-line 源码行号，可用 scrollToLine 定位
+function setHTMLContent(html) {
+    document.getElementById("wenyan")?.remove();
+    const container = document.createElement("section");
+    container.innerHTML = html;
+    container.setAttribute("id", "wenyan");
+    container.setAttribute("class", "preview");
+    document.body.appendChild(container);
+    MathJax.typeset();
+}
+// sourceBlocks 返回 scroller（不指定时为页面中的 #wenyan）中带 data-line 的元素，以及元素相对滚动起点的位置
+function sourceBlocks(scroller) {
+    const root = scroller || document.getElementById("wenyan");
+    const blocks = root ? Array.from(root.querySelectorAll("[data-line]")) : [];
+    const origin = scroller ? scroller.getBoundingClientRect().top - scroller.scrollTop : -window.scrollY;
+    return { blocks, top: element => element.getBoundingClientRect().top - origin };
+}
+// 滚动到编辑器第 line 行（从 1 开始）对应的块，块内按行数插值；scroller 为预览所在的滚动容器，
+// 不指定时滚动整个页面。没有 data-line 时返回 false
+function scrollToLine(line, scroller) {
+    const { blocks, top: offsetTop } = sourceBlocks(scroller);
+    if (blocks.length === 0) {
+        return false;
+    }
+    let i = 0;
+    while (i + 1 < blocks.length && Number(blocks[i + 1].dataset.line) <= line) {
+        i++;
+    }
+    // 同一个块的多个元素行号相同，从块的第一个元素开始插值到下一个块
+    while (i > 0 && blocks[i - 1].dataset.line === blocks[i].dataset.line) {
+        i--;
+    }
+    const block = blocks[i];
+    const start = Number(block.dataset.line);
+    let j = i + 1;
+    while (j < blocks.length && blocks[j].dataset.line === block.dataset.line) {
+        j++;
+    }
+    let top = offsetTop(block);
+    if (j < blocks.length && line > start) {
+        const next = blocks[j];
+        top += (offsetTop(next) - top) * (line - start) / (Number(next.dataset.line) - start);
+    }
+    isScrollingFromScript = true;
+    if (scroller) {
+        scroller.scrollTop = top;
+    } else {
+        window.scrollTo(0, top);
+    }
+    requestAnimationFrame(() => isScrollingFromScript = false);
+    return true;
+}
+// lineAtTop 返回预览顶部对应的源码行（可以是小数），与 scrollToLine 互逆；没有 data-line 时返回 0
+function lineAtTop(scroller) {
+    const { blocks, top: offsetTop } = sourceBlocks(scroller);
+    const y = scroller ? scroller.scrollTop : window.scrollY;
+    let i = -1;
+    while (i + 1 < blocks.length && offsetTop(blocks[i + 1]) <= y) {
+        i++;
+    }
+    if (i < 0) {
+        return blocks.length > 0 ? Number(blocks[0].dataset.line) : 0;
+    }
+    while (i > 0 && blocks[i - 1].dataset.line === blocks[i].dataset.line) {
+        i--;
+    }
+    const start = Number(blocks[i].dataset.line);
+    let j = i + 1;
+    while (j < blocks.length && blocks[j].dataset.line === blocks[i].dataset.line) {
+        j++;
+    }
+    if (j === blocks.length) {
+        return start;
+    }
+    const top = offsetTop(blocks[i]);
+    return start + (Number(blocks[j].dataset.line) - start) * (y - top) / Math.max(offsetTop(blocks[j]) - top, 1);
+}
+// 复制和导出前去掉 data-line 源码行号
+function stripSourceLines(root) {
+    root.removeAttribute("data-line");
+    root.querySelectorAll("[data-line]").forEach(element => element.removeAttribute("data-line"));
+}
 function setPreviewMode(mode) {
     document.getElementById("style")?.remove();
     setStylesheet("style", mode);
@@ -116,6 +198,7 @@ function setHighlight(css) {
 function getContent() {
     const wenyan = document.getElementById("wenyan");
     const clonedWenyan = wenyan.cloneNode(true);
+    stripSourceLines(clonedWenyan);
     const elements = clonedWenyan.querySelectorAll("mjx-container");
     elements.forEach(element => {
         const svg = element.firstChild;
@@ -132,6 +215,7 @@ function getContent() {
 function getContentWithMathImg() {
     const wenyan = document.getElementById("wenyan");
     const clonedWenyan = wenyan.cloneNode(true);
+    stripSourceLines(clonedWenyan);
     const elements = clonedWenyan.querySelectorAll("mjx-container");
     elements.forEach(element => {
         const math = element.getAttribute("math");
@@ -166,6 +250,7 @@ function getContentForGzh() {
 
     const wenyan = document.getElementById("wenyan");
     const clonedWenyan = wenyan.cloneNode(true);
+    stripSourceLines(clonedWenyan);
 
     csstree.walk(ast, {
         visit: 'Rule',
@@ -276,6 +361,7 @@ function extractDeclarations(ruleNode, resultMap) {
 function getContentForMedium() {
     const wenyan = document.getElementById("wenyan");
     const clonedWenyan = wenyan.cloneNode(true);
+    stripSourceLines(clonedWenyan);
     // 处理blockquote，移除<p>标签
     clonedWenyan.querySelectorAll('blockquote p').forEach(p => {
         const span = document.createElement('span');
@@ -0,0 +1,152 @@
+// 流式转换客户端：在 /api/v1/stream 上创建会话，只提交编辑的差异，
+// 收到 render 事件后只替换变化的块。用法：
+//   const stream = new StreamSession(container, { theme: "default" });
+//   await stream.open(markdown);
+//   stream.update(markdown);   // 每次编辑后调用
+//   stream.close();
+class StreamSession {
+    constructor(container, options = {}) {
+        this.container = container;
+        this.options = options;
+        this.id = null;
+        this.version = 0;
+        this.text = "";
+        // nodes 块 ID 对应的 DOM 节点，footer 为文末脚注的节点
+        this.nodes = new Map();
+        this.footer = [];
+        this.sending = null;
+        this.pending = null;
+        this.onrender = null;
+    }
+
+    // open 创建会话并开始接收渲染结果；选项错误时抛出服务端返回的错误
+    async open(markdown) {
+        const resp = await fetch("/api/v1/stream", {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ ...this.options, markdown }),
+        });
+        const data = await resp.json();
+        if (!resp.ok) {
+            throw new Error(data.error);
+        }
+        this.id = data.session;
+        this.version = data.version;
+        this.text = markdown;
+        this.source = new EventSource(`/api/v1/stream/${this.id}/events`);
+        this.source.addEventListener("render", event => this.apply(JSON.parse(event.data)));
+    }
+
+    // update 提交新的全文，与上次提交的内容比较后只发送变化的部分；上一次提交完成前只保留最新的内容
+    update(markdown) {
+        this.pending = markdown;
+        if (!this.sending) {
+            this.sending = this.flush().finally(() => this.sending = null);
+        }
+        return this.sending;
+    }
+
+    async flush() {
+        while (this.pending !== null && this.id) {
+            const markdown = this.pending;
+            this.pending = null;
+            if (markdown === this.text) {
+                continue;
+            }
+            let resp = await this.post({ version: this.version, edits: [diffText(this.text, markdown)] });
+            // 版本不一致时整篇重新提交
+            if (resp.status === 409) {
+                this.version = (await resp.json()).version;
+                resp = await this.post({ version: this.version, markdown });
+            }
+            const data = await resp.json();
+            if (!resp.ok) {
+                throw new Error(data.error);
+            }
+            this.version = data.version;
+            this.text = markdown;
+        }
+    }
+
+    post(body) {
+        return fetch(`/api/v1/stream/${this.id}/edits`, {
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify(body),
+        });
+    }
+
+    // apply 按 blocks 的顺序重建容器的子节点，未变化的块复用原有节点，并加上 data-line 源码行号
+    apply(event) {
+        const nodes = new Map();
+        const children = [];
+        const added = [];
+        for (const block of event.blocks) {
+            let list;
+            if (nodes.has(block.id)) {
+                // 内容相同的块出现多次时复制已有的节点
+                list = nodes.get(block.id).map(node => node.cloneNode(true));
+            } else if (this.nodes.has(block.id)) {
+                list = this.nodes.get(block.id);
+                nodes.set(block.id, list);
+            } else {
+                const template = document.createElement("template");
+                template.innerHTML = event.html[block.id] ?? "";
+                list = Array.from(template.content.childNodes);
+                nodes.set(block.id, list);
+                added.push(...list);
+            }
+            // 一个块可能输出多个顶层元素，都标记为块的行号
+            list.filter(node => node.nodeType === Node.ELEMENT_NODE).forEach(node => node.setAttribute("data-line", block.line));
+            children.push(...list);
+        }
+        if (event.footnotes !== undefined) {
+            const template = document.createElement("template");
+            template.innerHTML = event.footnotes;
+            this.footer = Array.from(template.content.childNodes);
+            added.push(...this.footer);
+        }
+        this.nodes = nodes;
+        this.container.replaceChildren(...children, ...this.footer);
+        if (window.MathJax && MathJax.typesetPromise) {
+            MathJax.typesetPromise(added.filter(node => node.nodeType === Node.ELEMENT_NODE));
+        }
+        if (this.onrender) {
+            this.onrender(event);
+        }
+    }
+
+    // close 关闭会话
+    close() {
+        this.source?.close();
+        if (this.id) {
+            fetch(`/api/v1/stream/${this.id}`, { method: "DELETE" });
+            this.id = null;
+        }
+    }
+}
+
+// diffText 返回把 before 变为 after 的一次替换，偏移为 UTF-16 下标
+function diffText(before, after) {
+    let start = 0;
+    const max = Math.min(before.length, after.length);
+    while (start < max && before[start] === after[start]) {
+        start++;
+    }
+    let end = 0;
+    while (end < max - start && before[before.length - 1 - end] === after[after.length - 1 - end]) {
+        end++;
+    }
+    // 不在代理对中间切分
+    if (start > 0 && isHighSurrogate(before.charCodeAt(start - 1))) {
+        start--;
+    }
+    if (end > 0 && isHighSurrogate(before.charCodeAt(before.length - end - 1))) {
+        end--;
+    }
+    return { from: start, to: before.length - end, text: after.slice(start, after.length - end) };
+}
+
+function isHighSurrogate(code) {
+    return code >= 0xd800 && code <= 0xdbff;
+}